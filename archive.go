@@ -0,0 +1,26 @@
+package main
+
+import (
+	"gallery-downloader/archive"
+	"gallery-downloader/config"
+	"log"
+	"net/url"
+)
+
+// downloadPicturesFromArchive reads the pictures of a page saved with its files (WARC, MHTML or "_files" folder).
+// The pictures missing from the archive are downloaded, from the URL of the page when -base is not given
+func downloadPicturesFromArchive(saved archive.Archive, baseURL *url.URL, flags Flags, cfg *config.Configuration) error {
+	page := saved.Page()
+	if !baseURL.IsAbs() && page.URL != nil {
+		baseURL = page.URL
+	}
+	if page.URL != nil {
+		log.Printf("Reading the pictures from the archive of %s", page.URL)
+	} else {
+		log.Println("Reading the pictures from the archive")
+	}
+	if flags.Stream {
+		log.Println("Web archives cannot be streamed, reading the page entirely")
+	}
+	return downloadPicturesFromPage(page.Source, page.ContentType, baseURL, saved, flags, cfg)
+}
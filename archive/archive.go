@@ -0,0 +1,85 @@
+// Package archive reads the pages saved with their resources: WARC files, MHTML files,
+// and HTML files saved with their "_files" folder
+package archive
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrNotArchive is returned by Open when the file is a simple page, without its resources
+var ErrNotArchive = errors.New("not a web archive")
+
+// Page is the page saved in an archive
+type Page struct {
+	Source      []byte
+	ContentType string
+	// URL of the page when it was saved, or nil when the archive doesn't know it
+	URL *url.URL
+}
+
+// Archive is a page saved with the files it uses
+type Archive interface {
+	// Page returns the saved page
+	Page() Page
+	// Open returns the content of a file saved with the page, and its content type.
+	// The error wraps os.ErrNotExist when the file is not in the archive
+	Open(link string) (io.ReadCloser, string, error)
+}
+
+// savedFrom finds the URL that browsers add in a comment of the pages they save
+var savedFrom = regexp.MustCompile(`<!-- saved from url=\(\d+\)(\S+?) ?-->`)
+
+// Open reads the archive of a file: a WARC file (.warc or .warc.gz), an MHTML file (.mht or .mhtml),
+// or an HTML file saved with a folder of the same name ending with "_files"
+func Open(filename string) (Archive, error) {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".warc") || strings.HasSuffix(name, ".warc.gz"):
+		return openWARC(filename)
+	case strings.HasSuffix(name, ".mht") || strings.HasSuffix(name, ".mhtml"):
+		return openMHTML(filename)
+	}
+	folder := strings.TrimSuffix(filename, filepath.Ext(filename)) + "_files"
+	if stat, err := os.Stat(folder); err == nil && stat.IsDir() {
+		return openFolder(filename, folder)
+	}
+	return nil, ErrNotArchive
+}
+
+// key returns the URL used to find a file in an archive: the fragment is never sent to the server
+func key(link string) string {
+	link = strings.TrimSpace(link)
+	fileURL, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	fileURL.Fragment = ""
+	fileURL.RawFragment = ""
+	return fileURL.String()
+}
+
+// pageURL returns the URL of a saved page when it's an absolute http(s) URL, or nil
+func pageURL(link string) *url.URL {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil
+	}
+	return parsed
+}
+
+// isHTML returns true for the content types of web pages
+func isHTML(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// notFound is the error of the files missing from an archive
+func notFound(link string) error {
+	return &os.PathError{Op: "open", Path: link, Err: os.ErrNotExist}
+}
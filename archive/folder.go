@@ -0,0 +1,70 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// folderArchive is an HTML file saved by a browser with the files of the page in a folder next to it,
+// like page.html and page_files/
+type folderArchive struct {
+	page   Page
+	folder string
+}
+
+func openFolder(filename, folder string) (*folderArchive, error) {
+	source, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// the charset of the system types is not the one of the file
+	contentType, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(filename)))
+	saved := &folderArchive{
+		page:   Page{Source: source, ContentType: contentType},
+		folder: folder,
+	}
+	if match := savedFrom.FindSubmatch(source); match != nil {
+		saved.page.URL = pageURL(string(match[1]))
+	}
+	return saved, nil
+}
+
+// Page returns the HTML file
+func (a *folderArchive) Page() Page {
+	return a.page
+}
+
+// Open reads the file of a link going through the folder, like "page_files/photo.jpg".
+// The link can be relative to the page, or absolute when the page has a base URL
+func (a *folderArchive) Open(link string) (io.ReadCloser, string, error) {
+	fileURL, err := url.Parse(link)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid URL %q: %w", link, err)
+	}
+	parts := strings.Split(fileURL.Path, "/")
+	name := filepath.Base(a.folder)
+	for index := len(parts) - 1; index >= 0; index-- {
+		if parts[index] != name {
+			continue
+		}
+		// Clean removes any ".." going out of the folder
+		relative := path.Clean("/" + strings.Join(parts[index+1:], "/"))
+		file, err := os.Open(filepath.Join(a.folder, filepath.FromSlash(relative)))
+		if err != nil {
+			return nil, "", err
+		}
+		if stat, err := file.Stat(); err != nil || stat.IsDir() {
+			file.Close()
+			return nil, "", notFound(link)
+		}
+		return file, mime.TypeByExtension(path.Ext(relative)), nil
+	}
+	return nil, "", notFound(link)
+}
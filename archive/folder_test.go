@@ -0,0 +1,64 @@
+package archive
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFolder(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "summer.html")
+	require.NoError(t, os.WriteFile(page, []byte("<!-- saved from url=(0040)https://example.com/galleries/summer/ -->\n<html></html>"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "summer_files", "thumbs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "summer_files", "beach.jpg"), []byte("beach"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "summer_files", "thumbs", "sea.png"), []byte("sea"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644))
+
+	saved, err := Open(page)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", saved.Page().ContentType)
+	require.NotNil(t, saved.Page().URL)
+	assert.Equal(t, "https://example.com/galleries/summer/", saved.Page().URL.String())
+
+	testData := []struct {
+		link        string
+		content     string
+		contentType string
+	}{
+		{"summer_files/beach.jpg", "beach", "image/jpeg"},
+		{"./summer_files/thumbs/sea.png", "sea", "image/png"},
+		{"https://example.com/galleries/summer/summer_files/beach.jpg", "beach", "image/jpeg"},
+		{"summer_files/beach.jpg#top", "beach", "image/jpeg"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.link, func(t *testing.T) {
+			reader, contentType, err := saved.Open(testItem.link)
+			require.NoError(t, err)
+			defer reader.Close()
+			content, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.content, string(content))
+			assert.Equal(t, testItem.contentType, contentType)
+		})
+	}
+
+	for _, link := range []string{"summer_files/missing.jpg", "summer_files/../secret.txt", "summer_files/thumbs", "https://example.com/beach.jpg"} {
+		t.Run(link, func(t *testing.T) {
+			_, _, err := saved.Open(link)
+			assert.ErrorIs(t, err, os.ErrNotExist)
+		})
+	}
+}
+
+func TestOpenNotArchive(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "page.html")
+	require.NoError(t, os.WriteFile(page, []byte("<html></html>"), 0644))
+
+	_, err := Open(page)
+	assert.ErrorIs(t, err, ErrNotArchive)
+}
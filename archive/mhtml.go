@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+// mhtmlArchive is a page saved by a browser as a single MIME file (.mht or .mhtml),
+// with a part for the page and each of its files
+type mhtmlArchive struct {
+	page  Page
+	files map[string]mhtmlFile
+}
+
+type mhtmlFile struct {
+	content     []byte
+	contentType string
+}
+
+func openMHTML(filename string) (*mhtmlArchive, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readMHTML(file)
+}
+
+// readMHTML reads all the parts of the file. The page is the part given by the "start" parameter,
+// or the first HTML part
+func readMHTML(reader io.Reader) (*mhtmlArchive, error) {
+	message, err := mail.ReadMessage(bufio.NewReader(reader))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MHTML file: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, errors.New("invalid MHTML file: not a multipart message")
+	}
+
+	saved := &mhtmlArchive{files: make(map[string]mhtmlFile)}
+	start := strings.Trim(params["start"], "<>")
+	pageKey, firstHTML := "", ""
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid MHTML file: %w", err)
+		}
+		var body io.Reader = part
+		if strings.EqualFold(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding")), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		content, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MHTML part: %w", err)
+		}
+		file := mhtmlFile{content: content, contentType: part.Header.Get("Content-Type")}
+
+		keys := make([]string, 0, 2)
+		if location := part.Header.Get("Content-Location"); location != "" {
+			keys = append(keys, key(location))
+		}
+		id := strings.Trim(part.Header.Get("Content-ID"), "<> ")
+		if id != "" {
+			keys = append(keys, "cid:"+id)
+		}
+		for _, name := range keys {
+			if _, found := saved.files[name]; !found {
+				saved.files[name] = file
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if start != "" && id == start {
+			pageKey = keys[0]
+		}
+		if firstHTML == "" && isHTML(file.contentType) {
+			firstHTML = keys[0]
+		}
+	}
+	if pageKey == "" {
+		pageKey = firstHTML
+	}
+	if pageKey == "" {
+		return nil, errors.New("no HTML page in the MHTML file")
+	}
+
+	page := saved.files[pageKey]
+	saved.page = Page{Source: page.content, ContentType: page.contentType}
+	location := message.Header.Get("Snapshot-Content-Location")
+	if location == "" && !strings.HasPrefix(pageKey, "cid:") {
+		location = pageKey
+	}
+	saved.page.URL = pageURL(location)
+	return saved, nil
+}
+
+// Page returns the main HTML part
+func (a *mhtmlArchive) Page() Page {
+	return a.page
+}
+
+// Open returns the part of a link, found by its Content-Location, or its Content-ID for a "cid:" link
+func (a *mhtmlArchive) Open(link string) (io.ReadCloser, string, error) {
+	file, found := a.files[key(link)]
+	if !found {
+		return nil, "", notFound(link)
+	}
+	return ioutil.NopCloser(bytes.NewReader(file.content)), file.contentType, nil
+}
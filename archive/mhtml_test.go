@@ -0,0 +1,93 @@
+package archive
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMHTML = "From: <Saved by Blink>\r\n" +
+	"Snapshot-Content-Location: https://example.com/galleries/summer/\r\n" +
+	"Subject: Summer\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/related;\r\n" +
+	"\ttype=\"text/html\";\r\n" +
+	"\tboundary=\"----MultipartBoundary--abc----\"\r\n" +
+	"\r\n" +
+	"------MultipartBoundary--abc----\r\n" +
+	"Content-Type: text/html\r\n" +
+	"Content-ID: <frame-1@mhtml.blink>\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"Content-Location: https://example.com/galleries/summer/\r\n" +
+	"\r\n" +
+	"<html><body><img src=3D\"https://example.com/photos/beach.jpg\">=\r\n" +
+	"</body></html>\r\n" +
+	"------MultipartBoundary--abc----\r\n" +
+	"Content-Type: image/jpeg\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"Content-Location: https://example.com/photos/beach.jpg\r\n" +
+	"\r\n" +
+	"YmVh\r\n" +
+	"Y2g=\r\n" +
+	"------MultipartBoundary--abc----\r\n" +
+	"Content-Type: image/png\r\n" +
+	"Content-Transfer-Encoding: binary\r\n" +
+	"Content-ID: <sea@mhtml.blink>\r\n" +
+	"\r\n" +
+	"sea\r\n" +
+	"------MultipartBoundary--abc------\r\n"
+
+func TestReadMHTML(t *testing.T) {
+	saved, err := readMHTML(strings.NewReader(testMHTML))
+	require.NoError(t, err)
+
+	page := saved.Page()
+	assert.Equal(t, `<html><body><img src="https://example.com/photos/beach.jpg"></body></html>`, string(page.Source))
+	assert.Equal(t, "text/html", page.ContentType)
+	require.NotNil(t, page.URL)
+	assert.Equal(t, "https://example.com/galleries/summer/", page.URL.String())
+
+	testData := []struct {
+		link        string
+		content     string
+		contentType string
+	}{
+		{"https://example.com/photos/beach.jpg", "beach", "image/jpeg"},
+		{"https://example.com/photos/beach.jpg#zoom", "beach", "image/jpeg"},
+		{"cid:sea@mhtml.blink", "sea", "image/png"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.link, func(t *testing.T) {
+			reader, contentType, err := saved.Open(testItem.link)
+			require.NoError(t, err)
+			content, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.content, string(content))
+			assert.Equal(t, testItem.contentType, contentType)
+		})
+	}
+
+	_, _, err = saved.Open("https://example.com/photos/sand.jpg")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReadMHTMLErrors(t *testing.T) {
+	testData := []struct {
+		name   string
+		source string
+	}{
+		{"not mime", "<html></html>"},
+		{"not multipart", "MIME-Version: 1.0\r\nContent-Type: text/html\r\n\r\n<html></html>"},
+		{"no page", "MIME-Version: 1.0\r\nContent-Type: multipart/related; boundary=b\r\n\r\n--b\r\nContent-Type: image/png\r\nContent-Location: https://example.com/a.png\r\n\r\npng\r\n--b--\r\n"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			_, err := readMHTML(strings.NewReader(testItem.source))
+			assert.Error(t, err)
+		})
+	}
+}
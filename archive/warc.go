@@ -0,0 +1,275 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// warcArchive is a WARC file written by a crawler (.warc, or .warc.gz with a gzip member per record).
+// Only the position of the records is kept in memory: the files are read again from the WARC file when opened
+type warcArchive struct {
+	filename   string
+	compressed bool
+	records    map[string]warcRecord
+	page       Page
+}
+
+// warcRecord is the position of a record: the offset of the record in the file,
+// or for a compressed file the offset of its gzip member and the offset of the record in the member
+type warcRecord struct {
+	offset      int64
+	inner       int64
+	response    bool
+	contentType string
+}
+
+// countingReader counts the bytes read, to know the offset of the records
+type countingReader struct {
+	reader *bufio.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.reader.ReadByte()
+	if err == nil {
+		c.count++
+	}
+	return b, err
+}
+
+func (c *countingReader) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	c.count += int64(len(line))
+	return line, err
+}
+
+func openWARC(filename string) (*warcArchive, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	saved := &warcArchive{
+		filename: filename,
+		records:  make(map[string]warcRecord),
+	}
+	pageKey, err := saved.index(file)
+	if err != nil {
+		return nil, err
+	}
+	if pageKey == "" {
+		return nil, errors.New("no HTML page in the WARC file")
+	}
+	reader, contentType, err := saved.Open(pageKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the page of the WARC file: %w", err)
+	}
+	defer reader.Close()
+	source, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the page of the WARC file: %w", err)
+	}
+	saved.page = Page{Source: source, ContentType: contentType, URL: pageURL(pageKey)}
+	return saved, nil
+}
+
+// index reads the position of all the records of the file, and returns the URL of the first HTML page
+func (a *warcArchive) index(file io.Reader) (string, error) {
+	counter := &countingReader{reader: bufio.NewReader(file)}
+	magic, _ := counter.reader.Peek(2)
+	a.compressed = bytes.Equal(magic, []byte{0x1f, 0x8b})
+	if !a.compressed {
+		return a.readRecords(counter, func(start int64) warcRecord {
+			return warcRecord{offset: start}
+		})
+	}
+
+	pageKey := ""
+	member := counter.count
+	decompressor, err := gzip.NewReader(counter)
+	for err == nil {
+		decompressor.Multistream(false)
+		var key string
+		key, err = a.readRecords(&countingReader{reader: bufio.NewReader(decompressor)}, func(start int64) warcRecord {
+			return warcRecord{offset: member, inner: start}
+		})
+		if pageKey == "" {
+			pageKey = key
+		}
+		if err != nil {
+			break
+		}
+		member = counter.count
+		err = decompressor.Reset(counter)
+	}
+	if err != io.EOF {
+		return "", fmt.Errorf("invalid WARC file: %w", err)
+	}
+	return pageKey, nil
+}
+
+// readRecords indexes the response and resource records of a WARC stream, keeping the first capture of each URL.
+// It returns the URL of the first HTML page
+func (a *warcArchive) readRecords(reader *countingReader, position func(int64) warcRecord) (string, error) {
+	pageKey := ""
+	for {
+		start := reader.count
+		line, err := reader.readLine()
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			return pageKey, nil
+		}
+		if err != nil {
+			return pageKey, err
+		}
+		if strings.TrimSpace(line) == "" {
+			// end of the previous record
+			continue
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			return pageKey, fmt.Errorf("invalid WARC record at offset %d", start)
+		}
+		header, err := readWARCHeader(reader)
+		if err != nil {
+			return pageKey, err
+		}
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil || length < 0 {
+			return pageKey, fmt.Errorf("invalid length of WARC record at offset %d", start)
+		}
+		block := io.LimitReader(reader, length)
+
+		record := position(start)
+		link := key(strings.Trim(header.Get("WARC-Target-URI"), "<> "))
+		valid := false
+		switch header.Get("WARC-Type") {
+		case "response":
+			record.response = true
+			record.contentType, valid = responseType(block)
+		case "resource":
+			record.contentType, valid = header.Get("Content-Type"), true
+		}
+		if _, found := a.records[link]; valid && !found && link != "" {
+			a.records[link] = record
+			if pageKey == "" && isHTML(record.contentType) {
+				pageKey = link
+			}
+		}
+		if _, err := io.Copy(ioutil.Discard, block); err != nil {
+			return pageKey, err
+		}
+	}
+}
+
+// responseType reads the HTTP response of a record, and returns its content type when it's a success
+func responseType(block io.Reader) (string, bool) {
+	response, err := http.ReadResponse(bufio.NewReader(block), nil)
+	if err != nil || response.StatusCode < 200 || response.StatusCode >= 300 {
+		return "", false
+	}
+	return response.Header.Get("Content-Type"), true
+}
+
+// readWARCHeader reads the named fields of a record, up to the empty line
+func readWARCHeader(reader *countingReader) (textproto.MIMEHeader, error) {
+	buffer := &bytes.Buffer{}
+	for {
+		line, err := reader.readLine()
+		if err != nil {
+			return nil, fmt.Errorf("invalid WARC header: %w", err)
+		}
+		buffer.WriteString(line)
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+	}
+	return textproto.NewReader(bufio.NewReader(buffer)).ReadMIMEHeader()
+}
+
+// Page returns the first HTML page captured
+func (a *warcArchive) Page() Page {
+	return a.page
+}
+
+// Open reads the payload of the record of a link: the body of the HTTP response, or the content of a resource
+func (a *warcArchive) Open(link string) (io.ReadCloser, string, error) {
+	record, found := a.records[key(link)]
+	if !found {
+		return nil, "", notFound(link)
+	}
+	file, err := os.Open(a.filename)
+	if err != nil {
+		return nil, "", err
+	}
+	body, err := a.payload(file, record)
+	if err != nil {
+		file.Close()
+		return nil, "", fmt.Errorf("cannot read %s in the WARC file: %w", link, err)
+	}
+	return &payload{Reader: body, Closer: file}, record.contentType, nil
+}
+
+// payload seeks the record in the file and returns the reader of its payload
+func (a *warcArchive) payload(file *os.File, record warcRecord) (io.Reader, error) {
+	if _, err := file.Seek(record.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var reader io.Reader = file
+	if a.compressed {
+		decompressor, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		decompressor.Multistream(false)
+		reader = decompressor
+	}
+	counter := &countingReader{reader: bufio.NewReader(reader)}
+	if _, err := counter.reader.Discard(int(record.inner)); err != nil {
+		return nil, err
+	}
+	if _, err := counter.readLine(); err != nil {
+		return nil, err
+	}
+	header, err := readWARCHeader(counter)
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	block := io.LimitReader(counter, length)
+	if !record.response {
+		return block, nil
+	}
+	// the HTTP response as it was received: the body can be chunked or compressed
+	response, err := http.ReadResponse(bufio.NewReader(block), nil)
+	if err != nil {
+		return nil, err
+	}
+	if response.Header.Get("Content-Encoding") == "gzip" {
+		return gzip.NewReader(response.Body)
+	}
+	return response.Body, nil
+}
+
+// payload reads a file of the archive, and closes the WARC file
+type payload struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWARCRecord(recordType, uri, contentType string, block []byte) []byte {
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "WARC/1.0\r\nWARC-Type: %s\r\nWARC-Target-URI: %s\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n",
+		recordType, uri, contentType, len(block))
+	buffer.Write(block)
+	buffer.WriteString("\r\n\r\n")
+	return buffer.Bytes()
+}
+
+func warcResponse(uri, status, headers string, body []byte) []byte {
+	block := append([]byte(fmt.Sprintf("HTTP/1.1 %s\r\n%s\r\n", status, headers)), body...)
+	return newWARCRecord("response", uri, "application/http; msgtype=response", block)
+}
+
+func gzipped(t *testing.T, content []byte) []byte {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	_, err := writer.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func testWARCRecords(t *testing.T) [][]byte {
+	return [][]byte{
+		newWARCRecord("warcinfo", "", "application/warc-fields", []byte("software: test\r\n")),
+		newWARCRecord("request", "https://example.com/galleries/summer/", "application/http; msgtype=request", []byte("GET /galleries/summer/ HTTP/1.1\r\nHost: example.com\r\n\r\n")),
+		warcResponse("https://example.com/galleries/summer/", "200 OK", "Content-Type: text/html; charset=utf-8\r\n", []byte("<html></html>")),
+		warcResponse("https://example.com/photos/beach.jpg", "404 Not Found", "Content-Type: text/plain\r\n", []byte("not found")),
+		warcResponse("https://example.com/photos/beach.jpg", "200 OK", "Content-Type: image/jpeg\r\nContent-Encoding: gzip\r\nTransfer-Encoding: chunked\r\n",
+			[]byte(fmt.Sprintf("%x\r\n%s\r\n0\r\n\r\n", len(gzipped(t, []byte("beach"))), gzipped(t, []byte("beach"))))),
+		warcResponse("<https://example.com/photos/beach.jpg>", "200 OK", "Content-Type: image/jpeg\r\n", []byte("second capture")),
+		newWARCRecord("resource", "https://example.com/photos/sea.png", "image/png", []byte("sea")),
+	}
+}
+
+func TestOpenWARC(t *testing.T) {
+	records := testWARCRecords(t)
+	plain := bytes.Join(records, nil)
+	// one gzip member per record, except the last two records sharing a member
+	compressed := &bytes.Buffer{}
+	for _, record := range records[:len(records)-2] {
+		compressed.Write(gzipped(t, record))
+	}
+	compressed.Write(gzipped(t, bytes.Join(records[len(records)-2:], nil)))
+
+	testData := []struct {
+		name    string
+		content []byte
+	}{
+		{"archive.warc", plain},
+		{"archive.warc.gz", compressed.Bytes()},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), testItem.name)
+			require.NoError(t, os.WriteFile(filename, testItem.content, 0644))
+
+			saved, err := Open(filename)
+			require.NoError(t, err)
+			page := saved.Page()
+			assert.Equal(t, "<html></html>", string(page.Source))
+			assert.Equal(t, "text/html; charset=utf-8", page.ContentType)
+			require.NotNil(t, page.URL)
+			assert.Equal(t, "https://example.com/galleries/summer/", page.URL.String())
+
+			for link, expected := range map[string]string{
+				"https://example.com/photos/beach.jpg": "beach",
+				"https://example.com/photos/sea.png":   "sea",
+			} {
+				reader, _, err := saved.Open(link)
+				require.NoError(t, err)
+				content, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				require.NoError(t, reader.Close())
+				assert.Equal(t, expected, string(content))
+			}
+
+			_, _, err = saved.Open("https://example.com/photos/sand.jpg")
+			assert.ErrorIs(t, err, os.ErrNotExist)
+		})
+	}
+}
+
+func TestOpenWARCErrors(t *testing.T) {
+	testData := []struct {
+		name    string
+		content []byte
+	}{
+		{"no page", newWARCRecord("resource", "https://example.com/photos/sea.png", "image/png", []byte("sea"))},
+		{"not warc", []byte("<html></html>")},
+		{"truncated", newWARCRecord("resource", "https://example.com/", "text/html", []byte("<html></html>"))[:60]},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "archive.warc")
+			require.NoError(t, os.WriteFile(filename, testItem.content, 0644))
+			_, err := Open(filename)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// batchSource is a gallery of the -sources list
+type batchSource struct {
+	line    int
+	source  string
+	output  string
+	profile string
+}
+
+// downloadBatch downloads all the galleries of the -sources list, a few of them at the same time when configured to do so.
+// It returns the number of galleries that failed
+func downloadBatch(baseURL *url.URL, flags Flags, cfg *config.Configuration) int {
+	sources, err := loadSources(flags.Sources)
+	if err != nil {
+		log.Fatalf("Error: cannot read the list of sources: %v", err)
+	}
+	if len(sources) == 0 {
+		log.Println("No source found in the list!")
+		return 0
+	}
+	batchOutputs(sources, flags.Output)
+
+	results := make([]error, len(sources))
+	jobs := make(chan int, len(sources))
+	for index := range sources {
+		jobs <- index
+	}
+	close(jobs)
+	workers := flags.Galleries
+	if workers > len(sources) {
+		workers = len(sources)
+	}
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				log.Printf("Gallery %d/%d (line %d): %s", index+1, len(sources), sources[index].line, sources[index].source)
+				results[index] = downloadBatchSource(sources[index], baseURL, flags, cfg)
+				if results[index] != nil && !errors.Is(results[index], errNoPicture) {
+					log.Printf("Error: %s: %v", sources[index].source, results[index])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range results {
+		if err != nil {
+			failed++
+		}
+	}
+	log.Printf("Summary: %d galleries downloaded, %d failed", len(sources)-failed, failed)
+	for index, err := range results {
+		if err != nil {
+			log.Printf("  line %d: %s: %v", sources[index].line, sources[index].source, err)
+		}
+	}
+	return failed
+}
+
+// downloadBatchSource downloads a gallery of the list, with its own output folder and profile
+func downloadBatchSource(source batchSource, baseURL *url.URL, flags Flags, cfg *config.Configuration) error {
+	flags.Source = source.source
+	flags.Output = source.output
+	if flags.Galleries > 1 {
+		// the progress lines of the galleries are mixed up
+		flags.Gallery = fmt.Sprintf("line %d", source.line)
+	}
+	if source.profile != "" {
+		if flags.Type != scan.AutoDetect && flags.Type != scan.ConfigProfiles {
+			return fmt.Errorf("a profile cannot be used with gallery type %s", flags.Type)
+		}
+		if _, found := cfg.Profile(source.profile); !found {
+			return fmt.Errorf("profile %q not found in configuration", source.profile)
+		}
+		flags.Profile = source.profile
+	}
+	if err := os.MkdirAll(source.output, 0755); err != nil {
+		return fmt.Errorf("cannot create output directory: %w", err)
+	}
+	return downloadSource(baseURL, flags, cfg)
+}
+
+// batchOutputs sets the output folder of each source: a relative folder is created in the -output folder,
+// and a source without folder gets one named after its URL
+func batchOutputs(sources []batchSource, output string) {
+	folders := make(map[string]bool)
+	for _, source := range sources {
+		if source.output != "" {
+			// the default folders never take the name of a folder given in the list
+			folders[strings.ToLower(path.Clean(source.output))] = true
+		}
+	}
+	for index := range sources {
+		switch {
+		case sources[index].output == "":
+			name := fmt.Sprintf("gallery-%d", index+1)
+			if sourceURL, err := url.Parse(sources[index].source); err == nil {
+				name = pageFolderName(sourceURL, index+1)
+			}
+			sources[index].output = path.Join(output, uniqueFolderName(name, folders))
+		case !filepath.IsAbs(sources[index].output):
+			sources[index].output = path.Join(output, sources[index].output)
+		}
+	}
+}
+
+// loadSources reads the list of sources from a file, or from the standard input with "-"
+func loadSources(name string) ([]batchSource, error) {
+	if name == "-" {
+		return readSources(os.Stdin)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readSources(file)
+}
+
+// readSources reads one source per line, optionally followed by its output folder and its profile.
+// The fields are separated by tabs, or by spaces when the line has no tab. An output folder "-" keeps the default one.
+// Empty lines and lines starting with # are skipped
+func readSources(reader io.Reader) ([]batchSource, error) {
+	sources := make([]batchSource, 0)
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var fields []string
+		if strings.Contains(text, "\t") {
+			fields = strings.Split(text, "\t")
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+		} else {
+			fields = strings.Fields(text)
+		}
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: too many fields (source, output folder and profile expected)", line)
+		}
+		source := batchSource{line: line, source: fields[0]}
+		if len(fields) > 1 && fields[1] != "-" {
+			source.output = fields[1]
+		}
+		if len(fields) > 2 {
+			source.profile = fields[2]
+		}
+		sources = append(sources, source)
+	}
+	return sources, scanner.Err()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"gallery-downloader/download"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSources(t *testing.T) {
+	testData := []struct {
+		name     string
+		list     string
+		expected []batchSource
+		err      string
+	}{
+		{"empty", "", []batchSource{}, ""},
+		{"sources", "https://example.com/summer\nhttps://example.com/winter\n", []batchSource{
+			{line: 1, source: "https://example.com/summer"},
+			{line: 2, source: "https://example.com/winter"},
+		}, ""},
+		{"comments and empty lines", "# my galleries\n\n  https://example.com/summer  \n  # https://example.com/winter\n\t\nfile.html", []batchSource{
+			{line: 3, source: "https://example.com/summer"},
+			{line: 6, source: "file.html"},
+		}, ""},
+		{"spaces", "https://example.com/summer   summer-2020  Gallery", []batchSource{
+			{line: 1, source: "https://example.com/summer", output: "summer-2020", profile: "Gallery"},
+		}, ""},
+		{"tabs", "https://example.com/summer\tSummer 2020\tMy gallery\n/tmp/page.html\t /tmp/page ", []batchSource{
+			{line: 1, source: "https://example.com/summer", output: "Summer 2020", profile: "My gallery"},
+			{line: 2, source: "/tmp/page.html", output: "/tmp/page"},
+		}, ""},
+		{"default output", "https://example.com/summer - Gallery\nhttps://example.com/winter\t-\tGallery", []batchSource{
+			{line: 1, source: "https://example.com/summer", profile: "Gallery"},
+			{line: 2, source: "https://example.com/winter", profile: "Gallery"},
+		}, ""},
+		{"empty output between tabs", "https://example.com/summer\t\tGallery", []batchSource{
+			{line: 1, source: "https://example.com/summer", profile: "Gallery"},
+		}, ""},
+		{"windows lines", "https://example.com/summer summer\r\nhttps://example.com/winter\r\n", []batchSource{
+			{line: 1, source: "https://example.com/summer", output: "summer"},
+			{line: 2, source: "https://example.com/winter"},
+		}, ""},
+		{"too many fields", "https://example.com/summer\n\nhttps://example.com/winter winter Gallery extra", nil, "line 3: too many fields"},
+		{"too many tabs", "https://example.com/summer\tsummer\tGallery\textra", nil, "line 1: too many fields"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			sources, err := readSources(strings.NewReader(testItem.list))
+			if testItem.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testItem.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testItem.expected, sources)
+		})
+	}
+}
+
+func TestBatchOutputs(t *testing.T) {
+	testData := []struct {
+		name     string
+		sources  []batchSource
+		output   string
+		expected []string
+	}{
+		{
+			name: "named after the url",
+			sources: []batchSource{
+				{source: "https://example.com/galleries/summer"},
+				{source: "https://example.com/galleries/winter/index.html"},
+				{source: "https://example.com/"},
+			},
+			output:   "pictures",
+			expected: []string{"pictures/summer", "pictures/winter", "pictures/example.com"},
+		},
+		{
+			name: "unique names",
+			sources: []batchSource{
+				{source: "https://example.com/2020/summer"},
+				{source: "https://example.com/2021/summer"},
+				{source: "https://example.com/2022/Summer"},
+			},
+			output:   "pictures",
+			expected: []string{"pictures/summer", "pictures/summer (2)", "pictures/Summer (3)"},
+		},
+		{
+			name: "given names are kept",
+			sources: []batchSource{
+				{source: "https://example.com/2020/summer"},
+				{source: "https://example.com/2021/summer", output: "summer"},
+				{source: "https://example.com/2022/winter", output: "./Summer (2)/"},
+			},
+			output:   "pictures",
+			expected: []string{"pictures/summer (3)", "pictures/summer", "pictures/Summer (2)"},
+		},
+		{
+			name: "relative and absolute",
+			sources: []batchSource{
+				{source: "https://example.com/summer", output: "2020/summer"},
+				{source: "https://example.com/winter", output: "/tmp/winter"},
+			},
+			output:   "pictures",
+			expected: []string{"pictures/2020/summer", "/tmp/winter"},
+		},
+		{
+			name: "current folder",
+			sources: []batchSource{
+				{source: "https://example.com/summer"},
+				{source: "https://example.com/winter", output: "2020/winter"},
+			},
+			output:   "",
+			expected: []string{"summer", "2020/winter"},
+		},
+		{
+			name: "files",
+			sources: []batchSource{
+				{source: "saved/summer.html"},
+				{source: "saved/"},
+			},
+			output:   "pictures",
+			expected: []string{"pictures/summer", "pictures/saved"},
+		},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			batchOutputs(testItem.sources, testItem.output)
+			outputs := make([]string, 0, len(testItem.sources))
+			for _, source := range testItem.sources {
+				outputs = append(outputs, source.output)
+			}
+			assert.Equal(t, testItem.expected, outputs)
+		})
+	}
+}
+
+func TestProgressHandler(t *testing.T) {
+	output := &bytes.Buffer{}
+	log.SetOutput(output)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+
+	progress := download.Progress{Event: download.EventStart, URL: "https://example.com/001.jpg", FileID: 0, TotalFiles: 2}
+	progressHandler(Flags{})(progress)
+	progressHandler(Flags{Gallery: "line 3"})(progress)
+	assert.Equal(t, "(1/2) download starting: 'https://example.com/001.jpg'\n[line 3] (1/2) download starting: 'https://example.com/001.jpg'\n", output.String())
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"fmt"
+	"gallery-downloader/scan"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/xpath"
+)
+
+type namedParser struct {
+	name   string
+	parser *Parser
+}
+
+// parsers returns all the parsers of the profile
+func (p *Profile) parsers() []namedParser {
+	return []namedParser{
+		{"generator", &p.DetectGenerator},
+		{"gallery detection", &p.DetectGallery},
+		{"image detection", &p.DetectImage},
+		{"next page detection", &p.Pagination.Next},
+		{"stop condition", &p.Pagination.Stop},
+		{"album link detection", &p.Albums.Link},
+		{"album title", &p.Albums.Title},
+	}
+}
+
+// resolveFiles makes the script files of the parsers relative to dir
+func (p *Profile) resolveFiles(dir string) {
+	for _, item := range p.parsers() {
+		if item.parser.File != "" && !filepath.IsAbs(item.parser.File) {
+			item.parser.File = filepath.Join(dir, item.parser.File)
+		}
+	}
+}
+
+// compile builds the matchers of all the parsers of the profile, so they're only compiled once
+func (p *Profile) compile() error {
+	for _, item := range p.parsers() {
+		matcher, err := item.parser.compile()
+		if err != nil {
+			return fmt.Errorf("profile %s: cannot compile %s %q: %w", p.Name, item.name, item.parser.Match, err)
+		}
+		item.parser.matcher = matcher
+	}
+	for i, rule := range p.Rewrite {
+		pattern, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return fmt.Errorf("profile %s: cannot compile rewrite rule %q: %w", p.Name, rule.Match, err)
+		}
+		p.Rewrite[i].pattern = pattern
+	}
+	return p.compilePatterns()
+}
+
+// compilePatterns compiles the host and path patterns and the media scope of the profile
+func (p *Profile) compilePatterns() error {
+	var err error
+	p.hostPatterns, err = compilePatterns(p.Hosts, compileHostPattern)
+	if err != nil {
+		return fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	p.pathPatterns, err = compilePatterns(p.Paths, compilePathPattern)
+	if err != nil {
+		return fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	p.Albums.hostPatterns, err = compilePatterns(p.Albums.Hosts, compileHostPattern)
+	if err != nil {
+		return fmt.Errorf("profile %s: album hosts: %w", p.Name, err)
+	}
+	scope, err := parseMediaScope(p.MediaScope)
+	if err != nil {
+		return fmt.Errorf("profile %s: cannot compile media scope %q: %w", p.Name, p.MediaScope, err)
+	}
+	p.scope = &scope
+	return nil
+}
+
+// ScanConfig returns the compiled matchers of the profile
+func (p Profile) ScanConfig() (scan.Config, error) {
+	generator, err := p.DetectGenerator.Matcher()
+	if err != nil {
+		return scan.Config{}, fmt.Errorf("profile %s: cannot compile generator %q: %w", p.Name, p.DetectGenerator.Match, err)
+	}
+	gallery, err := p.DetectGallery.Matcher()
+	if err != nil {
+		return scan.Config{}, fmt.Errorf("profile %s: cannot compile gallery detection %q: %w", p.Name, p.DetectGallery.Match, err)
+	}
+	image, err := p.DetectImage.Matcher()
+	if err != nil {
+		return scan.Config{}, fmt.Errorf("profile %s: cannot compile image detection %q: %w", p.Name, p.DetectImage.Match, err)
+	}
+	if image == nil {
+		return scan.Config{}, fmt.Errorf("profile %s: missing detectImage", p.Name)
+	}
+	scope, err := p.mediaScope()
+	if err != nil {
+		return scan.Config{}, fmt.Errorf("profile %s: cannot compile media scope %q: %w", p.Name, p.MediaScope, err)
+	}
+	return scan.Config{
+		Name:            p.Name,
+		DetectGenerator: generator,
+		DetectGallery:   gallery,
+		DetectImage:     image,
+		Extensions:      p.Extensions,
+		Media:           p.Media,
+		MediaScope:      scope,
+	}, nil
+}
+
+// mediaScope returns the selector of the media scope, or nil when the media are found in the whole page
+func (p Profile) mediaScope() (cascadia.Sel, error) {
+	if p.scope != nil {
+		return *p.scope, nil
+	}
+	return parseMediaScope(p.MediaScope)
+}
+
+func parseMediaScope(scope string) (cascadia.Sel, error) {
+	if strings.TrimSpace(scope) == "" {
+		return nil, nil
+	}
+	return cascadia.Parse(scope)
+}
+
+// Matcher returns the matcher of the parser: the one compiled when loading the configuration,
+// or a new one for a parser created in code. It returns nil when the type of parser is unknown or not set
+func (p Parser) Matcher() (scan.Matcher, error) {
+	if p.matcher != nil {
+		return p.matcher, nil
+	}
+	return p.compile()
+}
+
+// Pattern returns the regular expression of the rule: the one compiled when loading the configuration,
+// or a new one for a rule created in code
+func (r RewriteRule) Pattern() (*regexp.Regexp, error) {
+	if r.pattern != nil {
+		return r.pattern, nil
+	}
+	return regexp.Compile(r.Match)
+}
+
+func (p Parser) compile() (scan.Matcher, error) {
+	matcherType := strings.ToLower(p.Type)
+
+	if strings.HasPrefix(matcherType, "regex") {
+		pattern, err := regexp.Compile(p.Match)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewRegexpMatcher(pattern), nil
+	}
+
+	if strings.HasPrefix(matcherType, "json") {
+		return p.compileJSON()
+	}
+
+	// needs to be checked after "json"
+	if strings.HasPrefix(matcherType, "javascript") || strings.HasPrefix(matcherType, "js") {
+		return p.compileJavaScript()
+	}
+
+	if strings.HasPrefix(matcherType, "script") || strings.HasPrefix(matcherType, "starlark") {
+		return p.compileScript()
+	}
+
+	if strings.HasPrefix(matcherType, "xpath") {
+		expr, err := xpath.Compile(p.Match)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewXPathMatcher(expr, p.Attribute...), nil
+	}
+
+	// needs to be checked before "css" selectors
+	if strings.HasPrefix(matcherType, "background") || strings.HasPrefix(matcherType, "css-background") {
+		sel, err := cascadia.Parse(p.Match)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewBackgroundMatcher(sel), nil
+	}
+
+	if strings.HasPrefix(matcherType, "sel") || strings.HasPrefix(matcherType, "css") {
+		sel, err := cascadia.Parse(p.Match)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewSelectorMatcher(sel, p.Attribute...), nil
+	}
+	return nil, nil
+}
+
+func (p Parser) compileJSON() (scan.Matcher, error) {
+	path, err := scan.CompileJSONPath(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.ToLower(p.Locate), "regex") {
+		pattern, err := regexp.Compile(p.Match)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewJSONRegexpMatcher(pattern, path), nil
+	}
+	sel, err := cascadia.Parse(p.Match)
+	if err != nil {
+		return nil, err
+	}
+	return scan.NewJSONSelectorMatcher(sel, path), nil
+}
+
+func (p Parser) compileScript() (scan.Matcher, error) {
+	name := "inline"
+	source := []byte(p.Match)
+	if p.File != "" {
+		name = p.File
+		var err error
+		source, err = os.ReadFile(p.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scan.NewScriptMatcher(name, source, scan.ScriptLimits{
+		Timeout:   time.Duration(p.Timeout) * time.Millisecond,
+		MaxSteps:  p.MaxSteps,
+		MaxMemory: uint64(p.MaxMemory) << 20,
+	})
+}
+
+// compileJavaScript reads the literals from the scripts of the page ("script" when no selector is given),
+// or from the position located by a regular expression
+func (p Parser) compileJavaScript() (scan.Matcher, error) {
+	path, err := scan.CompileJSONPath(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.ToLower(p.Locate), "regex") {
+		pattern, err := regexp.Compile(p.Match)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewJSRegexpMatcher(pattern, path), nil
+	}
+	match := p.Match
+	if strings.TrimSpace(match) == "" {
+		match = "script"
+	}
+	sel, err := cascadia.Parse(match)
+	if err != nil {
+		return nil, err
+	}
+	return scan.NewJSSelectorMatcher(sel, path), nil
+}
+
+// StreamMatcher returns a matcher finding the pictures while the page is still downloading.
+// Only the "selector" parsers using a simple selector can be streamed
+func (p Parser) StreamMatcher() (*scan.StreamMatcher, error) {
+	matcherType := strings.ToLower(p.Type)
+	if strings.HasPrefix(matcherType, "css-background") || !(strings.HasPrefix(matcherType, "sel") || strings.HasPrefix(matcherType, "css")) {
+		return nil, scan.ErrNotStreamable
+	}
+	return scan.NewStreamMatcher(p.Match, p.Attribute...)
+}
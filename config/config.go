@@ -2,14 +2,29 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"gallery-downloader/scan"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
 )
 
 // Configuration contains all configuration from JSON file
 type Configuration struct {
 	Browser  Browser   `json:"browser"`
 	Profiles []Profile `json:"profiles"`
+	// Extensions of the pictures to download, for all the profiles not declaring their own list
+	Extensions []string `json:"extensions"`
+	// Media are the kinds of media (video, audio, poster) to download with the pictures, for all the profiles not declaring their own list
+	Media []string `json:"media"`
+	// FrameHosts are the hosts of the frames followed with the -frames flag, in addition to the site of the page
+	FrameHosts []string `json:"frameHosts"`
+	// frameHostPatterns are compiled when loading the configuration
+	frameHostPatterns []*regexp.Regexp
 }
 
 // Browser contains all browser configuration
@@ -29,22 +44,132 @@ type Group struct {
 
 // Profile contains the type of gallery and how to parse the images
 type Profile struct {
-	Priority        int    `json:"priority"`
-	Name            string `json:"name"`
-	DetectGenerator Parser `json:"detectGenerator"`
-	DetectGallery   Parser `json:"detectGallery"`
-	DetectImage     Parser `json:"detectImage"`
-	MinImages       int    `json:"minImages"`
-	MinWait         int    `json:"minWait"`
-	MaxWait         int    `json:"maxWait"`
-	Parallel        int    `json:"parallel"`
-}
-
-// Parser contains parsing data (regex or CSS selector)
+	Priority        int        `json:"priority"`
+	Name            string     `json:"name"`
+	DetectGenerator Parser     `json:"detectGenerator"`
+	DetectGallery   Parser     `json:"detectGallery"`
+	DetectImage     Parser     `json:"detectImage"`
+	MinImages       int        `json:"minImages"`
+	MinWait         int        `json:"minWait"`
+	MaxWait         int        `json:"maxWait"`
+	Parallel        int        `json:"parallel"`
+	Pagination      Pagination `json:"pagination"`
+	Albums          Albums     `json:"albums"`
+	// Rewrite rules are applied in order to each picture URL before downloading it
+	Rewrite []RewriteRule `json:"rewrite"`
+	// RewriteFallback downloads the original URL when the rewritten one is not found
+	RewriteFallback bool `json:"rewriteFallback"`
+	// Extensions of the pictures to keep, like ["jpg", "png", "webp"]. All pictures are kept when empty
+	Extensions []string `json:"extensions"`
+	// Media are the kinds of media (video, audio, poster) to download with the pictures.
+	// The list of the configuration is used when not set, and an empty list disables them
+	Media []string `json:"media"`
+	// MediaScope is a CSS selector of the elements holding the media of the gallery, like "div.gallery".
+	// The media of the whole page are downloaded when empty
+	MediaScope string `json:"mediaScope"`
+	// Hosts where this profile is known to work (see HostMatches for the syntax)
+	Hosts []string `json:"hosts"`
+	// Paths of the pages where this profile is known to work (see PathMatches for the syntax)
+	Paths []string `json:"paths"`
+	// Exclusive profiles are the only ones tried on the pages matching their hosts and paths.
+	// Otherwise the profiles matching the page are simply tried first
+	Exclusive bool `json:"exclusive"`
+	// the patterns below are compiled when loading the configuration
+	hostPatterns []*regexp.Regexp
+	pathPatterns []*regexp.Regexp
+	scope        *cascadia.Sel
+}
+
+// RewriteRule replaces the parts of a URL matching a regular expression.
+// The replacement can contain $1 (or ${1}) to insert the text of the submatches
+type RewriteRule struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+	// pattern is compiled when loading the configuration
+	pattern *regexp.Regexp
+}
+
+// Pagination describes how to find the next pages of a gallery split over many pages
+type Pagination struct {
+	// Next finds the link to the next page
+	Next Parser `json:"next"`
+	// Pattern builds the URL of the next pages when there's no link to follow: {page} is replaced by the page number.
+	// The URL can be relative to the first page, like "?page={page}"
+	Pattern string `json:"pattern"`
+	// Start is the number of the second page when using a pattern (default is 2). It can be 0 for galleries counting from 0
+	Start *int `json:"start"`
+	// MaxPages is the maximum number of pages to read, including the first one
+	MaxPages int `json:"maxPages"`
+	// Stop marks the last page: the pagination stops after reading a page where it matches
+	Stop Parser `json:"stop"`
+}
+
+// IsSet returns true when the gallery is configured to follow the next pages
+func (p Pagination) IsSet() bool {
+	return p.Next.Type != "" || p.Pattern != ""
+}
+
+// Albums describes an index page listing albums, each album being a gallery
+type Albums struct {
+	// Link finds the links to the albums
+	Link Parser `json:"link"`
+	// Title finds the title of an album on its page. The <title> of the page is used by default
+	Title Parser `json:"title"`
+	// MaxDepth is the number of index levels to follow (default is 1: the albums are galleries)
+	MaxDepth int `json:"maxDepth"`
+	// Hosts is the list of other hosts allowed when crawling: "photos.example.com" or ".example.com" for all subdomains.
+	// The host of the index page is always allowed
+	Hosts []string `json:"hosts"`
+	// hostPatterns are compiled when loading the configuration
+	hostPatterns []*regexp.Regexp
+}
+
+// IsSet returns true when the profile can detect an index of albums
+func (a Albums) IsSet() bool {
+	return a.Link.Type != ""
+}
+
+// Parser contains parsing data (regex, CSS selector, XPath or JSON)
 type Parser struct {
-	Type      string `json:"type"`
-	Match     string `json:"match"`
-	Attribute string `json:"attribute"`
+	Type      string     `json:"type"`
+	Match     string     `json:"match"`
+	Attribute Attributes `json:"attribute"`
+	// Locate is how a "json" parser finds the JSON document: "selector" (default) or "regexp"
+	Locate string `json:"locate"`
+	// Path is the JSONPath expression used by a "json" parser to extract the pictures
+	Path string `json:"path"`
+	// File is the script run by a "script" parser, relative to the configuration file.
+	// A short script can be written in Match instead
+	File string `json:"file"`
+	// Timeout (in milliseconds), MaxSteps and MaxMemory (in MB) limit each run of a "script" parser
+	Timeout   int    `json:"timeout"`
+	MaxSteps  uint64 `json:"maxSteps"`
+	MaxMemory int    `json:"maxMemory"`
+	// matcher is compiled when loading the configuration
+	matcher scan.Matcher
+}
+
+// Attributes is an ordered list of HTML attributes to read the picture from.
+// The first attribute holding a usable value wins, so lazy-loading galleries can
+// be described as ["data-src", "data-original", "src"]
+type Attributes []string
+
+// UnmarshalJSON accepts either a single attribute name or a list of attribute names
+func (a *Attributes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = nil
+		if single != "" {
+			*a = Attributes{single}
+		}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
 }
 
 // newConfiguration creates an empty configuration object
@@ -58,10 +183,11 @@ func LoadFileConfiguration(fileName string) (*Configuration, error) {
 	if err != nil {
 		return nil, err
 	}
-	return loadConfiguration(file)
+	return loadConfiguration(file, filepath.Dir(fileName))
 }
 
-func loadConfiguration(reader io.ReadCloser) (*Configuration, error) {
+// loadConfiguration reads the configuration. The script files are relative to dir
+func loadConfiguration(reader io.ReadCloser, dir string) (*Configuration, error) {
 	defer reader.Close()
 	decoder := json.NewDecoder(reader)
 	cfg := newConfiguration()
@@ -69,5 +195,89 @@ func loadConfiguration(reader io.ReadCloser) (*Configuration, error) {
 	if err != nil {
 		return nil, err
 	}
+	for i := range cfg.Profiles {
+		if len(cfg.Profiles[i].Extensions) == 0 {
+			cfg.Profiles[i].Extensions = cfg.Extensions
+		}
+		if cfg.Profiles[i].Media == nil {
+			cfg.Profiles[i].Media = cfg.Media
+		}
+	}
+	err = cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+	cfg.frameHostPatterns, err = compilePatterns(cfg.FrameHosts, compileHostPattern)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cfg.Profiles {
+		cfg.Profiles[i].resolveFiles(dir)
+		err = cfg.Profiles[i].compile()
+		if err != nil {
+			return nil, err
+		}
+	}
 	return cfg, nil
 }
+
+// Profile returns the profile with this name (case insensitive)
+func (c *Configuration) Profile(name string) (Profile, bool) {
+	for _, profile := range c.Profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+// validate checks the host and path patterns of the profiles
+func (c *Configuration) validate() error {
+	if err := validateMedia(c.Media); err != nil {
+		return err
+	}
+	for _, pattern := range c.FrameHosts {
+		if _, err := compileHostPattern(pattern); err != nil {
+			return fmt.Errorf("invalid frame host pattern %q: %w", pattern, err)
+		}
+	}
+	for _, profile := range c.Profiles {
+		for _, pattern := range profile.Hosts {
+			if _, err := compileHostPattern(pattern); err != nil {
+				return fmt.Errorf("profile %s: invalid host pattern %q: %w", profile.Name, pattern, err)
+			}
+		}
+		for _, pattern := range profile.Albums.Hosts {
+			if _, err := compileHostPattern(pattern); err != nil {
+				return fmt.Errorf("profile %s: invalid album host pattern %q: %w", profile.Name, pattern, err)
+			}
+		}
+		if err := validateMedia(profile.Media); err != nil {
+			return fmt.Errorf("profile %s: %w", profile.Name, err)
+		}
+		if _, err := parseMediaScope(profile.MediaScope); err != nil {
+			return fmt.Errorf("profile %s: invalid media scope %q: %w", profile.Name, profile.MediaScope, err)
+		}
+		for _, pattern := range profile.Paths {
+			if _, err := compilePathPattern(pattern); err != nil {
+				return fmt.Errorf("profile %s: invalid path pattern %q: %w", profile.Name, pattern, err)
+			}
+		}
+		for _, rule := range profile.Rewrite {
+			if _, err := regexp.Compile(rule.Match); err != nil {
+				return fmt.Errorf("profile %s: invalid rewrite rule %q: %w", profile.Name, rule.Match, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateMedia checks the kinds of media
+func validateMedia(kinds []string) error {
+	for _, kind := range kinds {
+		if !scan.IsMediaKind(kind) {
+			return fmt.Errorf("unknown kind of media %q (expected one of %s)", kind, strings.Join(scan.MediaKinds, ", "))
+		}
+	}
+	return nil
+}
@@ -2,13 +2,23 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
+	"gallery-downloader/scan"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// TestMain lets the test binary run the scripts of the tests in a process of their own
+func TestMain(m *testing.M) {
+	scan.ServeScript()
+	os.Exit(m.Run())
+}
+
 var configSource = `{
 	"browser": {
 		"default": {
@@ -90,7 +100,7 @@ func TestLoadConfiguration(t *testing.T) {
 	var err error
 
 	reader := ioutil.NopCloser(bytes.NewReader([]byte(configSource)))
-	cfg, err := loadConfiguration(reader)
+	cfg, err := loadConfiguration(reader, "")
 	if err != nil {
 		t.Fatal(err)
 		return
@@ -120,3 +130,181 @@ func TestLoadConfiguration(t *testing.T) {
 
 	assert.NotEmpty(t, cfg.Profiles)
 }
+
+func TestLoadParserAttribute(t *testing.T) {
+	testData := []struct {
+		source   string
+		expected Attributes
+	}{
+		{`{}`, nil},
+		{`{"attribute": ""}`, nil},
+		{`{"attribute": "src"}`, Attributes{"src"}},
+		{`{"attribute": ["data-src", "data-original", "src"]}`, Attributes{"data-src", "data-original", "src"}},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.source, func(t *testing.T) {
+			parser := Parser{}
+			err := json.Unmarshal([]byte(testItem.source), &parser)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.expected, parser.Attribute)
+		})
+	}
+}
+
+func TestLoadConfigurationExtensions(t *testing.T) {
+	source := `{
+		"extensions": ["jpg", "png"],
+		"profiles": [
+			{"name": "global"},
+			{"name": "own", "extensions": ["webp"]}
+		]
+	}`
+	cfg, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), "")
+	require.NoError(t, err)
+	require.Len(t, cfg.Profiles, 2)
+	assert.Equal(t, []string{"jpg", "png"}, cfg.Profiles[0].Extensions)
+	assert.Equal(t, []string{"webp"}, cfg.Profiles[1].Extensions)
+}
+
+func TestLoadConfigurationCompilesParsers(t *testing.T) {
+	source := `{
+		"profiles": [
+			{
+				"name": "compiled",
+				"detectGallery": {"type": "selector", "match": "ul.gallery"},
+				"detectImage": {"type": "regexp", "match": "href=\"([^\"]+\\.jpg)\""}
+			}
+		]
+	}`
+	cfg, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), "")
+	require.NoError(t, err)
+	require.Len(t, cfg.Profiles, 1)
+
+	profile := cfg.Profiles[0]
+	assert.NotNil(t, profile.DetectGallery.matcher)
+	assert.NotNil(t, profile.DetectImage.matcher)
+	assert.Nil(t, profile.DetectGenerator.matcher)
+
+	// the compiled matchers are shared, not compiled again
+	first, err := profile.ScanConfig()
+	require.NoError(t, err)
+	second, err := profile.ScanConfig()
+	require.NoError(t, err)
+	assert.Same(t, first.DetectImage, second.DetectImage)
+	assert.Nil(t, first.DetectGenerator)
+}
+
+func TestLoadConfigurationInvalidParser(t *testing.T) {
+	source := `{"profiles": [{"name": "invalid", "detectImage": {"type": "regexp", "match": "(unclosed"}}]}`
+	_, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile invalid: cannot compile image detection")
+}
+
+func TestParserMatcherTypes(t *testing.T) {
+	testData := []struct {
+		parser   Parser
+		expected interface{}
+	}{
+		{Parser{Type: "regexp", Match: "<img"}, &scan.RegexpMatcher{}},
+		{Parser{Type: "selector", Match: "img", Attribute: Attributes{"src"}}, &scan.SelectorMatcher{}},
+		{Parser{Type: "css-background", Match: "div"}, &scan.BackgroundMatcher{}},
+		{Parser{Type: "json", Match: "script", Path: "$..url"}, &scan.JSONMatcher{}},
+		{Parser{Type: "javascript", Path: "$..src"}, &scan.JSONMatcher{}},
+		{Parser{Type: "xpath", Match: "//img/@src"}, &scan.XPathMatcher{}},
+		{Parser{Type: "script", Match: "def find(page): return []"}, &scan.ScriptMatcher{}},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.parser.Type, func(t *testing.T) {
+			matcher, err := testItem.parser.Matcher()
+			require.NoError(t, err)
+			assert.IsType(t, testItem.expected, matcher)
+		})
+	}
+
+	matcher, err := Parser{}.Matcher()
+	require.NoError(t, err)
+	assert.Nil(t, matcher)
+
+	_, err = Parser{Type: "xpath", Match: "//img["}.Matcher()
+	assert.Error(t, err)
+}
+
+func TestLoadConfigurationScriptFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "gallery.star"), []byte("def find(page):\n    return ['a.jpg']\n"), 0644))
+	source := `{"profiles": [{"name": "script", "detectImage": {"type": "script", "file": "gallery.star", "timeout": 500}}]}`
+
+	cfg, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), dir)
+	require.NoError(t, err)
+	matcher, err := cfg.Profiles[0].DetectImage.Matcher()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg"}, matcher.FindAll(scan.NewDocument(nil)))
+
+	// the file is relative to the configuration
+	_, err = loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), "")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigurationMedia(t *testing.T) {
+	source := `{
+		"media": ["video"],
+		"profiles": [
+			{"name": "inherited", "detectImage": {"type": "regexp", "match": "x"}},
+			{"name": "own", "media": ["audio", "poster"], "detectImage": {"type": "regexp", "match": "x"}},
+			{"name": "disabled", "media": [], "detectImage": {"type": "regexp", "match": "x"}}
+		]
+	}`
+	cfg, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"video"}, cfg.Profiles[0].Media)
+	assert.Equal(t, []string{"audio", "poster"}, cfg.Profiles[1].Media)
+	assert.Empty(t, cfg.Profiles[2].Media)
+
+	scanCfg, err := cfg.Profiles[1].ScanConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"audio", "poster"}, scanCfg.Media)
+
+	_, err = loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(`{"profiles": [{"name": "invalid", "media": ["movie"]}]}`))), "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile invalid: unknown kind of media")
+}
+
+func TestLoadConfigurationMediaScope(t *testing.T) {
+	source := `{"profiles": [
+		{"name": "page", "detectImage": {"type": "regexp", "match": "x"}},
+		{"name": "scoped", "mediaScope": "div.gallery", "detectImage": {"type": "regexp", "match": "x"}}
+	]}`
+	cfg, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), "")
+	require.NoError(t, err)
+
+	scanCfg, err := cfg.Profiles[0].ScanConfig()
+	require.NoError(t, err)
+	assert.Nil(t, scanCfg.MediaScope)
+
+	scanCfg, err = cfg.Profiles[1].ScanConfig()
+	require.NoError(t, err)
+	assert.NotNil(t, scanCfg.MediaScope)
+
+	_, err = loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(`{"profiles": [{"name": "invalid", "mediaScope": "div["}]}`))), "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile invalid: invalid media scope")
+}
+
+func TestLoadConfigurationRewrite(t *testing.T) {
+	source := `{"profiles": [{"name": "rewrite", "rewrite": [{"match": "/thumbs/", "replace": "/full/"}], "detectImage": {"type": "regexp", "match": "x"}}]}`
+	cfg, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), "")
+	require.NoError(t, err)
+
+	rule := cfg.Profiles[0].Rewrite[0]
+	require.NotNil(t, rule.pattern)
+	pattern, err := rule.Pattern()
+	require.NoError(t, err)
+	assert.Same(t, rule.pattern, pattern)
+
+	_, err = loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(`{"profiles": [{"name": "invalid", "rewrite": [{"match": "_(\\d+"}]}]}`))), "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `profile invalid: invalid rewrite rule "_(\\d+"`)
+}
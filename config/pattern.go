@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// regexpPrefix marks a pattern as a regular expression instead of a glob
+const regexpPrefix = "regexp:"
+
+// HostMatches returns true when the host matches one of the patterns:
+//   - "photos.example.com" for this host only
+//   - ".example.com" for all subdomains of example.com
+//   - a glob like "*.example.*" where "*" matches any characters
+//   - a regular expression like "regexp:^photos[0-9]+\.example\.com$"
+//
+// Invalid patterns never match
+func HostMatches(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		compiled, err := compileHostPattern(pattern)
+		if err == nil && compiled.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// PathMatches returns true when the path of a URL matches one of the patterns:
+//   - a glob like "/gallery/*" where "*" matches any characters except "/" and "**" matches any characters
+//   - a regular expression like "regexp:^/album/[0-9]+"
+//
+// Invalid patterns never match
+func PathMatches(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		compiled, err := compilePathPattern(pattern)
+		if err == nil && compiled.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchURL returns true when the URL matches both the hosts and the paths of the profile.
+// A profile without any host or path never matches
+func (p Profile) MatchURL(pageURL *url.URL) bool {
+	if pageURL == nil || (len(p.Hosts) == 0 && len(p.Paths) == 0) {
+		return false
+	}
+	if len(p.Hosts) > 0 && !matchHost(pageURL.Hostname(), p.hostPatterns, p.Hosts) {
+		return false
+	}
+	if len(p.Paths) > 0 && !matchPath(pageURL.Path, p.pathPatterns, p.Paths) {
+		return false
+	}
+	return true
+}
+
+// HostMatches returns true when the host is one of the other hosts allowed when crawling the albums
+func (a Albums) HostMatches(host string) bool {
+	return matchHost(host, a.hostPatterns, a.Hosts)
+}
+
+// FrameHostMatches returns true when the frames of this host are followed with the -frames flag
+func (c *Configuration) FrameHostMatches(host string) bool {
+	return matchHost(host, c.frameHostPatterns, c.FrameHosts)
+}
+
+// matchHost uses the patterns compiled when loading the configuration,
+// or compiles the patterns of a configuration created in code
+func matchHost(host string, compiled []*regexp.Regexp, patterns []string) bool {
+	if compiled == nil {
+		return HostMatches(host, patterns)
+	}
+	return matchAny(strings.ToLower(host), compiled)
+}
+
+// matchPath uses the patterns compiled when loading the configuration,
+// or compiles the patterns of a configuration created in code
+func matchPath(path string, compiled []*regexp.Regexp, patterns []string) bool {
+	if compiled == nil {
+		return PathMatches(path, patterns)
+	}
+	return matchAny(path, compiled)
+}
+
+func matchAny(value string, compiled []*regexp.Regexp) bool {
+	for _, pattern := range compiled {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePatterns compiles a list of host or path patterns. The result is never nil
+func compilePatterns(patterns []string, compile func(string) (*regexp.Regexp, error)) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		regex, err := compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, regex)
+	}
+	return compiled, nil
+}
+
+func compileHostPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, regexpPrefix) {
+		return regexp.Compile("(?i)" + strings.TrimPrefix(pattern, regexpPrefix))
+	}
+	pattern = strings.ToLower(pattern)
+	if strings.HasPrefix(pattern, ".") {
+		pattern = "*" + pattern
+	}
+	return compileGlob(pattern, "")
+}
+
+func compilePathPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, regexpPrefix) {
+		return regexp.Compile(strings.TrimPrefix(pattern, regexpPrefix))
+	}
+	return compileGlob(pattern, "/")
+}
+
+// compileGlob converts a glob into a regular expression matching the whole string.
+// "?" matches one character, "*" matches any characters except the separator, and "**" matches any characters
+func compileGlob(glob, separator string) (*regexp.Regexp, error) {
+	star := ".*"
+	if separator != "" {
+		star = "[^" + regexp.QuoteMeta(separator) + "]*"
+	}
+	buffer := &strings.Builder{}
+	buffer.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			buffer.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			buffer.WriteString(star)
+		case glob[i] == '?':
+			buffer.WriteString(".")
+		default:
+			buffer.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	buffer.WriteString("$")
+	return regexp.Compile(buffer.String())
+}
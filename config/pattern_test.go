@@ -0,0 +1,152 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostMatches(t *testing.T) {
+	testData := []struct {
+		host     string
+		patterns []string
+		match    bool
+	}{
+		{"photos.example.com", []string{"photos.example.com"}, true},
+		{"Photos.Example.com", []string{"photos.example.com"}, true},
+		{"www.photos.example.com", []string{"photos.example.com"}, false},
+		{"photos.example.com", []string{".example.com"}, true},
+		{"a.b.example.com", []string{".example.com"}, true},
+		{"example.com", []string{".example.com"}, false},
+		{"notexample.com", []string{".example.com"}, false},
+		{"cdn12.example.net", []string{"cdn*.example.net"}, true},
+		{"cdn.example.org", []string{"cdn*.example.net"}, false},
+		{"photos42.example.com", []string{`regexp:^photos[0-9]+\.example\.com$`}, true},
+		{"photos.example.com", []string{`regexp:^photos[0-9]+\.example\.com$`}, false},
+		{"photos.example.com", []string{"other.com", ".example.com"}, true},
+		{"photos.example.com", []string{"regexp:("}, false},
+		{"photos.example.com", nil, false},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.host, func(t *testing.T) {
+			assert.Equal(t, testItem.match, HostMatches(testItem.host, testItem.patterns), "%v", testItem.patterns)
+		})
+	}
+}
+
+func TestPathMatches(t *testing.T) {
+	testData := []struct {
+		path     string
+		patterns []string
+		match    bool
+	}{
+		{"/gallery/summer", []string{"/gallery/*"}, true},
+		{"/gallery/summer/2", []string{"/gallery/*"}, false},
+		{"/gallery/summer/2", []string{"/gallery/**"}, true},
+		{"/album/12", []string{"/album/??"}, true},
+		{"/album/123", []string{"/album/??"}, false},
+		{"/Gallery/summer", []string{"/gallery/*"}, false},
+		{"/album/123/view", []string{"regexp:^/album/[0-9]+"}, true},
+		{"/albums", []string{"regexp:^/album/[0-9]+"}, false},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.path, func(t *testing.T) {
+			assert.Equal(t, testItem.match, PathMatches(testItem.path, testItem.patterns), "%v", testItem.patterns)
+		})
+	}
+}
+
+func TestProfileMatchURL(t *testing.T) {
+	pageURL, err := url.Parse("https://photos.example.com/gallery/summer?page=2")
+	require.NoError(t, err)
+
+	assert.False(t, Profile{}.MatchURL(pageURL))
+	assert.False(t, Profile{Hosts: []string{".example.com"}}.MatchURL(nil))
+	assert.True(t, Profile{Hosts: []string{".example.com"}}.MatchURL(pageURL))
+	assert.True(t, Profile{Paths: []string{"/gallery/*"}}.MatchURL(pageURL))
+	assert.True(t, Profile{Hosts: []string{".example.com"}, Paths: []string{"/gallery/*"}}.MatchURL(pageURL))
+	assert.False(t, Profile{Hosts: []string{".example.com"}, Paths: []string{"/album/*"}}.MatchURL(pageURL))
+	assert.False(t, Profile{Hosts: []string{"example.org"}, Paths: []string{"/gallery/*"}}.MatchURL(pageURL))
+}
+
+func TestLoadConfigurationInvalidPattern(t *testing.T) {
+	testData := []struct {
+		name   string
+		source string
+	}{
+		{"hosts", `{"profiles": [{"name": "invalid", "hosts": ["regexp:("]}]}`},
+		{"paths", `{"profiles": [{"name": "invalid", "paths": ["regexp:[a-"]}]}`},
+		{"albums", `{"profiles": [{"name": "invalid", "albums": {"hosts": ["regexp:)"]}}]}`},
+		{"frames", `{"frameHosts": ["regexp:("]}`},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			_, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(testItem.source))), "")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid")
+		})
+	}
+}
+
+func TestConfigurationProfile(t *testing.T) {
+	cfg := &Configuration{Profiles: []Profile{{Name: "First"}, {Name: "Second"}}}
+
+	profile, found := cfg.Profile("second")
+	assert.True(t, found)
+	assert.Equal(t, "Second", profile.Name)
+
+	_, found = cfg.Profile("third")
+	assert.False(t, found)
+
+	_, found = cfg.Profile("")
+	assert.False(t, found)
+}
+
+func TestLoadConfigurationCompilesPatterns(t *testing.T) {
+	source := `{
+		"frameHosts": [".example.net"],
+		"profiles": [{
+			"name": "compiled",
+			"hosts": ["Photos.Example.com"],
+			"paths": ["/gallery/*"],
+			"albums": {"hosts": [".example.org"]},
+			"mediaScope": "div.gallery",
+			"detectImage": {"type": "regexp", "match": "x"}
+		}]
+	}`
+	cfg, err := loadConfiguration(ioutil.NopCloser(bytes.NewReader([]byte(source))), "")
+	require.NoError(t, err)
+	profile := cfg.Profiles[0]
+	assert.Len(t, profile.hostPatterns, 1)
+	assert.Len(t, profile.pathPatterns, 1)
+	assert.Len(t, profile.Albums.hostPatterns, 1)
+	assert.Len(t, cfg.frameHostPatterns, 1)
+	require.NotNil(t, profile.scope)
+
+	pageURL, err := url.Parse("https://photos.example.com/gallery/summer")
+	require.NoError(t, err)
+	assert.True(t, profile.MatchURL(pageURL))
+	pageURL.Path = "/album/summer"
+	assert.False(t, profile.MatchURL(pageURL))
+	assert.True(t, profile.Albums.HostMatches("PHOTOS.example.org"))
+	assert.False(t, profile.Albums.HostMatches("example.com"))
+	assert.True(t, cfg.FrameHostMatches("player.example.net"))
+	assert.False(t, cfg.FrameHostMatches("example.com"))
+
+	// the patterns are used instead of the lists
+	profile.Hosts = []string{"example.org"}
+	pageURL.Path = "/gallery/summer"
+	assert.True(t, profile.MatchURL(pageURL))
+
+	// the lists of a configuration created in code are compiled when used
+	assert.True(t, Albums{Hosts: []string{".example.org"}}.HostMatches("photos.example.org"))
+	assert.True(t, (&Configuration{FrameHosts: []string{".example.net"}}).FrameHostMatches("player.example.net"))
+	assert.False(t, (&Configuration{}).FrameHostMatches("player.example.net"))
+}
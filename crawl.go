@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"gallery-downloader/config"
+	"gallery-downloader/download"
+	"gallery-downloader/scan"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var titleSelector = cascadia.MustCompile("head title")
+
+// crawler walks an index page listing albums, and downloads each album into its own folder
+type crawler struct {
+	flags   Flags
+	cfg     *config.Configuration
+	root    *url.URL
+	visited map[string]bool
+}
+
+func newCrawler(root *url.URL, flags Flags, cfg *config.Configuration) *crawler {
+	return &crawler{
+		flags:   flags,
+		cfg:     cfg,
+		root:    root,
+		visited: map[string]bool{root.String(): true},
+	}
+}
+
+// crawl downloads all the albums listed in the index page into subfolders of output.
+// It returns false when the page is not detected as an index of albums
+func (c *crawler) crawl(pageURL *url.URL, doc *scan.Document, output string, depth int) bool {
+	profile, links := detectAlbums(c.cfg.Profiles, pageURL, doc)
+	if len(links) == 0 {
+		return false
+	}
+	maxDepth := profile.Albums.MaxDepth
+	if c.flags.Depth > 0 {
+		maxDepth = c.flags.Depth
+	}
+	if maxDepth <= 0 {
+		maxDepth = 1
+	}
+	if depth > maxDepth {
+		return false
+	}
+	log.Printf("Found %d albums using profile %s (depth %d)", len(links), profile.Name, depth)
+
+	base := documentBase(pageURL, doc.Source())
+	folders := make(map[string]bool)
+	for index, link := range links {
+		albumURL, err := url.Parse(strings.TrimSpace(link))
+		if err != nil {
+			log.Printf("Error: invalid album URL %q: %v", link, err)
+			continue
+		}
+		albumURL = base.ResolveReference(albumURL)
+		albumURL.Fragment = ""
+		if c.visited[albumURL.String()] {
+			continue
+		}
+		c.visited[albumURL.String()] = true
+		if !c.hostAllowed(albumURL.Hostname(), profile.Albums) {
+			log.Printf("Skipping album on another host: %s", albumURL)
+			continue
+		}
+
+		log.Printf("Album %d/%d: %s", index+1, len(links), albumURL)
+		downloadContext := pageContext(pageURL.String(), c.flags, c.cfg)
+		source, err := downloadContext.HTML(albumURL.String())
+		if err != nil {
+			log.Printf("Error: cannot download album: %v", err)
+			continue
+		}
+		album := scan.NewPageDocument(source, albumURL)
+
+		folder := uniqueFolderName(albumTitle(profile.Albums.Title, album, albumURL, index+1), folders)
+		albumOutput := path.Join(output, folder)
+		err = os.MkdirAll(albumOutput, 0755)
+		if err != nil {
+			log.Printf("Error: cannot create album folder: %v", err)
+			continue
+		}
+
+		if depth < maxDepth && c.crawl(albumURL, album, albumOutput, depth+1) {
+			continue
+		}
+		downloadRemoteGallery(albumURL, album, albumOutput, c.flags, c.cfg)
+	}
+	return true
+}
+
+// hostAllowed returns true for the host of the index page, and for the hosts listed in the profile
+func (c *crawler) hostAllowed(host string, albums config.Albums) bool {
+	return strings.EqualFold(host, c.root.Hostname()) || albums.HostMatches(host)
+}
+
+// detectAlbums returns the first profile detecting links to albums in the source,
+// trying the profiles in the same order as the galleries (see profileOrder)
+func detectAlbums(profiles []config.Profile, pageURL *url.URL, doc *scan.Document) (config.Profile, []string) {
+	for _, index := range profileOrder(profiles, pageURL) {
+		profile := profiles[index]
+		if !profile.Albums.IsSet() {
+			continue
+		}
+		gallery, err := profile.DetectGallery.Matcher()
+		if err != nil {
+			log.Printf("Error: profile %s: cannot compile gallery detection: %v", profile.Name, err)
+			continue
+		}
+		if gallery != nil && gallery.Find(doc) == "" {
+			continue
+		}
+		link, err := profile.Albums.Link.Matcher()
+		if err != nil {
+			log.Printf("Error: profile %s: cannot compile album link detection: %v", profile.Name, err)
+			continue
+		}
+		if link == nil {
+			continue
+		}
+		if links := link.FindAll(doc); len(links) > 0 {
+			return profile, links
+		}
+	}
+	return config.Profile{}, nil
+}
+
+// albumTitle returns the title of the album, from the title parser of the profile,
+// from the <title> of the page, or from the URL of the album
+func albumTitle(parser config.Parser, doc *scan.Document, albumURL *url.URL, index int) string {
+	if matcher, err := parser.Matcher(); err == nil && matcher != nil {
+		// an attribute value first, or the text of the element
+		if titles := matcher.FindAll(doc); len(titles) > 0 && strings.TrimSpace(titles[0]) != "" {
+			return titles[0]
+		}
+		if title := textContent(matcher.Find(doc)); title != "" {
+			return title
+		}
+	}
+	if node, err := doc.Node(); err == nil {
+		if title := cascadia.Query(node, titleSelector); title != nil && title.FirstChild != nil {
+			if text := strings.TrimSpace(title.FirstChild.Data); text != "" {
+				return text
+			}
+		}
+	}
+	if name := path.Base(albumURL.Path); name != "" && name != "/" && name != "." {
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return fmt.Sprintf("album-%d", index)
+}
+
+// textContent returns the text of an HTML fragment
+func textContent(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return ""
+	}
+	buffer := &strings.Builder{}
+	for _, node := range nodes {
+		buffer.WriteString(scan.NodeText(node))
+	}
+	return strings.TrimSpace(buffer.String())
+}
+
+// uniqueFolderName converts the title into a folder name, different from the ones already used
+func uniqueFolderName(title string, used map[string]bool) string {
+	name := download.SafeName(html.UnescapeString(title))
+	if name == "" {
+		name = "album"
+	}
+	unique := name
+	for index := 2; used[strings.ToLower(unique)]; index++ {
+		unique = fmt.Sprintf("%s (%d)", name, index)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
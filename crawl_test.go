@@ -0,0 +1,282 @@
+package main
+
+import (
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniqueFolderName(t *testing.T) {
+	used := make(map[string]bool)
+	testData := []struct {
+		title    string
+		expected string
+	}{
+		{"Summer 2024", "Summer 2024"},
+		{"summer 2024", "summer 2024 (2)"},
+		{"Summer  2024 ", "Summer 2024 (3)"},
+		{"Tom &amp; Jerry", "Tom & Jerry"},
+		{"a/b: c?", "a_b_ c_"},
+		{"", "album"},
+		{" ... ", "album (2)"},
+	}
+	for _, testItem := range testData {
+		assert.Equal(t, testItem.expected, uniqueFolderName(testItem.title, used), testItem.title)
+	}
+}
+
+func TestTextContent(t *testing.T) {
+	testData := []struct {
+		fragment string
+		expected string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{`<h1 class="title"> Summer <em>2024</em> </h1>`, "Summer 2024"},
+		{"<img src=\"cover.jpg\">", ""},
+		{"<p>Tom &amp; Jerry</p>", "Tom & Jerry"},
+	}
+	for _, testItem := range testData {
+		assert.Equal(t, testItem.expected, textContent(testItem.fragment), testItem.fragment)
+	}
+}
+
+func TestAlbumTitle(t *testing.T) {
+	page := `<html><head><title> Page title </title></head><body>
+<h1 class="album"><span>Album</span> title</h1>
+<div class="cover" data-title="Cover title"></div>
+<div class="empty" data-title=" "></div>
+</body></html>`
+	untitled := `<html><head><title></title></head><body></body></html>`
+	albumURL := mustParseURL(t, "https://example.com/albums/summer.html")
+
+	testData := []struct {
+		name     string
+		parser   config.Parser
+		source   string
+		albumURL string
+		expected string
+	}{
+		{"attribute", config.Parser{Type: "css", Match: "div.cover", Attribute: config.Attributes{"data-title"}}, page, "", "Cover title"},
+		{"element text", config.Parser{Type: "css", Match: "h1.album"}, page, "", "Album title"},
+		{"regexp", config.Parser{Type: "regexp", Match: `<h1 class="album">(.+?)</h1>`}, page, "", "<span>Album</span> title"},
+		{"empty attribute", config.Parser{Type: "css", Match: "div.empty", Attribute: config.Attributes{"data-title"}}, page, "", "Page title"},
+		{"not found", config.Parser{Type: "css", Match: "h2"}, page, "", "Page title"},
+		{"invalid parser", config.Parser{Type: "regexp", Match: "("}, page, "", "Page title"},
+		{"page title", config.Parser{}, page, "", "Page title"},
+		{"url", config.Parser{}, untitled, "", "summer"},
+		{"index", config.Parser{}, untitled, "https://example.com/", "album-3"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			pageURL := albumURL
+			if testItem.albumURL != "" {
+				pageURL = mustParseURL(t, testItem.albumURL)
+			}
+			doc := scan.NewDocument([]byte(testItem.source))
+			assert.Equal(t, testItem.expected, albumTitle(testItem.parser, doc, pageURL, 3))
+		})
+	}
+}
+
+func TestDetectAlbums(t *testing.T) {
+	doc := scan.NewDocument([]byte(`<html><body><div class="albums">
+<a class="album" href="/albums/1">One</a>
+<a class="album" href="/albums/2">Two</a>
+</div></body></html>`))
+	albums := func(name string, priority int, gallery, link string) config.Profile {
+		profile := config.Profile{
+			Priority: priority,
+			Name:     name,
+			Albums:   config.Albums{Link: config.Parser{Type: "css", Match: link, Attribute: config.Attributes{"href"}}},
+		}
+		if gallery != "" {
+			profile.DetectGallery = config.Parser{Type: "css", Match: gallery}
+		}
+		return profile
+	}
+	withHost := func(profile config.Profile, exclusive bool) config.Profile {
+		profile.Hosts = []string{"example.com"}
+		profile.Exclusive = exclusive
+		return profile
+	}
+	pageURL := mustParseURL(t, "https://example.com/albums/")
+
+	testData := []struct {
+		name     string
+		profiles []config.Profile
+		expected string
+		links    []string
+	}{
+		{"no albums", []config.Profile{{Name: "gallery", DetectImage: config.Parser{Type: "css", Match: "img"}}}, "", nil},
+		{"links", []config.Profile{albums("albums", 10, "", "a.album")}, "albums", []string{"/albums/1", "/albums/2"}},
+		{"gallery detected", []config.Profile{albums("albums", 10, "div.albums", "a.album")}, "albums", []string{"/albums/1", "/albums/2"}},
+		{"gallery not detected", []config.Profile{albums("albums", 10, "div.index", "a.album")}, "", nil},
+		{"no link found", []config.Profile{albums("none", 10, "", "a.missing"), albums("albums", 20, "", "a.album")}, "albums", []string{"/albums/1", "/albums/2"}},
+		{"by priority", []config.Profile{albums("last", 20, "", "a.album"), albums("first", 10, "", `a[href$="2"]`)}, "first", []string{"/albums/2"}},
+		{"invalid", []config.Profile{albums("invalid", 10, "", "a["), albums("albums", 20, "", "a.album")}, "albums", []string{"/albums/1", "/albums/2"}},
+		{"host first", []config.Profile{albums("first", 10, "", "a.album"), withHost(albums("host", 20, "", `a[href$="2"]`), false)}, "host", []string{"/albums/2"}},
+		{"exclusive host", []config.Profile{albums("other", 10, "", "a.album"), withHost(albums("host", 20, "", "a.missing"), true)}, "", nil},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			profile, links := detectAlbums(testItem.profiles, pageURL, doc)
+			assert.Equal(t, testItem.expected, profile.Name)
+			assert.Equal(t, testItem.links, links)
+		})
+	}
+}
+
+// newAlbumServer serves an index of albums, with a section (an index of its own) and an album on another host
+func newAlbumServer(t *testing.T) (*httptest.Server, func() []string) {
+	lock := sync.Mutex{}
+	requested := make([]string, 0)
+	pages := map[string]string{
+		"/index":         `<title>Index</title><a href="/albums/a">A</a> <a href="/albums/b">B</a> <a href="/albums/a#top">A</a> <a href="/sections/s">S</a> <a href="http://localhost:{port}/albums/remote">R</a>`,
+		"/albums/a":      `<title>Summer</title><img src="/photos/a1.jpg"><img src="/photos/a2.jpg">`,
+		"/albums/b":      `<title>Summer</title><img src="/photos/b1.jpg">`,
+		"/sections/s":    `<title>Section</title><a href="/albums/c">C</a>`,
+		"/albums/c":      `<title>Winter</title><img src="/photos/c1.jpg">`,
+		"/albums/remote": `<title>Remote</title><img src="/photos/r1.jpg">`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requested = append(requested, r.Host[:strings.Index(r.Host, ":")]+r.URL.Path)
+		lock.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/photos/") {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("picture " + r.URL.Path))
+			return
+		}
+		page, found := pages[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		port := r.Host[strings.Index(r.Host, ":")+1:]
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>" + strings.ReplaceAll(page, "{port}", port) + "</html>"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		sorted := append([]string{}, requested...)
+		sort.Strings(sorted)
+		return sorted
+	}
+}
+
+// listFiles returns the files of the folder, relative to it
+func listFiles(t *testing.T, folder string) []string {
+	files := make([]string, 0)
+	err := filepath.Walk(folder, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(folder, name)
+		files = append(files, filepath.ToSlash(relative))
+		return err
+	})
+	require.NoError(t, err)
+	sort.Strings(files)
+	return files
+}
+
+func TestCrawl(t *testing.T) {
+	testData := []struct {
+		name      string
+		maxDepth  int
+		depth     int
+		hosts     []string
+		files     []string
+		requested []string
+	}{
+		{
+			name:  "default depth",
+			files: []string{"Section/index.html", "Summer (2)/b1.jpg", "Summer/a1.jpg", "Summer/a2.jpg"},
+			requested: []string{
+				"127.0.0.1/albums/a", "127.0.0.1/albums/b", "127.0.0.1/index", "127.0.0.1/photos/a1.jpg",
+				"127.0.0.1/photos/a2.jpg", "127.0.0.1/photos/b1.jpg", "127.0.0.1/sections/s",
+			},
+		},
+		{
+			name:     "profile depth",
+			maxDepth: 2,
+			files:    []string{"Section/Winter/c1.jpg", "Summer (2)/b1.jpg", "Summer/a1.jpg", "Summer/a2.jpg"},
+			requested: []string{
+				"127.0.0.1/albums/a", "127.0.0.1/albums/b", "127.0.0.1/albums/c", "127.0.0.1/index", "127.0.0.1/photos/a1.jpg",
+				"127.0.0.1/photos/a2.jpg", "127.0.0.1/photos/b1.jpg", "127.0.0.1/photos/c1.jpg", "127.0.0.1/sections/s",
+			},
+		},
+		{
+			name:     "depth flag",
+			maxDepth: 2,
+			depth:    1,
+			files:    []string{"Section/index.html", "Summer (2)/b1.jpg", "Summer/a1.jpg", "Summer/a2.jpg"},
+			requested: []string{
+				"127.0.0.1/albums/a", "127.0.0.1/albums/b", "127.0.0.1/index", "127.0.0.1/photos/a1.jpg",
+				"127.0.0.1/photos/a2.jpg", "127.0.0.1/photos/b1.jpg", "127.0.0.1/sections/s",
+			},
+		},
+		{
+			name:  "other host allowed",
+			hosts: []string{"localhost"},
+			files: []string{"Remote/r1.jpg", "Section/index.html", "Summer (2)/b1.jpg", "Summer/a1.jpg", "Summer/a2.jpg"},
+			requested: []string{
+				"127.0.0.1/albums/a", "127.0.0.1/albums/b", "127.0.0.1/index", "127.0.0.1/photos/a1.jpg",
+				"127.0.0.1/photos/a2.jpg", "127.0.0.1/photos/b1.jpg", "127.0.0.1/sections/s",
+				"localhost/albums/remote", "localhost/photos/r1.jpg",
+			},
+		},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			ts, requested := newAlbumServer(t)
+			output := t.TempDir()
+			cfg := &config.Configuration{Profiles: []config.Profile{{
+				Name:        "albums",
+				DetectImage: config.Parser{Type: "regexp", Match: `src="([^"]+)"`},
+				Albums: config.Albums{
+					Link:     config.Parser{Type: "regexp", Match: `href="([^"]+)"`},
+					MaxDepth: testItem.maxDepth,
+					Hosts:    testItem.hosts,
+				},
+			}}}
+			flags := Flags{Type: scan.ConfigProfiles, Depth: testItem.depth}
+
+			indexURL := mustParseURL(t, ts.URL+"/index")
+			response, err := http.Get(indexURL.String())
+			require.NoError(t, err)
+			source, err := io.ReadAll(response.Body)
+			response.Body.Close()
+			require.NoError(t, err)
+			doc := scan.NewPageDocument(source, indexURL)
+
+			assert.True(t, newCrawler(indexURL, flags, cfg).crawl(indexURL, doc, output, 1))
+			assert.Equal(t, testItem.files, listFiles(t, output))
+			assert.Equal(t, testItem.requested, requested())
+		})
+	}
+}
+
+func TestCrawlNoAlbum(t *testing.T) {
+	pageURL := mustParseURL(t, "https://example.com/gallery")
+	doc := scan.NewDocument([]byte(`<html><body><img src="photo.jpg"></body></html>`))
+	cfg := &config.Configuration{Profiles: []config.Profile{{
+		Name:   "albums",
+		Albums: config.Albums{Link: config.Parser{Type: "css", Match: "a.album", Attribute: config.Attributes{"href"}}},
+	}}}
+	assert.False(t, newCrawler(pageURL, Flags{}, cfg).crawl(pageURL, doc, t.TempDir(), 1))
+}
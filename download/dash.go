@@ -0,0 +1,385 @@
+package download
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDASHSegments stops the download of absurdly long streams, like a duration written in the wrong unit
+const maxDASHSegments = 100000
+
+type dashMPD struct {
+	Type     string       `xml:"type,attr"`
+	Duration string       `xml:"mediaPresentationDuration,attr"`
+	BaseURL  string       `xml:"BaseURL"`
+	Periods  []dashPeriod `xml:"Period"`
+}
+
+type dashPeriod struct {
+	Duration        string              `xml:"duration,attr"`
+	BaseURL         string              `xml:"BaseURL"`
+	SegmentTemplate *dashTemplate       `xml:"SegmentTemplate"`
+	SegmentList     *dashList           `xml:"SegmentList"`
+	AdaptationSets  []dashAdaptationSet `xml:"AdaptationSet"`
+}
+
+type dashAdaptationSet struct {
+	MimeType        string               `xml:"mimeType,attr"`
+	ContentType     string               `xml:"contentType,attr"`
+	BaseURL         string               `xml:"BaseURL"`
+	SegmentTemplate *dashTemplate        `xml:"SegmentTemplate"`
+	SegmentList     *dashList            `xml:"SegmentList"`
+	Representations []dashRepresentation `xml:"Representation"`
+}
+
+type dashRepresentation struct {
+	ID              string        `xml:"id,attr"`
+	Bandwidth       int64         `xml:"bandwidth,attr"`
+	MimeType        string        `xml:"mimeType,attr"`
+	BaseURL         string        `xml:"BaseURL"`
+	SegmentTemplate *dashTemplate `xml:"SegmentTemplate"`
+	SegmentList     *dashList     `xml:"SegmentList"`
+	SegmentBase     *dashBase     `xml:"SegmentBase"`
+}
+
+type dashTemplate struct {
+	Initialization string        `xml:"initialization,attr"`
+	Media          string        `xml:"media,attr"`
+	StartNumber    *int64        `xml:"startNumber,attr"`
+	Timescale      *int64        `xml:"timescale,attr"`
+	Duration       int64         `xml:"duration,attr"`
+	Timeline       *dashTimeline `xml:"SegmentTimeline"`
+}
+
+type dashTimeline struct {
+	Segments []dashTimelineSegment `xml:"S"`
+}
+
+type dashTimelineSegment struct {
+	Time     *int64 `xml:"t,attr"`
+	Duration int64  `xml:"d,attr"`
+	Repeat   int64  `xml:"r,attr"`
+}
+
+type dashList struct {
+	Initialization *dashURL  `xml:"Initialization"`
+	SegmentURLs    []dashURL `xml:"SegmentURL"`
+}
+
+type dashURL struct {
+	SourceURL  string `xml:"sourceURL,attr"`
+	Range      string `xml:"range,attr"`
+	Media      string `xml:"media,attr"`
+	MediaRange string `xml:"mediaRange,attr"`
+}
+
+type dashBase struct {
+	Initialization *dashURL `xml:"Initialization"`
+}
+
+// dashStreams reads a DASH manifest. It takes the representation with the highest bandwidth of the video,
+// and of the audio when it's apart from the video. The periods are downloaded one after the other
+func dashStreams(playlistURL *url.URL, content []byte) ([]mediaStream, error) {
+	mpd := dashMPD{}
+	if err := xml.Unmarshal(content, &mpd); err != nil {
+		return nil, fmt.Errorf("invalid DASH manifest: %w", err)
+	}
+	if mpd.Type == "dynamic" {
+		return nil, errors.New("live DASH streams are not supported")
+	}
+	if len(mpd.Periods) == 0 {
+		return nil, errors.New("no period found in the DASH manifest")
+	}
+	base, err := resolve(playlistURL, mpd.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var video, audio *mediaStream
+	for _, period := range mpd.Periods {
+		duration := period.Duration
+		if duration == "" && len(mpd.Periods) == 1 {
+			duration = mpd.Duration
+		}
+		seconds, err := parseISODuration(duration)
+		if err != nil && duration != "" {
+			return nil, err
+		}
+		periodBase, err := resolve(base, period.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range []string{"video", "audio"} {
+			set, representation := bestRepresentation(period.AdaptationSets, kind)
+			if representation == nil {
+				continue
+			}
+			stream, err := dashStream(periodBase, seconds, period, *set, *representation)
+			if err != nil {
+				return nil, err
+			}
+			target := &video
+			if kind == "audio" {
+				target = &audio
+			}
+			if *target == nil {
+				*target = &stream
+			} else {
+				(*target).segments = append((*target).segments, stream.segments...)
+			}
+		}
+	}
+
+	streams := make([]mediaStream, 0, 2)
+	if video != nil {
+		streams = append(streams, *video)
+	}
+	if audio != nil {
+		if video != nil {
+			audio.suffix = ".audio"
+		}
+		streams = append(streams, *audio)
+	}
+	if len(streams) == 0 {
+		return nil, errors.New("no video or audio found in the DASH manifest")
+	}
+	return streams, nil
+}
+
+// bestRepresentation returns the representation of a kind of media with the highest bandwidth
+func bestRepresentation(sets []dashAdaptationSet, kind string) (*dashAdaptationSet, *dashRepresentation) {
+	var bestSet *dashAdaptationSet
+	var best *dashRepresentation
+	for i := range sets {
+		set := &sets[i]
+		for j := range set.Representations {
+			representation := &set.Representations[j]
+			if dashKind(*set, *representation) != kind {
+				continue
+			}
+			if best == nil || representation.Bandwidth > best.Bandwidth {
+				bestSet, best = set, representation
+			}
+		}
+	}
+	return bestSet, best
+}
+
+// dashKind returns "video", "audio" or another type of media like "text"
+func dashKind(set dashAdaptationSet, representation dashRepresentation) string {
+	for _, value := range []string{set.ContentType, representation.MimeType, set.MimeType} {
+		if value != "" {
+			kind, _, _ := strings.Cut(value, "/")
+			return kind
+		}
+	}
+	return ""
+}
+
+// dashStream lists the segments of a representation: the most specific segment description is used
+func dashStream(base *url.URL, seconds float64, period dashPeriod, set dashAdaptationSet, representation dashRepresentation) (mediaStream, error) {
+	stream := mediaStream{extension: ".mp4"}
+	mimeType := representation.MimeType
+	if mimeType == "" {
+		mimeType = set.MimeType
+	}
+	if extension := extensionByType(mimeType); extension != "" {
+		stream.extension = extension
+	}
+	base, err := resolve(base, set.BaseURL)
+	if err != nil {
+		return stream, err
+	}
+	base, err = resolve(base, representation.BaseURL)
+	if err != nil {
+		return stream, err
+	}
+
+	template := firstTemplate(representation.SegmentTemplate, set.SegmentTemplate, period.SegmentTemplate)
+	list := firstList(representation.SegmentList, set.SegmentList, period.SegmentList)
+	switch {
+	case template != nil:
+		stream.segments, err = templateSegments(base, seconds, *template, representation)
+	case list != nil:
+		stream.segments, err = listSegments(base, *list)
+	default:
+		// a single file, maybe with the index of its segments that we don't need
+		stream.segments = []segment{{url: base.String()}}
+	}
+	return stream, err
+}
+
+func firstTemplate(templates ...*dashTemplate) *dashTemplate {
+	for _, template := range templates {
+		if template != nil {
+			return template
+		}
+	}
+	return nil
+}
+
+func firstList(lists ...*dashList) *dashList {
+	for _, list := range lists {
+		if list != nil {
+			return list
+		}
+	}
+	return nil
+}
+
+// templateSegments builds the URLs of the segments from a template, using the timeline or the duration of the period
+func templateSegments(base *url.URL, seconds float64, template dashTemplate, representation dashRepresentation) ([]segment, error) {
+	number := int64(1)
+	if template.StartNumber != nil {
+		number = *template.StartNumber
+	}
+	timescale := int64(1)
+	if template.Timescale != nil && *template.Timescale > 0 {
+		timescale = *template.Timescale
+	}
+
+	segments := make([]segment, 0)
+	add := func(pattern string, number, time int64) error {
+		segmentURL, err := resolve(base, expandTemplate(pattern, representation, number, time))
+		if err != nil {
+			return err
+		}
+		segments = append(segments, segment{url: segmentURL.String()})
+		return nil
+	}
+	if template.Initialization != "" {
+		if err := add(template.Initialization, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if template.Timeline != nil {
+		time := int64(0)
+		for _, entry := range template.Timeline.Segments {
+			if entry.Time != nil {
+				time = *entry.Time
+			}
+			if entry.Duration <= 0 || entry.Repeat < 0 {
+				// a negative repeat lasts until the end of the period, which is only used by live streams
+				return nil, errors.New("unsupported DASH segment timeline")
+			}
+			for repeat := int64(0); repeat <= entry.Repeat; repeat++ {
+				if len(segments) > maxDASHSegments {
+					return nil, errors.New("too many segments in the DASH manifest")
+				}
+				if err := add(template.Media, number, time); err != nil {
+					return nil, err
+				}
+				number++
+				time += entry.Duration
+			}
+		}
+		return segments, nil
+	}
+
+	if template.Duration <= 0 || seconds <= 0 {
+		return nil, errors.New("missing duration in the DASH manifest")
+	}
+	count := int64(math.Ceil(seconds * float64(timescale) / float64(template.Duration)))
+	if count > maxDASHSegments {
+		return nil, errors.New("too many segments in the DASH manifest")
+	}
+	for index := int64(0); index < count; index++ {
+		if err := add(template.Media, number+index, index*template.Duration); err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
+
+// listSegments returns the segments listed one by one
+func listSegments(base *url.URL, list dashList) ([]segment, error) {
+	segments := make([]segment, 0, len(list.SegmentURLs)+1)
+	add := func(link, byteRange string) error {
+		segmentURL, err := resolve(base, link)
+		if err != nil {
+			return err
+		}
+		part := segment{url: segmentURL.String()}
+		if byteRange != "" {
+			first, last, found := strings.Cut(byteRange, "-")
+			start, err1 := strconv.ParseInt(first, 10, 64)
+			end, err2 := strconv.ParseInt(last, 10, 64)
+			if !found || err1 != nil || err2 != nil || end < start {
+				return fmt.Errorf("invalid byte range %q", byteRange)
+			}
+			part.offset, part.length = start, end-start+1
+		}
+		segments = append(segments, part)
+		return nil
+	}
+	if list.Initialization != nil {
+		if err := add(list.Initialization.SourceURL, list.Initialization.Range); err != nil {
+			return nil, err
+		}
+	}
+	for _, segmentURL := range list.SegmentURLs {
+		if err := add(segmentURL.Media, segmentURL.MediaRange); err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
+
+var templateIdentifier = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time)(%0(\d+)d)?\$|\$\$`)
+
+// expandTemplate replaces the identifiers of a segment template, like $Number%05d$
+func expandTemplate(pattern string, representation dashRepresentation, number, time int64) string {
+	return templateIdentifier.ReplaceAllStringFunc(pattern, func(identifier string) string {
+		if identifier == "$$" {
+			return "$"
+		}
+		match := templateIdentifier.FindStringSubmatch(identifier)
+		var value int64
+		switch match[1] {
+		case "RepresentationID":
+			return representation.ID
+		case "Number":
+			value = number
+		case "Bandwidth":
+			value = representation.Bandwidth
+		case "Time":
+			value = time
+		}
+		if match[3] != "" {
+			width, _ := strconv.Atoi(match[3])
+			return fmt.Sprintf("%0*d", width, value)
+		}
+		return strconv.FormatInt(value, 10)
+	})
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)Y)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration returns the number of seconds of an ISO 8601 duration like PT1H2M3.5S
+func parseISODuration(duration string) (float64, error) {
+	match := isoDuration.FindStringSubmatch(strings.TrimSpace(duration))
+	if match == nil || duration == "P" || strings.HasSuffix(duration, "T") {
+		return 0, fmt.Errorf("invalid duration %q", duration)
+	}
+	// years and months are approximations, they're not used by the manifests in practice
+	units := []float64{365 * 86400, 30 * 86400, 86400, 3600, 60, 1}
+	seconds := 0.0
+	for index, unit := range units {
+		if match[index+1] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(match[index+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", duration)
+		}
+		seconds += value * unit
+	}
+	return seconds, nil
+}
@@ -0,0 +1,143 @@
+package download
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadDASHTemplate(t *testing.T) {
+	ts, requested := playlistServer(map[string]string{
+		"/dash/manifest.mpd": `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT9.5S">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate initialization="$RepresentationID$/init.mp4" media="$RepresentationID$/$Number%03d$.m4s" timescale="1000" duration="4000"/>
+      <Representation id="360p" bandwidth="500000"/>
+      <Representation id="720p" bandwidth="1500000"/>
+    </AdaptationSet>
+    <AdaptationSet contentType="audio" mimeType="audio/mp4">
+      <Representation id="audio" bandwidth="128000">
+        <BaseURL>sound/</BaseURL>
+        <SegmentTemplate initialization="init.mp4" media="$Time$.m4s" startNumber="0">
+          <SegmentTimeline>
+            <S t="0" d="5" r="1"/>
+            <S d="2"/>
+          </SegmentTimeline>
+        </SegmentTemplate>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`,
+		"/dash/720p/init.mp4":  "init-",
+		"/dash/720p/001.m4s":   "one-",
+		"/dash/720p/002.m4s":   "two-",
+		"/dash/720p/003.m4s":   "three",
+		"/dash/sound/init.mp4": "audio-",
+		"/dash/sound/0.m4s":    "a",
+		"/dash/sound/5.m4s":    "b",
+		"/dash/sound/10.m4s":   "c",
+	})
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser:  testBrowserConfiguration,
+		Output:   output,
+		Parallel: 2,
+	})
+	download.Pictures([]Picture{{URL: ts.URL + "/dash/manifest.mpd"}})
+
+	video, err := os.ReadFile(path.Join(output, "manifest.mp4"))
+	require.NoError(t, err)
+	assert.Equal(t, "init-one-two-three", string(video))
+	audio, err := os.ReadFile(path.Join(output, "manifest.audio.m4a"))
+	require.NoError(t, err)
+	assert.Equal(t, "audio-abc", string(audio))
+	assert.NotContains(t, requested(), "/dash/360p/init.mp4")
+}
+
+func TestDownloadDASHSegmentList(t *testing.T) {
+	ts, _ := playlistServer(map[string]string{
+		"/list.mpd": `<MPD type="static">
+  <BaseURL>media/</BaseURL>
+  <Period duration="PT4S">
+    <AdaptationSet mimeType="video/webm">
+      <Representation id="1" bandwidth="1000">
+        <SegmentList>
+          <Initialization sourceURL="clip.webm" range="0-3"/>
+          <SegmentURL media="clip.webm" mediaRange="4-8"/>
+          <SegmentURL media="clip.webm" mediaRange="9-14"/>
+        </SegmentList>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`,
+		"/media/clip.webm": "initfirstsecond",
+	})
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Output:  output,
+	})
+	saved, size, err := download.downloadPicture(ts.URL+"/list.mpd", path.Join(output, "list.mpd"))
+	require.NoError(t, err)
+	assert.Equal(t, path.Join(output, "list.webm"), saved)
+	assert.Equal(t, int64(15), size)
+}
+
+func TestDashStreamsErrors(t *testing.T) {
+	playlistURL, _ := url.Parse("http://localhost/manifest.mpd")
+	testData := []struct {
+		name    string
+		content string
+	}{
+		{"not xml", "#EXTM3U"},
+		{"live", `<MPD type="dynamic"><Period><AdaptationSet mimeType="video/mp4"><Representation id="1"/></AdaptationSet></Period></MPD>`},
+		{"no period", `<MPD type="static"></MPD>`},
+		{"no video", `<MPD><Period><AdaptationSet mimeType="text/vtt"><Representation id="1"/></AdaptationSet></Period></MPD>`},
+		{"no duration", `<MPD><Period><AdaptationSet mimeType="video/mp4"><SegmentTemplate media="$Number$.m4s" duration="4"/><Representation id="1"/></AdaptationSet></Period></MPD>`},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			_, err := dashStreams(playlistURL, []byte(testItem.content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	representation := dashRepresentation{ID: "video-1", Bandwidth: 800000}
+	assert.Equal(t, "video-1/800000/00042-3600$.m4s",
+		expandTemplate("$RepresentationID$/$Bandwidth$/$Number%05d$-$Time$$$.m4s", representation, 42, 3600))
+}
+
+func TestParseISODuration(t *testing.T) {
+	testData := []struct {
+		duration string
+		seconds  float64
+	}{
+		{"PT1H2M3.5S", 3723.5},
+		{"PT30S", 30},
+		{"P1DT1S", 86401},
+		{"PT0.25S", 0.25},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.duration, func(t *testing.T) {
+			seconds, err := parseISODuration(testItem.duration)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.seconds, seconds)
+		})
+	}
+
+	for _, duration := range []string{"", "P", "PT", "1H", "PT1X"} {
+		_, err := parseISODuration(duration)
+		assert.Error(t, err, duration)
+	}
+}
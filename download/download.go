@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"gallery-downloader/config"
 	"gallery-downloader/headers"
+	"gallery-downloader/transcode"
 	"io"
 	"io/ioutil"
 	"log"
@@ -16,17 +17,20 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"strings"
+	"sync"
 	"time"
 )
 
 // http client is global to the package, and instanciated on first use
 var (
-	transport *http.Transport
-	client    *http.Client
+	transport  *http.Transport
+	client     *http.Client
+	clientLock sync.Mutex
 )
 
 type job struct {
-	picture string
+	picture Picture
 	index   int
 	total   int
 }
@@ -44,16 +48,37 @@ type Config struct {
 	Parallel      int
 	SkipVerifyTLS bool
 	Progress      func(Progress)
+	// Charset of the HTML pages, overriding the one detected from the response
+	Charset string
+	// Archive of the page, where the pictures are read before being downloaded
+	Archive Archive
 }
 
+// Archive gives the files saved with a page
+type Archive interface {
+	// Open returns the content of the file of a URL and its content type.
+	// The error wraps os.ErrNotExist when the file is not in the archive
+	Open(link string) (io.ReadCloser, string, error)
+}
+
+var errRelativeURL = errors.New("cannot load picture: its URL is relative and no -base flag was given")
+
 // Context contains the context to download http files
 type Context struct {
 	cfg Config
+	// files currently being downloaded, so parallel downloads never share a file
+	lock   sync.Mutex
+	active map[string]bool
+	// slots limit the number of downloads at the same time, segments of videos included
+	slots chan struct{}
 }
 
 // NewContext creates a new Context with an http client.
-// Any subsequent call to NewContext keeps the same http.Client already created
+// Any subsequent call to NewContext keeps the same http.Client already created.
+// It is safe for concurrent use, like downloading several galleries at the same time
 func NewContext(cfg Config) *Context {
+	clientLock.Lock()
+	defer clientLock.Unlock()
 	if transport == nil || client == nil {
 		tlsConfig := &tls.Config{
 			InsecureSkipVerify: cfg.SkipVerifyTLS,
@@ -75,86 +100,175 @@ func NewContext(cfg Config) *Context {
 		client = &http.Client{
 			Transport: transport,
 		}
-	} else {
+	} else if transport.TLSClientConfig.InsecureSkipVerify != cfg.SkipVerifyTLS {
 		transport.TLSClientConfig.InsecureSkipVerify = cfg.SkipVerifyTLS
 	}
 
+	slots := cfg.Parallel
+	if slots < 1 {
+		slots = 1
+	}
 	return &Context{
-		cfg: cfg,
+		cfg:    cfg,
+		active: make(map[string]bool),
+		slots:  make(chan struct{}, slots),
 	}
 }
 
 // HTML downloads an HTML page
 func (c *Context) HTML(link string) ([]byte, error) {
-	request, err := http.NewRequest("GET", link, nil)
+	page, _, err := c.Page(link)
+	return page, err
+}
+
+// Page downloads a page like HTML, and also returns its content type
+func (c *Context) Page(link string) ([]byte, string, error) {
+	reader, err := c.HTMLReader(link)
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+	page, err := ioutil.ReadAll(reader)
+	return page, reader.(*pageBody).contentType, err
+}
+
+// HTMLReader starts downloading an HTML page, and returns the body of the response as it arrives,
+// converted to UTF-8. The reader must be closed
+func (c *Context) HTMLReader(link string) (io.ReadCloser, error) {
+	response, reader, err := c.getPage(link)
 	if err != nil {
 		return nil, err
 	}
-	c.setHTMLDownloadHeaders(request)
+	reader, name, err := transcode.NewReader(reader, response.Header.Get("Content-Type"), c.cfg.Charset)
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	if name != transcode.UTF8 {
+		log.Printf("Converting page from %s to UTF-8", name)
+	}
+	return &pageBody{Reader: reader, Closer: response.Body, contentType: response.Header.Get("Content-Type")}, nil
+}
 
-	response, err := client.Do(request)
+// Data downloads a file as it is, like an XML document declaring its own encoding, or a compressed file.
+// Files larger than maxSize bytes are an error
+func (c *Context) Data(link string, maxSize int64) ([]byte, error) {
+	response, reader, err := c.getPage(link)
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", link, maxSize)
+	}
+	return data, nil
+}
 
-	if response.StatusCode >= 200 && response.StatusCode < 400 {
-		reader := response.Body
-		if response.Header.Get("Content-Encoding") == "gzip" {
-			reader, err = gzip.NewReader(response.Body)
-			if err != nil {
-				return nil, err
-			}
-		}
-		buffer, err := ioutil.ReadAll(reader)
+// getPage sends the request of a page, and returns the response with its decompressed body.
+// The body of the response must be closed
+func (c *Context) getPage(link string) (*http.Response, io.Reader, error) {
+	request, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.setHTMLDownloadHeaders(request)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 400 {
+		response.Body.Close()
+		return nil, nil, &StatusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+	var reader io.Reader = response.Body
+	if response.Header.Get("Content-Encoding") == "gzip" {
+		reader, err = gzip.NewReader(response.Body)
 		if err != nil {
-			return nil, err
+			response.Body.Close()
+			return nil, nil, err
 		}
-		return buffer, nil
 	}
-	return nil, fmt.Errorf("HTTP %s", response.Status)
+	return response, reader, nil
+}
+
+// pageBody reads the converted page, and closes the body of the response
+type pageBody struct {
+	io.Reader
+	io.Closer
+	contentType string
 }
 
 // Pictures downloads a list of pictures
-func (c *Context) Pictures(pictures []string) {
+func (c *Context) Pictures(pictures []Picture) {
 	total := len(pictures)
-	if c.cfg.Parallel < 2 {
-		// simple case of synchronous download
-		for index, picture := range pictures {
-			c.picture(picture, index, total)
-		}
-		return
-	}
-
 	jobs := make(chan job, total)
-	results := make(chan interface{}, total)
-
-	for w := 1; w <= c.cfg.Parallel; w++ {
-		go c.pictureWorker(w, jobs, results)
-	}
-
 	for index, picture := range pictures {
 		jobs <- job{picture, index, total}
 	}
 	close(jobs)
+	c.run(jobs)
+}
 
-	// get all results (could also do with a waitgroup)
-	for a := 1; a <= total; a++ {
-		<-results
-	}
+// Stream downloads the pictures as they arrive, until the channel is closed.
+// The total number of pictures is not known in advance, so the progress reports a total of 0.
+// It returns the number of pictures received
+func (c *Context) Stream(pictures <-chan Picture) int {
+	count := 0
+	jobs := make(chan job)
+	go func() {
+		for picture := range pictures {
+			jobs <- job{picture, count, 0}
+			count++
+		}
+		close(jobs)
+	}()
+	c.run(jobs)
+	return count
 }
 
-func (c *Context) pictureWorker(id int, jobs <-chan job, results chan<- interface{}) {
-	log.Printf("Creating worker %d", id)
-	for j := range jobs {
-		c.picture(j.picture, j.index, j.total)
-		results <- nil
+// run downloads the pictures of the jobs, using parallel workers when configured to do so
+func (c *Context) run(jobs <-chan job) {
+	c.parallel(func(id int) {
+		if id > 0 {
+			log.Printf("Creating worker %d", id)
+			defer log.Printf("Worker %d finished", id)
+		}
+		for j := range jobs {
+			c.slots <- struct{}{}
+			c.picture(j.picture, j.index, j.total)
+			<-c.slots
+		}
+	})
+}
+
+// parallel runs the work in as many workers as configured, and waits for all of them.
+// Without parallel download, the work runs once in the current goroutine with the id 0
+func (c *Context) parallel(work func(id int)) {
+	if c.cfg.Parallel < 2 {
+		// simple case of synchronous download
+		work(0)
+		return
 	}
-	log.Printf("Worker %d finished", id)
+
+	wg := &sync.WaitGroup{}
+	for w := 1; w <= c.cfg.Parallel; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			work(id)
+		}(w)
+	}
+	wg.Wait()
 }
 
-func (c *Context) picture(picture string, index, total int) {
-	pictureURL, err := url.Parse(picture)
+func (c *Context) picture(picture Picture, index, total int) {
+	pictureURL, err := url.Parse(picture.URL)
 	if err != nil {
 		if c.cfg.Progress != nil {
 			c.cfg.Progress(Progress{
@@ -166,23 +280,25 @@ func (c *Context) picture(picture string, index, total int) {
 		}
 		return
 	}
-	if !pictureURL.IsAbs() {
-		if c.cfg.BaseURL == nil || c.cfg.BaseURL.String() == "" {
+	if !pictureURL.IsAbs() && (c.cfg.BaseURL == nil || c.cfg.BaseURL.String() == "") {
+		// a relative picture can still be read from the archive
+		if c.cfg.Archive == nil {
 			if c.cfg.Progress != nil {
 				c.cfg.Progress(Progress{
 					FileID:     index,
 					TotalFiles: total,
 					URL:        pictureURL.String(),
 					Event:      EventError,
-					Err:        errors.New("cannot load picture: its URL is relative and no -base flag was given"),
+					Err:        errRelativeURL,
 				})
 			}
 			return
 		}
+	} else if !pictureURL.IsAbs() {
 		pictureURL = joinURL(c.cfg.BaseURL, pictureURL)
 	}
-	pictureName := path.Base(pictureURL.Path)
-	if pictureName == "" || pictureName == "/" {
+	pictureName := pictureName(picture, pictureURL.Path)
+	if pictureName == "" {
 		if c.cfg.Progress != nil {
 			c.cfg.Progress(Progress{
 				FileID:     index,
@@ -202,8 +318,31 @@ func (c *Context) picture(picture string, index, total int) {
 			Event:      EventStart,
 		})
 	}
-	output := uniqueName(path.Join(c.cfg.Output, pictureName))
-	size, err := c.downloadPicture(pictureURL.String(), output)
+	output, size, err := c.archivedPicture(pictureURL.String(), path.Join(c.cfg.Output, pictureName))
+	archived := err == nil
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error: %v: downloading it instead", err)
+		}
+		if pictureURL.IsAbs() {
+			output, size, err = c.downloadPicture(pictureURL.String(), path.Join(c.cfg.Output, pictureName))
+		} else {
+			err = errRelativeURL
+		}
+	}
+	if err != nil && picture.Fallback != "" && IsNotFound(err) {
+		if c.cfg.Progress != nil {
+			c.cfg.Progress(Progress{
+				FileID:     index,
+				TotalFiles: total,
+				URL:        picture.Fallback,
+				Event:      EventFallback,
+				Err:        err,
+			})
+		}
+		c.picture(Picture{URL: picture.Fallback, Title: picture.Title}, index, total)
+		return
+	}
 	if err != nil {
 		if c.cfg.Progress != nil {
 			c.cfg.Progress(Progress{
@@ -221,71 +360,273 @@ func (c *Context) picture(picture string, index, total int) {
 			URL:        pictureURL.String(),
 			Event:      EventFinished,
 			Downloaded: size,
+			Archived:   archived,
 		}
 		if size == 0 {
 			// no need to keep an empty file
 			progress.Event = EventNotSaving
 			_ = os.Remove(output)
 		}
-		if c.cfg.WaitMax > 0 && c.cfg.WaitMax > c.cfg.WaitMin {
-			wait := rand.Intn(c.cfg.WaitMax - c.cfg.WaitMin)
-			progress.Wait = wait + c.cfg.WaitMin
-			// now send the complete progress report
-			if c.cfg.Progress != nil {
-				c.cfg.Progress(progress)
-			}
-			// and wait
-			time.Sleep(time.Duration(wait+c.cfg.WaitMin) * time.Millisecond)
-		} else {
-			// now send the complete progress report
-			if c.cfg.Progress != nil {
-				c.cfg.Progress(progress)
+		progress.Wait = c.waitTime()
+		// now send the complete progress report
+		if c.cfg.Progress != nil {
+			c.cfg.Progress(progress)
+		}
+		// and wait
+		time.Sleep(time.Duration(progress.Wait) * time.Millisecond)
+	}
+}
+
+// waitTime returns a random time to wait between two downloads, in milliseconds
+func (c *Context) waitTime() int {
+	if c.cfg.WaitMax > 0 && c.cfg.WaitMax > c.cfg.WaitMin {
+		return c.cfg.WaitMin + rand.Intn(c.cfg.WaitMax-c.cfg.WaitMin)
+	}
+	return 0
+}
+
+// downloadPicture saves the picture into output, or into a unique name derived from it, and returns the name of the file.
+// The file is written as output.part until it's complete: an interrupted download is resumed with a range request,
+// right away or the next time the same file is downloaded.
+// An output without extension gets the one of the content type. With an empty output, the picture is only downloaded.
+// The videos of HLS and DASH playlists are downloaded segment by segment (see downloadPlaylist)
+func (c *Context) downloadPicture(picture, output string) (string, int64, error) {
+	if output == "" {
+		response, err := c.getPicture(picture)
+		if err != nil {
+			return "", 0, err
+		}
+		defer response.Body.Close()
+		size, err := io.Copy(io.Discard, response.Body)
+		return "", size, err
+	}
+	if kind := playlistType(picture); kind != "" {
+		return c.downloadPlaylist(picture, output, kind)
+	}
+
+	partial := c.claim(output + partialExtension)
+	if _, err := os.Stat(partial); err != nil {
+		// nothing to resume: choose a name not already used
+		c.release(partial)
+		partial = c.claim(uniqueName(output) + partialExtension)
+	}
+	defer c.release(partial)
+
+	contentType := ""
+	for attempt := 0; ; attempt++ {
+		written, mediaType, err := c.savePicture(picture, partial)
+		if mediaType != "" {
+			contentType = mediaType
+		}
+		if err == nil {
+			break
+		}
+		if written == 0 || attempt >= maxResumes || !isTransferError(err) {
+			if size, _ := fileSize(partial); size == 0 {
+				removePartial(partial)
 			}
+			return "", 0, err
 		}
+		log.Printf("Download of %s interrupted (%v): resuming", picture, err)
+	}
+
+	final := strings.TrimSuffix(partial, partialExtension)
+	if path.Ext(final) == "" {
+		final += extensionByType(contentType)
+	}
+	final = uniqueName(final)
+	if err := os.Rename(partial, final); err != nil {
+		return "", 0, err
+	}
+	removePartial(partial)
+	size, err := fileSize(final)
+	return final, size, err
+}
+
+// archivedPicture saves the picture found in the archive of the page, like downloadPicture does.
+// The error wraps os.ErrNotExist when there's no archive, or the picture is not in it
+func (c *Context) archivedPicture(picture, output string) (string, int64, error) {
+	if c.cfg.Archive == nil || output == "" {
+		return "", 0, os.ErrNotExist
+	}
+	reader, contentType, err := c.cfg.Archive.Open(picture)
+	if err != nil {
+		return "", 0, err
+	}
+	defer reader.Close()
+
+	if path.Ext(output) == "" {
+		output += extensionByType(contentType)
+	}
+	partial := c.claim(uniqueName(output) + partialExtension)
+	defer c.release(partial)
+	outputFile, err := os.Create(partial)
+	if err != nil {
+		return "", 0, err
+	}
+	_, err = io.Copy(outputFile, reader)
+	if closeErr := outputFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(partial)
+		return "", 0, fmt.Errorf("cannot read %s from the archive: %w", picture, err)
+	}
+	final := uniqueName(strings.TrimSuffix(partial, partialExtension))
+	if err := os.Rename(partial, final); err != nil {
+		return "", 0, err
 	}
+	size, err := fileSize(final)
+	return final, size, err
 }
 
-func (c *Context) downloadPicture(picture, output string) (int64, error) {
+// savePicture downloads the picture into the partial file, resuming from its current size.
+// A download is only resumed with the validator (ETag or Last-Modified) the server gave for the partial file,
+// so the server sends the whole file again when it has changed.
+// It returns the number of bytes written and the content type of the response
+func (c *Context) savePicture(picture, partial string) (int64, string, error) {
+	offset, _ := fileSize(partial)
+	validator := readValidator(partial)
+	if validator == "" {
+		// without validator, there's no way to know the partial file is still valid
+		offset = 0
+	}
 	request, err := http.NewRequest("GET", picture, nil)
 	if err != nil {
-		return 0, err
+		return 0, "", err
 	}
 	c.setPictureDownloadHeaders(request)
-
-	// Output file
-	if output == "" {
-		output = os.DevNull
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		request.Header.Set("If-Range", validator)
 	}
 
 	response, err := client.Do(request)
 	if err != nil {
-		return 0, err
+		return 0, "", err
 	}
 	defer response.Body.Close()
 
-	if response.StatusCode >= 200 && response.StatusCode < 400 {
-		outputFile, err := os.Create(output)
+	contentType := response.Header.Get("Content-Type")
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case offset > 0 && response.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		if rangeTotal(response) == offset {
+			// the partial file is already complete
+			return 0, contentType, nil
+		}
+		// the file is not the same size on the server anymore
+		log.Printf("Partial download of %s doesn't match the file on the server: downloading it again", picture)
+		response.Body.Close()
+		removePartial(partial)
+		return c.savePicture(picture, partial)
+	case offset > 0 && response.StatusCode == http.StatusPartialContent && rangeStart(response) == offset:
+		log.Printf("Resuming download of %s after %d bytes", picture, offset)
+		flags = os.O_WRONLY | os.O_APPEND
+	case offset > 0 && response.StatusCode == http.StatusPartialContent:
+		// appending this range would corrupt the file
+		log.Printf("Partial content of %s doesn't start at the end of the partial download: downloading it again", picture)
+		response.Body.Close()
+		removePartial(partial)
+		return c.savePicture(picture, partial)
+	case response.StatusCode < 200 || response.StatusCode >= 400 || response.StatusCode == http.StatusPartialContent:
+		return 0, contentType, &StatusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+
+	outputFile, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return 0, contentType, err
+	}
+	if flags&os.O_TRUNC != 0 {
+		writeValidator(partial, responseValidator(response))
+	}
+	// images shouldn't come back gzip encoded, but we never know
+	var reader io.Reader = response.Body
+	if response.Header.Get("Content-Encoding") == "gzip" {
+		reader, err = gzip.NewReader(response.Body)
 		if err != nil {
-			return 0, err
+			outputFile.Close()
+			return 0, contentType, err
 		}
-		defer outputFile.Close()
-
-		// images shouldn't come back gzip encoded, but we never know
-		reader := response.Body
-		if response.Header.Get("Content-Encoding") == "gzip" {
-			reader, err = gzip.NewReader(response.Body)
-			if err != nil {
-				return 0, err
-			}
+	}
+	written, err := io.Copy(outputFile, reader)
+	if closeErr := outputFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && response.ContentLength > 0 && response.Header.Get("Content-Encoding") == "" && written < response.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
+	return written, contentType, err
+}
+
+// rangeStart returns the first byte of the partial content of a response, or -1
+func rangeStart(response *http.Response) int64 {
+	var start, end, total int64
+	contentRange := response.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/*", &start, &end); err != nil {
+			return -1
 		}
+	}
+	return start
+}
 
-		size, err := io.Copy(outputFile, reader)
-		if err != nil {
-			return size, err
+// rangeTotal returns the size of the file given by the Content-Range of a response, like "bytes */1000", or -1
+func rangeTotal(response *http.Response) int64 {
+	contentRange := response.Header.Get("Content-Range")
+	total := int64(-1)
+	if slash := strings.LastIndex(contentRange, "/"); slash >= 0 {
+		if _, err := fmt.Sscanf(contentRange[slash+1:], "%d", &total); err != nil {
+			return -1
 		}
-		return size, nil
 	}
-	return 0, fmt.Errorf("HTTP %s", response.Status)
+	return total
+}
+
+// isTransferError returns true when the connection was broken during the transfer, so the download can be resumed
+func isTransferError(err error) bool {
+	var statusError *StatusError
+	var pathError *os.PathError
+	return !errors.As(err, &statusError) && !errors.As(err, &pathError)
+}
+
+// getPicture sends the request of a picture and checks the status of the response
+func (c *Context) getPicture(picture string) (*http.Response, error) {
+	request, err := http.NewRequest("GET", picture, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setPictureDownloadHeaders(request)
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 400 {
+		response.Body.Close()
+		return nil, &StatusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+	return response, nil
+}
+
+// claim reserves a partial file for a download of this context. When the file is already used by another download,
+// a unique name derived from it is reserved instead
+func (c *Context) claim(partial string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	candidate := partial
+	output := strings.TrimSuffix(partial, partialExtension)
+	extension := path.Ext(output)
+	for index := 1; c.active[candidate]; index++ {
+		candidate = fmt.Sprintf("%s(%d)%s%s", strings.TrimSuffix(output, extension), index, extension, partialExtension)
+	}
+	c.active[candidate] = true
+	return candidate
+}
+
+func (c *Context) release(filename string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.active, filename)
 }
 
 func (c *Context) setHTMLDownloadHeaders(request *http.Request) {
@@ -1,12 +1,24 @@
 package download
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"gallery-downloader/config"
 	"gallery-downloader/headers"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -151,7 +163,7 @@ func TestDownloadPictureNoAuthorization(t *testing.T) {
 		Referer: "test://referer",
 		Browser: testBrowserConfiguration,
 	})
-	size, err := download.downloadPicture(ts.URL, "")
+	_, size, err := download.downloadPicture(ts.URL, "")
 	if err != nil {
 		t.Fatalf("downloadPicture returned an error: %v", err)
 	}
@@ -178,7 +190,7 @@ func TestDownloadPictureWithAuthorization(t *testing.T) {
 		User:     "myuser",
 		Password: "mypassword",
 	})
-	size, err := download.downloadPicture(ts.URL, "")
+	_, size, err := download.downloadPicture(ts.URL, "")
 	if err != nil {
 		t.Fatalf("downloadPicture returned an error: %v", err)
 	}
@@ -238,3 +250,345 @@ func TestDownloadHTMLwithHTTPS(t *testing.T) {
 		t.Fatalf("buffer length should be 14 but returned %d", len(buffer))
 	}
 }
+
+func TestDownloadPictureFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/thumbs/picture.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "thumbnail")
+	}))
+	defer ts.Close()
+
+	events := make([]Event, 0)
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Output:  output,
+		Progress: func(progress Progress) {
+			events = append(events, progress.Event)
+		},
+	})
+	download.Pictures([]Picture{
+		{URL: ts.URL + "/full/picture.jpg", Fallback: ts.URL + "/thumbs/picture.jpg"},
+		{URL: ts.URL + "/full/missing.jpg"},
+	})
+	assert.Equal(t, []Event{EventStart, EventFallback, EventStart, EventFinished, EventStart, EventError}, events)
+
+	content, err := os.ReadFile(path.Join(output, "picture.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "thumbnail", string(content))
+	assert.NoFileExists(t, path.Join(output, "missing.jpg"))
+}
+
+func TestDownloadStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, path.Base(r.URL.Path))
+	}))
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser:  testBrowserConfiguration,
+		Output:   output,
+		Parallel: 2,
+	})
+	pictures := make(chan Picture)
+	go func() {
+		for _, name := range []string{"1.jpg", "2.jpg", "3.jpg"} {
+			pictures <- Picture{URL: ts.URL + "/" + name}
+		}
+		close(pictures)
+	}()
+	assert.Equal(t, 3, download.Stream(pictures))
+
+	for _, name := range []string{"1.jpg", "2.jpg", "3.jpg"} {
+		content, err := os.ReadFile(path.Join(output, name))
+		require.NoError(t, err)
+		assert.Equal(t, name, string(content))
+	}
+}
+
+func TestDownloadHTMLReaderGzip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		writer := gzip.NewWriter(w)
+		fmt.Fprint(writer, "<html></html>")
+		writer.Close()
+	}))
+	defer ts.Close()
+
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+	})
+	reader, err := download.HTMLReader(ts.URL)
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "<html></html>", string(content))
+
+	_, err = download.HTMLReader(ts.URL + "/%zz")
+	assert.Error(t, err)
+}
+
+func TestDownloadHTMLCharset(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		// "Фото" in windows-1251
+		w.Write([]byte{'<', 'p', '>', 0xd4, 0xee, 0xf2, 0xee, '<', '/', 'p', '>'})
+	}))
+	defer ts.Close()
+
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+	})
+	buffer, err := download.HTML(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Фото</p>", string(buffer))
+
+	// the charset given by the user wins
+	download = NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Charset: "iso-8859-5",
+	})
+	buffer, err = download.HTML(ts.URL)
+	require.NoError(t, err)
+	assert.NotEqual(t, "<p>Фото</p>", string(buffer))
+}
+
+func TestDownloadData(t *testing.T) {
+	binary := []byte{0x1f, 0x8b, 0xd4, 0xee, 0xf2, 0xee}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		w.Write(binary)
+	}))
+	defer ts.Close()
+
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+	})
+	// the file is not converted
+	data, err := download.Data(ts.URL, 10)
+	require.NoError(t, err)
+	assert.Equal(t, binary, data)
+
+	_, err = download.Data(ts.URL, 5)
+	assert.Error(t, err)
+}
+
+// mediaServer serves a large media file with range requests. The first full download is cut in the middle
+func mediaServer(t *testing.T, content []byte, modified time.Time) (*httptest.Server, *int32) {
+	t.Helper()
+	requests := new(int32)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) == 1 && r.Header.Get("Range") == "" {
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+			w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			connection, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			connection.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp4")
+		http.ServeContent(w, r, "", modified, bytes.NewReader(content))
+	}))
+	return ts, requests
+}
+
+func TestDownloadPictureResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	modified := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	ts, requests := mediaServer(t, content, modified)
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Output:  output,
+	})
+	download.Pictures([]Picture{{URL: ts.URL + "/clip.mp4"}})
+
+	saved, err := os.ReadFile(path.Join(output, "clip.mp4"))
+	require.NoError(t, err)
+	assert.Equal(t, content, saved)
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+	assert.NoFileExists(t, path.Join(output, "clip.mp4.part"))
+}
+
+func TestDownloadPictureResumePartialFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	modified := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	lastModified := modified.Format(http.TimeFormat)
+	ranges := make([]string, 0)
+	etag := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, "clip.webm", modified, bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	testData := []struct {
+		name      string
+		partial   []byte
+		validator string
+		etag      string
+		requests  []string
+	}{
+		{"resumed", content[:3000], lastModified, "", []string{"bytes=3000-"}},
+		{"resumed with etag", content[:3000], `"v1"`, `"v1"`, []string{"bytes=3000-"}},
+		{"complete", content, lastModified, "", []string{"bytes=10000-"}},
+		{"changed on the server", []byte("another file"), modified.Add(-time.Hour).Format(http.TimeFormat), "", []string{"bytes=12-"}},
+		{"etag changed on the server", content[:3000], `"v1"`, `"v2"`, []string{"bytes=3000-"}},
+		{"shrunk on the server", append(append([]byte{}, content...), "more"...), lastModified, "", []string{"bytes=10004-", ""}},
+		{"no validator", content[:3000], "", "", []string{""}},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			ranges = ranges[:0]
+			etag = testItem.etag
+			output := t.TempDir()
+			partial := path.Join(output, "clip.webm.part")
+			require.NoError(t, os.WriteFile(partial, testItem.partial, 0644))
+			if testItem.validator != "" {
+				require.NoError(t, os.WriteFile(partial+validatorExtension, []byte(testItem.validator), 0644))
+			}
+
+			download := NewContext(Config{
+				Browser: testBrowserConfiguration,
+				Output:  output,
+			})
+			saved, size, err := download.downloadPicture(ts.URL+"/clip.webm", path.Join(output, "clip.webm"))
+			require.NoError(t, err)
+			assert.Equal(t, testItem.requests, ranges)
+			assert.Equal(t, path.Join(output, "clip.webm"), saved)
+			assert.Equal(t, int64(len(content)), size)
+			file, err := os.ReadFile(saved)
+			require.NoError(t, err)
+			assert.Equal(t, content, file)
+			assert.NoFileExists(t, partial)
+			assert.NoFileExists(t, partial+validatorExtension)
+		})
+	}
+}
+
+func TestDownloadPictureResumeWrongRange(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	lastModified := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	ranges := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("Range") != "" {
+			// the range starts at the wrong place
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 1000-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[1000:])
+			return
+		}
+		w.Write(content)
+	}))
+	defer ts.Close()
+
+	output := t.TempDir()
+	partial := path.Join(output, "clip.webm.part")
+	require.NoError(t, os.WriteFile(partial, content[:3000], 0644))
+	require.NoError(t, os.WriteFile(partial+validatorExtension, []byte(lastModified), 0644))
+
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Output:  output,
+	})
+	saved, size, err := download.downloadPicture(ts.URL+"/clip.webm", path.Join(output, "clip.webm"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bytes=3000-", ""}, ranges)
+	assert.Equal(t, int64(len(content)), size)
+	file, err := os.ReadFile(saved)
+	require.NoError(t, err)
+	assert.Equal(t, content, file)
+	assert.NoFileExists(t, partial)
+}
+
+func TestDownloadPictureExtensionFromType(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "video/webm")
+		fmt.Fprint(w, "webm")
+	}))
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Output:  output,
+	})
+	download.Pictures([]Picture{{URL: ts.URL + "/media/clip"}, {URL: ts.URL + "/media/other.bin"}, {URL: ts.URL + "/missing"}})
+
+	assert.FileExists(t, path.Join(output, "clip.webm"))
+	// an extension is never replaced
+	assert.FileExists(t, path.Join(output, "other.bin"))
+	assert.NoFileExists(t, path.Join(output, "missing.part"))
+	assert.NoFileExists(t, path.Join(output, "missing"))
+}
+
+// testArchive is an archive of files in memory
+type testArchive map[string]string
+
+func (a testArchive) Open(link string) (io.ReadCloser, string, error) {
+	content, found := a[link]
+	if !found {
+		return nil, "", os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(content)), "image/png", nil
+}
+
+func TestDownloadPictureFromArchive(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, "downloaded")
+	}))
+	defer ts.Close()
+
+	archived := make([]bool, 0)
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Output:  output,
+		Archive: testArchive{
+			ts.URL + "/archived.jpg": "archived",
+			"page_files/image":       "relative",
+		},
+		Progress: func(progress Progress) {
+			if progress.Event == EventFinished {
+				archived = append(archived, progress.Archived)
+			}
+		},
+	})
+	download.Pictures([]Picture{
+		{URL: ts.URL + "/archived.jpg"},
+		{URL: "page_files/image"},
+		{URL: ts.URL + "/missing.jpg"},
+		{URL: "page_files/missing.jpg"},
+	})
+	assert.Equal(t, []bool{true, true, false}, archived)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	for name, expected := range map[string]string{"archived.jpg": "archived", "image.png": "relative", "missing.jpg": "downloaded"} {
+		content, err := os.ReadFile(path.Join(output, name))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+	assert.NoFileExists(t, path.Join(output, "image.png.part"))
+}
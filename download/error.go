@@ -0,0 +1,22 @@
+package download
+
+import (
+	"errors"
+	"net/http"
+)
+
+// StatusError is returned when the server answers with an HTTP error status
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "HTTP " + e.Status
+}
+
+// IsNotFound returns true when the error is an HTTP 404 answer
+func IsNotFound(err error) bool {
+	var statusError *StatusError
+	return errors.As(err, &statusError) && statusError.StatusCode == http.StatusNotFound
+}
@@ -2,11 +2,78 @@ package download
 
 import (
 	"fmt"
+	"mime"
+	"net/http"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 )
 
+const (
+	// partialExtension is added to the name of a file while it's downloading
+	partialExtension = ".part"
+	// validatorExtension is added to the name of a partial file to keep the ETag or the Last-Modified date of the server
+	validatorExtension = ".validator"
+	// maxResumes is the number of times an interrupted download is resumed
+	maxResumes = 3
+)
+
+// extensions of the most common types, as the system list can give many extensions for one type
+var typeExtensions = map[string]string{
+	"image/jpeg":       ".jpg",
+	"image/png":        ".png",
+	"image/gif":        ".gif",
+	"image/webp":       ".webp",
+	"image/avif":       ".avif",
+	"image/svg+xml":    ".svg",
+	"video/mp4":        ".mp4",
+	"video/webm":       ".webm",
+	"video/ogg":        ".ogv",
+	"video/quicktime":  ".mov",
+	"video/x-matroska": ".mkv",
+	"audio/mpeg":       ".mp3",
+	"audio/mp4":        ".m4a",
+	"audio/ogg":        ".ogg",
+	"audio/webm":       ".weba",
+	"audio/wav":        ".wav",
+	"audio/flac":       ".flac",
+}
+
+// maxNameLength is the maximum length (in bytes) of a name created from a title
+const maxNameLength = 100
+
+var invalidNameCharacters = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+
+// SafeName converts a title into a name valid for a file or a folder on every system.
+// It returns an empty string when nothing is left of the title
+func SafeName(title string) string {
+	name := invalidNameCharacters.ReplaceAllString(title, "_")
+	name = strings.Join(strings.Fields(name), " ")
+	if len(name) > maxNameLength {
+		name = strings.ToValidUTF8(name[:maxNameLength], "")
+	}
+	return strings.Trim(name, " .")
+}
+
+// pictureName returns the name of the file of a picture: its title when it has one, or the last part of its path.
+// The title keeps the extension found in the path
+func pictureName(picture Picture, picturePath string) string {
+	name := path.Base(picturePath)
+	if name == "/" || name == "." {
+		name = ""
+	}
+	title := SafeName(picture.Title)
+	if title == "" {
+		return name
+	}
+	extension := path.Ext(name)
+	if strings.EqualFold(path.Ext(title), extension) {
+		return title
+	}
+	return title + extension
+}
+
 // uniqueName checks the file already exists: if yes it adds a (n) at the end
 func uniqueName(filename string) string {
 	if _, err := os.Stat(filename); err == nil || os.IsExist(err) {
@@ -23,3 +90,62 @@ func uniqueName(filename string) string {
 	}
 	return filename
 }
+
+// extensionByType returns the extension of the files of a content type, like ".mp4" for "video/mp4".
+// It returns an empty string when the type is unknown
+func extensionByType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if extension, found := typeExtensions[mediaType]; found {
+		return extension
+	}
+	extensions, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(extensions) == 0 {
+		return ""
+	}
+	return extensions[0]
+}
+
+// fileSize returns the size of a file, or 0 when it doesn't exist
+func fileSize(filename string) (int64, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// responseValidator returns the validator of a response that can be sent in an If-Range header:
+// its strong ETag, or its Last-Modified date. It returns an empty string when the response has none
+func responseValidator(response *http.Response) string {
+	if etag := strings.TrimSpace(response.Header.Get("ETag")); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return strings.TrimSpace(response.Header.Get("Last-Modified"))
+}
+
+// readValidator returns the validator saved with a partial file, or an empty string
+func readValidator(partial string) string {
+	content, err := os.ReadFile(partial + validatorExtension)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// writeValidator saves the validator of the server next to the partial file, so the download can be resumed later
+func writeValidator(partial, validator string) {
+	if validator == "" {
+		_ = os.Remove(partial + validatorExtension)
+		return
+	}
+	_ = os.WriteFile(partial+validatorExtension, []byte(validator), 0644)
+}
+
+// removePartial deletes a partial file and its validator
+func removePartial(partial string) {
+	_ = os.Remove(partial)
+	_ = os.Remove(partial + validatorExtension)
+}
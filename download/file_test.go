@@ -0,0 +1,46 @@
+package download
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeName(t *testing.T) {
+	testData := []struct {
+		title    string
+		expected string
+	}{
+		{"Summer 2020", "Summer 2020"},
+		{"  Beach:  day 1/2 ", "Beach_ day 1_2"},
+		{"...", ""},
+		{"what?*", "what_"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.title, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, SafeName(testItem.title))
+		})
+	}
+}
+
+func TestPictureName(t *testing.T) {
+	testData := []struct {
+		name     string
+		picture  Picture
+		path     string
+		expected string
+	}{
+		{"no title", Picture{}, "/images/001.jpg", "001.jpg"},
+		{"title", Picture{Title: "Sunset"}, "/images/001.jpg", "Sunset.jpg"},
+		{"title with extension", Picture{Title: "sunset.JPG"}, "/images/001.jpg", "sunset.JPG"},
+		{"invalid title", Picture{Title: "//"}, "/images/001.jpg", "_.jpg"},
+		{"empty title", Picture{Title: " . "}, "/images/001.jpg", "001.jpg"},
+		{"title without path", Picture{Title: "Sunset"}, "/", "Sunset"},
+		{"no name", Picture{}, "/", ""},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, pictureName(testItem.picture, testItem.path))
+		})
+	}
+}
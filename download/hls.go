@@ -0,0 +1,279 @@
+package download
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// hlsVariant is a version of the media listed in a master playlist
+type hlsVariant struct {
+	uri        string
+	bandwidth  int64
+	audioGroup string
+}
+
+// hlsRendition is an alternative media of a master playlist, like the audio of the variants
+type hlsRendition struct {
+	uri       string
+	group     string
+	isDefault bool
+}
+
+// hlsStreams reads an HLS playlist. A master playlist gives the variant with the highest bandwidth,
+// and its audio when it's kept in another playlist
+func (c *Context) hlsStreams(playlistURL *url.URL, content []byte) ([]mediaStream, error) {
+	if !isHLS(content) {
+		return nil, errors.New("not an HLS playlist")
+	}
+	variants, renditions, err := parseHLSMaster(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		stream, err := parseHLSMedia(playlistURL, content)
+		if err != nil {
+			return nil, err
+		}
+		return []mediaStream{stream}, nil
+	}
+
+	best := variants[0]
+	for _, variant := range variants[1:] {
+		if variant.bandwidth > best.bandwidth {
+			best = variant
+		}
+	}
+	log.Printf("Using HLS variant %s (bandwidth %d)", best.uri, best.bandwidth)
+	video, err := c.hlsMediaStream(playlistURL, best.uri)
+	if err != nil {
+		return nil, err
+	}
+	streams := []mediaStream{video}
+
+	if audio := hlsAudio(renditions, best.audioGroup); audio != nil {
+		stream, err := c.hlsMediaStream(playlistURL, audio.uri)
+		if err != nil {
+			return nil, err
+		}
+		stream.suffix = ".audio"
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// hlsMediaStream downloads and reads a media playlist
+func (c *Context) hlsMediaStream(base *url.URL, uri string) (mediaStream, error) {
+	playlistURL, err := resolve(base, uri)
+	if err != nil {
+		return mediaStream{}, err
+	}
+	content, err := c.fetchPlaylist(playlistURL)
+	if err != nil {
+		return mediaStream{}, err
+	}
+	if !isHLS(content) {
+		return mediaStream{}, fmt.Errorf("%s is not an HLS playlist", playlistURL)
+	}
+	return parseHLSMedia(playlistURL, content)
+}
+
+// hlsAudio returns the audio rendition of a group, the default one first. Renditions without URI are in the variant itself
+func hlsAudio(renditions []hlsRendition, group string) *hlsRendition {
+	var found *hlsRendition
+	for i, rendition := range renditions {
+		if group == "" || rendition.group != group || rendition.uri == "" {
+			continue
+		}
+		if found == nil || (rendition.isDefault && !found.isDefault) {
+			found = &renditions[i]
+		}
+	}
+	return found
+}
+
+func isHLS(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))), []byte("#EXTM3U"))
+}
+
+// parseHLSMaster returns the variants and the audio renditions of a master playlist.
+// A media playlist has no variant
+func parseHLSMaster(content []byte) ([]hlsVariant, []hlsRendition, error) {
+	variants := make([]hlsVariant, 0)
+	renditions := make([]hlsRendition, 0)
+	var pending *hlsVariant
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), maxPlaylistSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attributes := hlsAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bandwidth, _ := strconv.ParseInt(attributes["BANDWIDTH"], 10, 64)
+			pending = &hlsVariant{bandwidth: bandwidth, audioGroup: attributes["AUDIO"]}
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			attributes := hlsAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))
+			if attributes["TYPE"] == "AUDIO" {
+				renditions = append(renditions, hlsRendition{
+					uri:       attributes["URI"],
+					group:     attributes["GROUP-ID"],
+					isDefault: attributes["DEFAULT"] == "YES",
+				})
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case pending != nil:
+			pending.uri = line
+			variants = append(variants, *pending)
+			pending = nil
+		}
+	}
+	return variants, renditions, scanner.Err()
+}
+
+// parseHLSMedia returns the segments of a media playlist
+func parseHLSMedia(playlistURL *url.URL, content []byte) (mediaStream, error) {
+	stream := mediaStream{segments: make([]segment, 0)}
+	fragmented := false
+	ended := false
+	// byte range of the next segment, and end of the previous one in the same file
+	var length, offset int64
+	var previousURL string
+	var previousEnd int64
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), maxPlaylistSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attributes := hlsAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			if method := attributes["METHOD"]; method != "" && method != "NONE" {
+				return stream, fmt.Errorf("encrypted HLS playlists are not supported (%s)", method)
+			}
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			attributes := hlsAttributes(strings.TrimPrefix(line, "#EXT-X-MAP:"))
+			initURL, err := resolve(playlistURL, attributes["URI"])
+			if err != nil {
+				return stream, err
+			}
+			part := segment{url: initURL.String()}
+			if byteRange := attributes["BYTERANGE"]; byteRange != "" {
+				part.length, part.offset, err = parseByteRange(byteRange, 0)
+				if err != nil {
+					return stream, err
+				}
+			}
+			stream.segments = append(stream.segments, part)
+			fragmented = true
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			var err error
+			length, offset, err = parseByteRange(strings.TrimPrefix(line, "#EXT-X-BYTERANGE:"), -1)
+			if err != nil {
+				return stream, err
+			}
+		case line == "#EXT-X-ENDLIST":
+			ended = true
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		default:
+			segmentURL, err := resolve(playlistURL, line)
+			if err != nil {
+				return stream, err
+			}
+			part := segment{url: segmentURL.String()}
+			if length > 0 {
+				part.length = length
+				part.offset = offset
+				if offset < 0 {
+					// the segment follows the previous one
+					part.offset = 0
+					if part.url == previousURL {
+						part.offset = previousEnd
+					}
+				}
+				previousURL = part.url
+				previousEnd = part.offset + part.length
+			}
+			stream.segments = append(stream.segments, part)
+			length, offset = 0, 0
+			if !fragmented {
+				fragmented = isFragmentedSegment(segmentURL.Path)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stream, err
+	}
+	if !ended {
+		log.Printf("Live HLS playlist: only the %d segments currently listed are downloaded", len(stream.segments))
+	}
+	stream.extension = ".ts"
+	if fragmented {
+		stream.extension = ".mp4"
+	} else if len(stream.segments) > 0 {
+		switch extension := strings.ToLower(path.Ext(stream.segments[0].url)); extension {
+		case ".aac", ".mp3", ".ac3":
+			stream.extension = extension
+		}
+	}
+	return stream, nil
+}
+
+// isFragmentedSegment returns true for the fragmented MP4 (CMAF) segments
+func isFragmentedSegment(segmentPath string) bool {
+	switch strings.ToLower(path.Ext(segmentPath)) {
+	case ".m4s", ".mp4", ".m4v", ".m4a", ".cmfv", ".cmfa":
+		return true
+	}
+	return false
+}
+
+// parseByteRange reads a "length[@offset]" range. The offset is defaultOffset when missing
+func parseByteRange(value string, defaultOffset int64) (int64, int64, error) {
+	lengthValue, offsetValue, hasOffset := strings.Cut(strings.TrimSpace(value), "@")
+	length, err := strconv.ParseInt(lengthValue, 10, 64)
+	if err != nil || length <= 0 {
+		return 0, 0, fmt.Errorf("invalid byte range %q", value)
+	}
+	if !hasOffset {
+		return length, defaultOffset, nil
+	}
+	offset, err := strconv.ParseInt(offsetValue, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid byte range %q", value)
+	}
+	return length, offset, nil
+}
+
+// hlsAttributes reads a list of attributes like BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2"
+func hlsAttributes(list string) map[string]string {
+	attributes := make(map[string]string)
+	for list != "" {
+		name, rest, found := strings.Cut(list, "=")
+		if !found {
+			break
+		}
+		value := ""
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attributes[strings.ToUpper(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		list = strings.TrimSpace(rest)
+	}
+	return attributes
+}
@@ -0,0 +1,244 @@
+package download
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// playlistServer serves the files of a map, with range requests, and records the paths requested
+func playlistServer(files map[string]string) (*httptest.Server, func() []string) {
+	lock := sync.Mutex{}
+	requested := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requested = append(requested, r.URL.Path)
+		lock.Unlock()
+		content, found := files[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, bytes.NewReader([]byte(content)))
+	}))
+	return ts, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, requested...)
+	}
+}
+
+func TestDownloadHLSMasterPlaylist(t *testing.T) {
+	ts, requested := playlistServer(map[string]string{
+		"/video/master.m3u8": `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2"
+high/index.m3u8
+`,
+		"/video/high/index.m3u8": `#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.0,
+segment0.ts
+#EXTINF:4.0,
+segment1.ts
+#EXTINF:4.0,
+/video/high/segment2.ts
+#EXTINF:2.5,
+segment3.ts
+#EXT-X-ENDLIST
+`,
+		"/video/high/segment0.ts": "first-",
+		"/video/high/segment1.ts": "second-",
+		"/video/high/segment2.ts": "third-",
+		"/video/high/segment3.ts": "fourth",
+	})
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser:  testBrowserConfiguration,
+		Output:   output,
+		Parallel: 3,
+	})
+	download.Pictures([]Picture{{URL: ts.URL + "/video/master.m3u8"}})
+
+	saved, err := os.ReadFile(path.Join(output, "master.ts"))
+	require.NoError(t, err)
+	assert.Equal(t, "first-second-third-fourth", string(saved))
+	assert.NotContains(t, requested(), "/video/low/index.m3u8")
+	entries, err := os.ReadDir(output)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the temporary files should be removed")
+}
+
+func TestDownloadHLSFragmentedByteRanges(t *testing.T) {
+	ts, _ := playlistServer(map[string]string{
+		"/clip.m3u8": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-MAP:URI="media.mp4",BYTERANGE="4@0"
+#EXTINF:2.0,
+#EXT-X-BYTERANGE:5@4
+media.mp4
+#EXTINF:2.0,
+#EXT-X-BYTERANGE:6
+media.mp4
+#EXT-X-ENDLIST
+`,
+		"/media.mp4": "initfirstsecond",
+	})
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Output:  output,
+	})
+	saved, size, err := download.downloadPicture(ts.URL+"/clip.m3u8", path.Join(output, "clip.m3u8"))
+	require.NoError(t, err)
+	assert.Equal(t, path.Join(output, "clip.mp4"), saved)
+	assert.Equal(t, int64(15), size)
+	content, err := os.ReadFile(saved)
+	require.NoError(t, err)
+	assert.Equal(t, "initfirstsecond", string(content))
+}
+
+func TestDownloadHLSSeparateAudio(t *testing.T) {
+	ts, _ := playlistServer(map[string]string{
+		"/master.m3u8": `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="French",DEFAULT=YES,URI="audio/fr.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,AUDIO="aac"
+video.m3u8
+`,
+		"/video.m3u8":    "#EXTM3U\n#EXTINF:4,\nvideo.ts\n#EXT-X-ENDLIST\n",
+		"/video.ts":      "video",
+		"/audio/fr.m3u8": "#EXTM3U\n#EXTINF:4,\nfr.aac\n#EXT-X-ENDLIST\n",
+		"/audio/fr.aac":  "french",
+	})
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser: testBrowserConfiguration,
+		Output:  output,
+	})
+	saved, size, err := download.downloadPicture(ts.URL+"/master.m3u8", path.Join(output, "movie.m3u8"))
+	require.NoError(t, err)
+	assert.Equal(t, path.Join(output, "movie.ts"), saved)
+	assert.Equal(t, int64(11), size)
+	audio, err := os.ReadFile(path.Join(output, "movie.audio.aac"))
+	require.NoError(t, err)
+	assert.Equal(t, "french", string(audio))
+}
+
+func TestDownloadHLSErrors(t *testing.T) {
+	ts, _ := playlistServer(map[string]string{
+		"/encrypted.m3u8":       "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n#EXTINF:4,\nsegment.ts\n#EXT-X-ENDLIST\n",
+		"/missing-segment.m3u8": "#EXTM3U\n#EXTINF:4,\nmissing.ts\n#EXT-X-ENDLIST\n",
+		"/not-a-playlist.m3u8":  "<html></html>",
+	})
+	defer ts.Close()
+
+	for _, name := range []string{"encrypted", "missing-segment", "not-a-playlist", "not-found"} {
+		t.Run(name, func(t *testing.T) {
+			output := t.TempDir()
+			download := NewContext(Config{
+				Browser: testBrowserConfiguration,
+				Output:  output,
+			})
+			_, _, err := download.downloadPicture(ts.URL+"/"+name+".m3u8", path.Join(output, name+".m3u8"))
+			assert.Error(t, err)
+			entries, err := os.ReadDir(output)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestParseHLSMediaExtension(t *testing.T) {
+	playlistURL, _ := url.Parse("http://localhost/path/index.m3u8")
+	testData := []struct {
+		content   string
+		extension string
+	}{
+		{"#EXTM3U\n#EXTINF:4,\nsegment.ts\n", ".ts"},
+		{"#EXTM3U\n#EXTINF:4,\nsegment.m4s?token=1\n", ".mp4"},
+		{"#EXTM3U\n#EXTINF:4,\nsegment.aac\n", ".aac"},
+		{"#EXTM3U\n#EXTINF:4,\nsegment\n", ".ts"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.extension, func(t *testing.T) {
+			stream, err := parseHLSMedia(playlistURL, []byte(testItem.content))
+			require.NoError(t, err)
+			assert.Equal(t, testItem.extension, stream.extension)
+		})
+	}
+}
+
+func TestHLSAttributes(t *testing.T) {
+	attributes := hlsAttributes(`BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",resolution=1280x720,URI="a=b.m3u8"`)
+	assert.Equal(t, map[string]string{
+		"BANDWIDTH":  "1280000",
+		"CODECS":     "avc1.4d401f,mp4a.40.2",
+		"RESOLUTION": "1280x720",
+		"URI":        "a=b.m3u8",
+	}, attributes)
+}
+
+func TestDownloadHLSSegmentsShareWorkers(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-TARGETDURATION:4\n"
+	for index := 0; index < 6; index++ {
+		playlist += fmt.Sprintf("#EXTINF:4.0,\nsegment%d.ts\n", index)
+	}
+	playlist += "#EXT-X-ENDLIST\n"
+
+	var active, maxActive int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Ext(r.URL.Path) == ".m3u8" {
+			fmt.Fprint(w, playlist)
+			return
+		}
+		current := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			highest := atomic.LoadInt32(&maxActive)
+			if current <= highest || atomic.CompareAndSwapInt32(&maxActive, highest, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, path.Base(r.URL.Path))
+	}))
+	defer ts.Close()
+
+	output := t.TempDir()
+	download := NewContext(Config{
+		Browser:  testBrowserConfiguration,
+		Output:   output,
+		Parallel: 2,
+		WaitMin:  5,
+		WaitMax:  6,
+	})
+	start := time.Now()
+	download.Pictures([]Picture{{URL: ts.URL + "/first.m3u8"}, {URL: ts.URL + "/second.m3u8"}, {URL: ts.URL + "/third.m3u8"}})
+
+	for _, name := range []string{"first.ts", "second.ts", "third.ts"} {
+		assert.FileExists(t, path.Join(output, name))
+	}
+	// never more downloads than the parallel setting, segments included
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxActive), int32(2))
+	// 18 segments of 10ms, with a wait of 5ms after each of them, by 2 workers at most
+	assert.GreaterOrEqual(t, time.Since(start), 18*15*time.Millisecond/2)
+}
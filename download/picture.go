@@ -0,0 +1,10 @@
+package download
+
+// Picture to download
+type Picture struct {
+	URL string
+	// Fallback is downloaded instead when URL is not found on the server (HTTP 404)
+	Fallback string
+	// Title is used as the name of the file when set
+	Title string
+}
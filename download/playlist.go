@@ -0,0 +1,276 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of playlists
+const (
+	playlistHLS  = "hls"
+	playlistDASH = "dash"
+)
+
+// maxPlaylistSize is the maximum size of a playlist file, in bytes
+const maxPlaylistSize = 10 << 20
+
+// segment is a part of a media stream listed in a playlist
+type segment struct {
+	url string
+	// offset and length of the bytes of the segment in the file, when length > 0
+	offset int64
+	length int64
+}
+
+// mediaStream is a media found in a playlist: its segments concatenated make a playable file
+type mediaStream struct {
+	segments  []segment
+	extension string
+	// suffix is added to the name of the file of a secondary stream, like the audio kept apart from the video
+	suffix string
+}
+
+// playlistType returns the type of playlist from the extension of the URL, or an empty string
+func playlistType(link string) string {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(path.Ext(linkURL.Path)) {
+	case ".m3u8":
+		return playlistHLS
+	case ".mpd":
+		return playlistDASH
+	}
+	return ""
+}
+
+// downloadPlaylist downloads the best quality of the media of an HLS or DASH playlist into output,
+// with the extension of the segments. The segments are simply concatenated: there's no transcoding.
+// When the audio is apart from the video, it's saved next to it with an ".audio" suffix.
+// It returns the name of the main file, and the size of all the files
+func (c *Context) downloadPlaylist(link, output, kind string) (string, int64, error) {
+	playlistURL, err := url.Parse(link)
+	if err != nil {
+		return "", 0, err
+	}
+	content, err := c.fetchPlaylist(playlistURL)
+	if err != nil {
+		return "", 0, err
+	}
+	var streams []mediaStream
+	if kind == playlistHLS {
+		streams, err = c.hlsStreams(playlistURL, content)
+	} else {
+		streams, err = dashStreams(playlistURL, content)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	base := strings.TrimSuffix(output, path.Ext(output))
+	saved := ""
+	total := int64(0)
+	for _, stream := range streams {
+		filename, size, err := c.downloadStream(stream, base+stream.suffix+stream.extension)
+		if err != nil {
+			return "", 0, err
+		}
+		if saved == "" {
+			saved = filename
+		}
+		total += size
+	}
+	return saved, total, nil
+}
+
+// fetchPlaylist downloads a playlist file
+func (c *Context) fetchPlaylist(playlistURL *url.URL) ([]byte, error) {
+	response, err := c.getPicture(playlistURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(response.Body, maxPlaylistSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxPlaylistSize {
+		return nil, fmt.Errorf("playlist %s is too large", playlistURL)
+	}
+	return content, nil
+}
+
+// downloadStream downloads the segments into a temporary folder, then concatenates them into output.
+// The segments are downloaded in parallel by the idle workers of the context, waiting between segments like between pictures
+func (c *Context) downloadStream(stream mediaStream, output string) (string, int64, error) {
+	if len(stream.segments) == 0 {
+		return "", 0, fmt.Errorf("no segment found for %s", path.Base(output))
+	}
+	log.Printf("Downloading %d segments into %s", len(stream.segments), path.Base(output))
+	folder, err := os.MkdirTemp(path.Dir(output), ".segments-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.RemoveAll(folder)
+
+	jobs := make(chan int, len(stream.segments))
+	for index := range stream.segments {
+		jobs <- index
+	}
+	close(jobs)
+	lock := sync.Mutex{}
+	var failure error
+	work := func() {
+		for index := range jobs {
+			lock.Lock()
+			failed := failure != nil
+			lock.Unlock()
+			if failed {
+				continue
+			}
+			if err := c.downloadSegment(stream.segments[index], segmentFile(folder, index)); err != nil {
+				lock.Lock()
+				if failure == nil {
+					failure = fmt.Errorf("segment %d: %w", index+1, err)
+				}
+				lock.Unlock()
+				continue
+			}
+			time.Sleep(time.Duration(c.waitTime()) * time.Millisecond)
+		}
+	}
+	// the segments are downloaded by this worker, helped by the idle ones of the context
+	wg := sync.WaitGroup{}
+	for helper := 1; helper < len(stream.segments) && c.acquireIdleSlot(); helper++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-c.slots }()
+			work()
+		}()
+	}
+	work()
+	wg.Wait()
+	if failure != nil {
+		return "", 0, failure
+	}
+
+	partial := c.claim(uniqueName(output) + partialExtension)
+	defer c.release(partial)
+	if err := concatenate(partial, folder, len(stream.segments)); err != nil {
+		_ = os.Remove(partial)
+		return "", 0, err
+	}
+	final := uniqueName(strings.TrimSuffix(partial, partialExtension))
+	if err := os.Rename(partial, final); err != nil {
+		return "", 0, err
+	}
+	size, err := fileSize(final)
+	return final, size, err
+}
+
+// acquireIdleSlot takes a download slot of the context when one is free, without waiting
+func (c *Context) acquireIdleSlot() bool {
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func segmentFile(folder string, index int) string {
+	return path.Join(folder, fmt.Sprintf("%06d", index))
+}
+
+// concatenate writes all the segments in order into output
+func concatenate(output, folder string, count int) error {
+	outputFile, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	for index := 0; index < count; index++ {
+		input, err := os.Open(segmentFile(folder, index))
+		if err != nil {
+			outputFile.Close()
+			return err
+		}
+		_, err = io.Copy(outputFile, input)
+		input.Close()
+		if err != nil {
+			outputFile.Close()
+			return err
+		}
+	}
+	return outputFile.Close()
+}
+
+// downloadSegment saves a segment into a file, trying again when the connection is broken
+func (c *Context) downloadSegment(part segment, filename string) error {
+	for attempt := 0; ; attempt++ {
+		err := c.saveSegment(part, filename)
+		if err == nil || attempt >= maxResumes || !isTransferError(err) {
+			return err
+		}
+	}
+}
+
+func (c *Context) saveSegment(part segment, filename string) error {
+	request, err := http.NewRequest("GET", part.url, nil)
+	if err != nil {
+		return err
+	}
+	c.setPictureDownloadHeaders(request)
+	if part.length > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", part.offset, part.offset+part.length-1))
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 400 {
+		return &StatusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+
+	var reader io.Reader = response.Body
+	if part.length > 0 {
+		if response.StatusCode != http.StatusPartialContent {
+			// the server sent the whole file
+			if _, err := io.CopyN(io.Discard, response.Body, part.offset); err != nil {
+				return err
+			}
+		}
+		reader = io.LimitReader(response.Body, part.length)
+	}
+	outputFile, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(outputFile, reader)
+	if closeErr := outputFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && part.length > 0 && written < part.length {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// resolve returns the absolute URL of a link found in a playlist
+func resolve(base *url.URL, link string) (*url.URL, error) {
+	linkURL, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return nil, err
+	}
+	return base.ResolveReference(linkURL), nil
+}
@@ -8,6 +8,7 @@ const (
 	EventFinished
 	EventNotSaving
 	EventError
+	EventFallback
 )
 
 type Progress struct {
@@ -17,5 +18,7 @@ type Progress struct {
 	Event      Event
 	Err        error
 	Downloaded int64
-	Wait       int
+	// Archived is true when the file was read from the archive of the page instead of being downloaded
+	Archived bool
+	Wait     int
 }
@@ -51,6 +51,51 @@ var (
 			"dir/file",
 			"http://localhost/base/dir/file",
 		},
+		{
+			"http://localhost/base/index",
+			"file.jpg?w=2000&h=1000",
+			"http://localhost/base/file.jpg?w=2000&h=1000",
+		},
+		{
+			"http://localhost/base/index?page=2",
+			"file.jpg",
+			"http://localhost/base/file.jpg",
+		},
+		{
+			"http://localhost/base/index",
+			"?page=3",
+			"http://localhost/base/index?page=3",
+		},
+		{
+			"http://localhost/base/index",
+			"file.jpg#fragment",
+			"http://localhost/base/file.jpg#fragment",
+		},
+		{
+			"http://localhost/base/dir/index",
+			"../file",
+			"http://localhost/base/file",
+		},
+		{
+			"http://localhost/base/dir/index",
+			"../../../../file",
+			"http://localhost/file",
+		},
+		{
+			"http://localhost/base/dir/",
+			"./sub/../file",
+			"http://localhost/base/dir/file",
+		},
+		{
+			"https://localhost/base/index",
+			"//cdn.example.com/file.jpg",
+			"https://cdn.example.com/file.jpg",
+		},
+		{
+			"http://localhost/base/index",
+			"https://cdn.example.com/file.jpg",
+			"https://cdn.example.com/file.jpg",
+		},
 	}
 )
 
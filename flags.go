@@ -10,6 +10,8 @@ import (
 type Flags struct {
 	ConfigFile string
 	Source     string
+	Sources    string
+	Galleries  int
 	Base       string
 	Type       string
 	Output     string
@@ -20,12 +22,26 @@ type Flags struct {
 	// WaitMax     int
 	// Parallel    int
 	InsecureTLS bool
+	Crawl       bool
+	Depth       int
+	Score       bool
+	Explain     bool
+	Profile     string
+	Stream      bool
+	Charset     string
+	Frames      bool
+	Sitemap     bool
+	Pattern     string
+	// Gallery identifies the gallery in the progress lines, when a batch downloads many galleries at the same time
+	Gallery string
 }
 
 func loadFlags() Flags {
 	flags := Flags{}
 	flag.StringVar(&flags.ConfigFile, "config", "config.json", "configuration file")
 	flag.StringVar(&flags.Source, "source", "", "source HTML gallery")
+	flag.StringVar(&flags.Sources, "sources", "", "file listing the sources, one per line with optional output folder and profile (- to read the list from the standard input)")
+	flag.IntVar(&flags.Galleries, "galleries", 1, "with -sources, number of galleries downloaded in parallel")
 	flag.StringVar(&flags.Base, "base", "", "base URL when downloading relative images")
 	flag.StringVar(&flags.Type, "type", scan.AvailableGalleryScanners[0], "type of gallery ("+strings.Join(scan.AvailableGalleryScanners[:], ", ")+")")
 	flag.StringVar(&flags.Output, "output", "", "output folder to store pictures")
@@ -36,6 +52,16 @@ func loadFlags() Flags {
 	// flag.IntVar(&flags.WaitMax, "max-wait", 0, "wait n milliseconds maximum before downloading the next image")
 	// flag.IntVar(&flags.Parallel, "parallel", 1, "download n images in parallel")
 	flag.BoolVar(&flags.InsecureTLS, "insecure-tls", false, "Skip TLS certificate verification. Should only be enabled for testing locally")
+	flag.BoolVar(&flags.Crawl, "crawl", false, "crawl an index page listing albums: each album is saved into its own folder")
+	flag.IntVar(&flags.Depth, "depth", 0, "maximum number of index pages to follow when crawling (default from profile, or 1)")
+	flag.StringVar(&flags.Profile, "profile", "", "name of the profile to use, bypassing the gallery detection")
+	flag.BoolVar(&flags.Score, "score", false, "evaluate all the profiles and pick the one with the best score, instead of the first one matching")
+	flag.BoolVar(&flags.Explain, "explain", false, "display the score of every profile (implies -score)")
+	flag.BoolVar(&flags.Stream, "stream", false, "download the pictures while reading the page, for very large pages (no gallery detection nor pagination)")
+	flag.StringVar(&flags.Charset, "charset", "", "charset of the HTML page, like Shift_JIS or windows-1251 (default is detected from the page)")
+	flag.BoolVar(&flags.Frames, "frames", false, "when no picture is found, look for the gallery in the frames of the page (same site or frameHosts from the configuration)")
+	flag.BoolVar(&flags.Sitemap, "sitemap", false, "read the source as an XML sitemap (or sitemap index) and download the pictures of its <image:image> entries, in a folder per page")
+	flag.StringVar(&flags.Pattern, "pattern", "", "with -sitemap, only keep the pages whose URL matches this regular expression")
 	flag.Parse()
 	return flags
 }
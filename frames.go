@@ -0,0 +1,103 @@
+package main
+
+import (
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+)
+
+// maxFrameDepth is the maximum number of nested frames followed to find a gallery
+const maxFrameDepth = 3
+
+var frameSelector = cascadia.MustCompile("iframe[src], frame[src]")
+
+// framedGallery is a gallery found inside a frame of the page
+type framedGallery struct {
+	url      *url.URL
+	doc      *scan.Document
+	pictures []scan.Record
+	profile  config.Profile
+}
+
+// scanFrames downloads the frames of the page hosted on the same site (or on the frameHosts of the configuration),
+// and returns the first one where pictures are found. Nested frames are followed up to maxFrameDepth
+func scanFrames(pageURL *url.URL, doc *scan.Document, flags Flags, cfg *config.Configuration, depth int, visited map[string]bool) (framedGallery, bool) {
+	node, err := doc.Node()
+	if err != nil {
+		return framedGallery{}, false
+	}
+	base := documentBase(pageURL, doc.Source())
+	for _, frame := range cascadia.QueryAll(node, frameSelector) {
+		frameURL, err := url.Parse(frameSource(frame))
+		if err != nil {
+			continue
+		}
+		frameURL = base.ResolveReference(frameURL)
+		frameURL.Fragment = ""
+		if (frameURL.Scheme != "http" && frameURL.Scheme != "https") || visited[frameURL.String()] {
+			continue
+		}
+		visited[frameURL.String()] = true
+		if !sameSite(pageURL, frameURL) && !cfg.FrameHostMatches(frameURL.Hostname()) {
+			log.Printf("Skipping frame on another site: %s", frameURL)
+			continue
+		}
+
+		log.Printf("Scanning frame %s", frameURL)
+		downloadContext := pageContext(pageURL.String(), flags, cfg)
+		source, err := downloadContext.HTML(frameURL.String())
+		if err != nil {
+			log.Printf("Error: cannot download frame: %v", err)
+			continue
+		}
+		frameDoc := scan.NewPageDocument(source, frameURL)
+		pictures, profile := scanImages(frameURL, frameDoc, flags, cfg)
+		pictures = followPagination(frameURL, frameDoc, pictures, profile, flags, cfg)
+		if len(pictures) > 0 {
+			return framedGallery{url: frameURL, doc: frameDoc, pictures: pictures, profile: profile}, true
+		}
+		if depth < maxFrameDepth {
+			if found, ok := scanFrames(frameURL, frameDoc, flags, cfg, depth+1, visited); ok {
+				return found, true
+			}
+		}
+	}
+	return framedGallery{}, false
+}
+
+// frameSource returns the src attribute of a frame
+func frameSource(frame *html.Node) string {
+	for _, attribute := range frame.Attr {
+		if attribute.Key == "src" {
+			return strings.TrimSpace(attribute.Val)
+		}
+	}
+	return ""
+}
+
+// sameSite returns true when both URLs belong to the same registered domain, like www.example.com and photos.example.com.
+// An IP address is only the same site as itself
+func sameSite(first, second *url.URL) bool {
+	if strings.EqualFold(first.Hostname(), second.Hostname()) {
+		return true
+	}
+	if net.ParseIP(first.Hostname()) != nil || net.ParseIP(second.Hostname()) != nil {
+		return false
+	}
+	firstSite, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(first.Hostname()))
+	if err != nil {
+		return false
+	}
+	secondSite, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(second.Hostname()))
+	if err != nil {
+		return false
+	}
+	return firstSite == secondSite
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestSameSite(t *testing.T) {
+	testData := []struct {
+		first    string
+		second   string
+		expected bool
+	}{
+		{"https://example.com/", "https://example.com/frame", true},
+		{"https://example.com/", "http://EXAMPLE.com:8080/", true},
+		{"https://www.example.com/", "https://photos.example.com/", true},
+		{"https://example.com/", "https://cdn.photos.example.com/", true},
+		{"https://example.com/", "https://example.org/", false},
+		{"https://example.com/", "https://example.com.evil.net/", false},
+		{"https://www.example.co.uk/", "https://photos.example.co.uk/", true},
+		{"https://example.co.uk/", "https://other.co.uk/", false},
+		{"https://alice.github.io/", "https://bob.github.io/", false},
+		{"http://localhost/", "http://localhost:8080/", true},
+		{"http://localhost/", "http://127.0.0.1/", false},
+		{"http://127.0.0.1/", "http://127.0.0.1:8080/", true},
+		{"http://127.0.0.1/", "http://10.0.0.1/", false},
+		{"http://[::1]/", "http://[::1]:8080/", true},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.first+" "+testItem.second, func(t *testing.T) {
+			first := mustParseURL(t, testItem.first)
+			second := mustParseURL(t, testItem.second)
+			assert.Equal(t, testItem.expected, sameSite(first, second))
+			assert.Equal(t, testItem.expected, sameSite(second, first))
+		})
+	}
+}
+
+func TestFrameSource(t *testing.T) {
+	node, err := html.Parse(strings.NewReader(`<html><body>
+<iframe src=" /gallery.html "></iframe>
+<iframe srcdoc="<p>inline</p>"></iframe>
+<iframe SRC="https://example.com/frame"></iframe>
+</body></html>`))
+	require.NoError(t, err)
+
+	sources := make([]string, 0)
+	for _, frame := range cascadia.QueryAll(node, cascadia.MustCompile("iframe")) {
+		sources = append(sources, frameSource(frame))
+	}
+	assert.Equal(t, []string{"/gallery.html", "", "https://example.com/frame"}, sources)
+}
+
+// newFrameServer serves pages framing each other, on the same site (127.0.0.1) or on another one (localhost)
+func newFrameServer(t *testing.T) (*httptest.Server, func() []string) {
+	lock := sync.Mutex{}
+	requested := make([]string, 0)
+	pages := map[string]string{
+		"/gallery":  `<img src="/photos/001.jpg"><img src="/photos/002.jpg">`,
+		"/empty":    `<p>nothing here</p>`,
+		"/nested/1": `<iframe src="/gallery"></iframe>`,
+		"/nested/2": `<iframe src="/nested/1"></iframe>`,
+		"/nested/3": `<iframe src="/nested/2"></iframe>`,
+		"/nested/4": `<iframe src="/nested/3"></iframe>`,
+		"/cross":    `<iframe src="http://localhost:{port}/gallery"></iframe>`,
+		"/cross/2":  `<iframe src="http://localhost:{port}/nested/1"></iframe>`,
+		"/loop":     `<iframe src="/loop"></iframe><iframe src="/page"></iframe><iframe src="/empty"></iframe>`,
+		"/siblings": `<iframe src="/empty"></iframe><iframe src="/gallery"></iframe>`,
+		"/schemes":  `<iframe src="about:blank"></iframe><iframe src="javascript:void(0)"></iframe><iframe src="/empty#top"></iframe><iframe src="/empty"></iframe>`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host[:strings.Index(r.Host, ":")]
+		lock.Lock()
+		requested = append(requested, host+r.URL.Path)
+		lock.Unlock()
+		page, found := pages[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		port := r.Host[strings.Index(r.Host, ":")+1:]
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>" + strings.ReplaceAll(page, "{port}", port) + "</body></html>"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, requested...)
+	}
+}
+
+func TestScanFrames(t *testing.T) {
+	testData := []struct {
+		name       string
+		frame      string
+		frameHosts []string
+		found      string
+		requested  []string
+	}{
+		{"same site", "/gallery", nil, "127.0.0.1/gallery", []string{"127.0.0.1/gallery"}},
+		{"nested", "/nested/2", nil, "127.0.0.1/gallery", []string{"127.0.0.1/nested/2", "127.0.0.1/nested/1", "127.0.0.1/gallery"}},
+		{"deepest frame", "/nested/3", nil, "", []string{"127.0.0.1/nested/3", "127.0.0.1/nested/2", "127.0.0.1/nested/1"}},
+		{"too deep", "/nested/4", nil, "", []string{"127.0.0.1/nested/4", "127.0.0.1/nested/3", "127.0.0.1/nested/2"}},
+		{"first frame with pictures", "/siblings", nil, "127.0.0.1/gallery", []string{"127.0.0.1/siblings", "127.0.0.1/empty", "127.0.0.1/gallery"}},
+		{"other site", "/cross", nil, "", []string{"127.0.0.1/cross"}},
+		{"nested other site", "/cross/2", nil, "", []string{"127.0.0.1/cross/2"}},
+		{"frame hosts", "/cross", []string{"localhost"}, "localhost/gallery", []string{"127.0.0.1/cross", "localhost/gallery"}},
+		{"nested frame hosts", "/cross/2", []string{"localhost"}, "localhost/gallery", []string{"127.0.0.1/cross/2", "localhost/nested/1", "localhost/gallery"}},
+		{"visited once", "/loop", nil, "", []string{"127.0.0.1/loop", "127.0.0.1/empty"}},
+		{"not http", "/schemes", nil, "", []string{"127.0.0.1/schemes", "127.0.0.1/empty"}},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			ts, requested := newFrameServer(t)
+			cfg := &config.Configuration{
+				FrameHosts: testItem.frameHosts,
+				Profiles: []config.Profile{{
+					Name:        "photos",
+					DetectImage: config.Parser{Type: "regexp", Match: `src="(/photos/[^"]+)"`},
+				}},
+			}
+			flags := Flags{Type: scan.ConfigProfiles}
+
+			pageURL := mustParseURL(t, ts.URL+"/page")
+			doc := scan.NewPageDocument([]byte(`<html><body><iframe src="`+testItem.frame+`"></iframe></body></html>`), pageURL)
+			frame, found := scanFrames(pageURL, doc, flags, cfg, 1, map[string]bool{pageURL.String(): true})
+
+			assert.Equal(t, testItem.found != "", found)
+			if found {
+				assert.Equal(t, testItem.found, frame.url.Hostname()+frame.url.Path)
+				assert.Equal(t, "photos", frame.profile.Name)
+				assert.Equal(t, []string{"/photos/001.jpg", "/photos/002.jpg"}, recordPaths(t, frame.pictures))
+			}
+			assert.Equal(t, testItem.requested, requested())
+		})
+	}
+}
+
+// recordPaths returns the path of the URL of each record
+func recordPaths(t *testing.T, records []scan.Record) []string {
+	paths := make([]string, 0, len(records))
+	for _, record := range records {
+		paths = append(paths, mustParseURL(t, record.URL).Path)
+	}
+	return paths
+}
@@ -1,37 +1,50 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"gallery-downloader/archive"
 	"gallery-downloader/config"
 	"gallery-downloader/download"
 	"gallery-downloader/scan"
+	"gallery-downloader/transcode"
 	"io/ioutil"
 	"log"
+	"mime"
 	"net/url"
 	"os"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
-
-	"github.com/andybalholm/cascadia"
 )
 
+// errNoPicture is returned when a source has no picture: it's already reported, and it's not an error for a single source
+var errNoPicture = errors.New("no picture found")
+
 func main() {
 	var err error
 
+	// this process may only be started to run a script
+	scan.ServeScript()
+
 	setLogger()
 	flags := loadFlags()
 
 	checkSource(flags)
 	checkType(flags)
 	checkOutput(flags)
+	checkCharset(flags)
 	checkParallel(flags)
 
 	cfg, err := config.LoadFileConfiguration(flags.ConfigFile)
 	if err != nil {
 		log.Fatalf("Error: cannot load configuration: %v", err)
 	}
+	checkProfile(flags, cfg)
+	checkStream(flags)
+	checkSitemap(flags)
 
 	var baseURL = &url.URL{}
 	if flags.Base != "" {
@@ -44,17 +57,31 @@ func main() {
 		}
 	}
 
+	if flags.Sources != "" {
+		if downloadBatch(baseURL, flags, cfg) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	err = downloadSource(baseURL, flags, cfg)
+	if err != nil && !errors.Is(err, errNoPicture) {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// downloadSource downloads the gallery of the -source flag: a local file, a remote page or a sitemap
+func downloadSource(baseURL *url.URL, flags Flags, cfg *config.Configuration) error {
 	sourceURL, err := url.Parse(flags.Source)
 	if err != nil {
-		log.Fatalf("Error parsing source URL: %v", err)
+		return fmt.Errorf("cannot parse source URL: %w", err)
+	}
+	if flags.Sitemap {
+		return downloadSitemap(sourceURL, flags, cfg)
 	}
-
 	if sourceURL.Scheme == "" {
-		downloadPicturesFromLocalGalleryFile(flags.Source, baseURL, flags, cfg)
-	} else {
-		downloadPicturesFromRemoteGallery(sourceURL, flags, cfg)
+		return downloadPicturesFromLocalGalleryFile(flags.Source, baseURL, flags, cfg)
 	}
-
+	return downloadPicturesFromRemoteGallery(sourceURL, flags, cfg)
 }
 
 func setLogger() {
@@ -62,9 +89,15 @@ func setLogger() {
 }
 
 func checkSource(flags Flags) {
-	if flags.Source == "" {
+	if flags.Source != "" && flags.Sources != "" {
+		log.Fatal("\nError: -source cannot be used with -sources")
+	}
+	if flags.Source == "" && flags.Sources == "" {
 		flag.Usage()
-		log.Fatal("\nError: missing HTML source (-source)")
+		log.Fatal("\nError: missing HTML source (-source or -sources)")
+	}
+	if flags.Galleries < 1 {
+		log.Fatal("\nError: -galleries must be at least 1")
 	}
 }
 
@@ -82,6 +115,52 @@ func checkType(flags Flags) {
 	log.Fatalf("\nError: unknown gallery type. Known types are: %s", strings.Join(scan.AvailableGalleryScanners[:], ", "))
 }
 
+func checkProfile(flags Flags, cfg *config.Configuration) {
+	if flags.Profile == "" {
+		return
+	}
+	if flags.Type != scan.AutoDetect && flags.Type != scan.ConfigProfiles {
+		log.Fatalf("\nError: -profile cannot be used with gallery type %s", flags.Type)
+	}
+	if _, found := cfg.Profile(flags.Profile); !found {
+		log.Fatalf("\nError: profile %q not found in configuration", flags.Profile)
+	}
+}
+
+func checkStream(flags Flags) {
+	if flags.Stream && flags.Crawl {
+		log.Fatal("\nError: -stream cannot be used with -crawl")
+	}
+	if flags.Stream && flags.Frames {
+		// the frames are only scanned when the whole page has no picture
+		log.Fatal("\nError: -stream cannot be used with -frames")
+	}
+}
+
+func checkSitemap(flags Flags) {
+	if !flags.Sitemap {
+		if flags.Pattern != "" {
+			log.Fatal("\nError: -pattern can only be used with -sitemap")
+		}
+		return
+	}
+	if flags.Crawl || flags.Stream {
+		log.Fatal("\nError: -sitemap cannot be used with -crawl or -stream")
+	}
+	if _, err := regexp.Compile(flags.Pattern); err != nil {
+		log.Fatalf("\nError: invalid -pattern: %v", err)
+	}
+}
+
+func checkCharset(flags Flags) {
+	if flags.Charset == "" {
+		return
+	}
+	if _, _, err := transcode.Lookup(flags.Charset); err != nil {
+		log.Fatalf("\nError: %v", err)
+	}
+}
+
 func checkOutput(flags Flags) {
 	if flags.Output == "" {
 		flag.Usage()
@@ -107,20 +186,75 @@ func checkParallel(flags Flags) {
 	// }
 }
 
-func downloadPicturesFromLocalGalleryFile(sourceFile string, baseURL *url.URL, flags Flags, cfg *config.Configuration) {
+func downloadPicturesFromLocalGalleryFile(sourceFile string, baseURL *url.URL, flags Flags, cfg *config.Configuration) error {
+	saved, err := archive.Open(sourceFile)
+	if err == nil {
+		return downloadPicturesFromArchive(saved, baseURL, flags, cfg)
+	}
+	if !errors.Is(err, archive.ErrNotArchive) {
+		return fmt.Errorf("cannot read web archive: %w", err)
+	}
+
 	// Let's consider this is a file on disk
 	sourcefile, err := os.Open(sourceFile)
 	if err != nil {
-		log.Fatalf("Error: cannot open HTML source file: %v", err)
+		return fmt.Errorf("cannot open HTML source file: %w", err)
 	}
 	defer sourcefile.Close()
+	if flags.Stream {
+		matcher, profile, err := newStreamMatcher(baseURL, flags, cfg)
+		if err == nil {
+			reader, name, err := transcode.NewReader(sourcefile, "", flags.Charset)
+			if err != nil {
+				return fmt.Errorf("cannot read gallery file: %w", err)
+			}
+			logCharset(name)
+			downloadContext := download.NewContext(download.Config{
+				BaseURL:       baseURL,
+				Referer:       flags.Referer,
+				User:          flags.User,
+				Password:      flags.Password,
+				Output:        flags.Output,
+				Browser:       cfg.Browser,
+				WaitMin:       profile.MinWait,
+				WaitMax:       profile.MaxWait,
+				SkipVerifyTLS: flags.InsecureTLS,
+				Parallel:      profile.Parallel,
+				Progress:      progressHandler(flags),
+			})
+			if streamGallery(reader, matcher, profile, downloadContext) == 0 {
+				log.Println("No picture found in the HTML source!")
+				return errNoPicture
+			}
+			return nil
+		}
+		log.Printf("Cannot stream the page, reading it entirely: %v", err)
+	}
 	buffer, err := ioutil.ReadAll(sourcefile)
 	if err != nil {
-		log.Fatalf("Error cannot read gallery file: %s", err)
+		return fmt.Errorf("cannot read gallery file: %w", err)
 	}
-	pictures, profile := scanImages(buffer, flags, cfg)
+	// the charset of the system types is not the one of the file
+	contentType, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(sourceFile)))
+	return downloadPicturesFromPage(buffer, contentType, baseURL, nil, flags, cfg)
+}
+
+// downloadPicturesFromPage downloads the pictures of a page read from disk,
+// reading them from the archive of the page when it has one
+func downloadPicturesFromPage(buffer []byte, contentType string, baseURL *url.URL, saved archive.Archive, flags Flags, cfg *config.Configuration) error {
+	buffer, name, err := transcode.ToUTF8(buffer, contentType, flags.Charset)
+	if err != nil {
+		return fmt.Errorf("cannot read gallery file: %w", err)
+	}
+	logCharset(name)
+	flags = sourceFlags(flags, contentType, buffer)
+	if base := documentBase(baseURL, buffer); base.IsAbs() {
+		baseURL = base
+	}
+	pictures, profile := scanImages(baseURL, scan.NewPageDocument(buffer, baseURL), flags, cfg)
 	if len(pictures) == 0 {
 		log.Println("No picture found in the HTML source!")
+		return errNoPicture
 	}
 
 	downloadContext := download.NewContext(download.Config{
@@ -134,12 +268,14 @@ func downloadPicturesFromLocalGalleryFile(sourceFile string, baseURL *url.URL, f
 		WaitMax:       profile.MaxWait,
 		SkipVerifyTLS: flags.InsecureTLS,
 		Parallel:      profile.Parallel,
-		Progress:      handleProgress,
+		Progress:      progressHandler(flags),
+		Archive:       saved,
 	})
-	downloadContext.Pictures(pictures)
+	downloadContext.Pictures(rewritePictures(pictures, profile))
+	return nil
 }
 
-func downloadPicturesFromRemoteGallery(sourceURL *url.URL, flags Flags, cfg *config.Configuration) {
+func downloadPicturesFromRemoteGallery(sourceURL *url.URL, flags Flags, cfg *config.Configuration) error {
 	// We need to download the remote HTML file
 	downloadContext := download.NewContext(download.Config{
 		Referer:       flags.Referer,
@@ -147,38 +283,173 @@ func downloadPicturesFromRemoteGallery(sourceURL *url.URL, flags Flags, cfg *con
 		Password:      flags.Password,
 		Browser:       cfg.Browser,
 		SkipVerifyTLS: flags.InsecureTLS,
-		Progress:      handleProgress,
+		Charset:       flags.Charset,
+		Progress:      progressHandler(flags),
 	})
-	buffer, err := downloadContext.HTML(flags.Source)
+	if flags.Stream {
+		matcher, profile, err := newStreamMatcher(sourceURL, flags, cfg)
+		if err == nil {
+			return streamRemoteGallery(sourceURL, matcher, profile, flags, cfg)
+		}
+		log.Printf("Cannot stream the page, reading it entirely: %v", err)
+	}
+	buffer, contentType, err := downloadContext.Page(flags.Source)
 	if err != nil {
-		log.Fatalf("Error: cannot download HTML source file: %v", err)
+		return fmt.Errorf("cannot download HTML source file: %w", err)
+	}
+	flags = sourceFlags(flags, contentType, buffer)
+	document := scan.NewPageDocument(buffer, sourceURL)
+	if flags.Crawl && newCrawler(sourceURL, flags, cfg).crawl(sourceURL, document, flags.Output, 1) {
+		return nil
+	}
+	return downloadRemoteGallery(sourceURL, document, flags.Output, flags, cfg)
+}
+
+// downloadRemoteGallery scans the gallery page (and its next pages), then downloads the pictures into the output folder.
+// It returns errNoPicture when the page has no picture
+func downloadRemoteGallery(pageURL *url.URL, doc *scan.Document, output string, flags Flags, cfg *config.Configuration) error {
+	pictures, profile := scanImages(pageURL, doc, flags, cfg)
+	pictures = followPagination(pageURL, doc, pictures, profile, flags, cfg)
+	if len(pictures) == 0 && flags.Frames {
+		if frame, found := scanFrames(pageURL, doc, flags, cfg, 1, map[string]bool{pageURL.String(): true}); found {
+			// the pictures are downloaded from the frame
+			pageURL, doc, pictures, profile = frame.url, frame.doc, frame.pictures, frame.profile
+		}
 	}
-	pictures, profile := scanImages(buffer, flags, cfg)
 	if len(pictures) == 0 {
-		ioutil.WriteFile(path.Join(flags.Output, "index.html"), buffer, 0644)
+		ioutil.WriteFile(path.Join(output, "index.html"), doc.Source(), 0644)
 		log.Println("No picture found in the HTML source. HTML file saved as index.html")
+		return errNoPicture
 	}
 
-	downloadContext = download.NewContext(download.Config{
+	downloadContext := download.NewContext(download.Config{
 		User:          flags.User,
 		Password:      flags.Password,
 		Browser:       cfg.Browser,
 		SkipVerifyTLS: flags.InsecureTLS,
-		BaseURL:       sourceURL,
-		Referer:       flags.Source,
+		BaseURL:       documentBase(pageURL, doc.Source()),
+		Referer:       pageURL.String(),
+		Output:        output,
+		WaitMin:       profile.MinWait,
+		WaitMax:       profile.MaxWait,
+		Parallel:      profile.Parallel,
+		Progress:      progressHandler(flags),
+	})
+	downloadContext.Pictures(rewritePictures(pictures, profile))
+	return nil
+}
+
+// streamRemoteGallery downloads the pictures while the gallery page is still downloading
+func streamRemoteGallery(pageURL *url.URL, matcher *scan.StreamMatcher, profile config.Profile, flags Flags, cfg *config.Configuration) error {
+	downloadContext := download.NewContext(download.Config{
+		User:          flags.User,
+		Password:      flags.Password,
+		Browser:       cfg.Browser,
+		SkipVerifyTLS: flags.InsecureTLS,
+		BaseURL:       pageURL,
+		Referer:       pageURL.String(),
 		Output:        flags.Output,
 		WaitMin:       profile.MinWait,
 		WaitMax:       profile.MaxWait,
 		Parallel:      profile.Parallel,
-		Progress:      handleProgress,
+		Progress:      progressHandler(flags),
+	})
+	reader, err := download.NewContext(download.Config{
+		Referer:       flags.Referer,
+		User:          flags.User,
+		Password:      flags.Password,
+		Browser:       cfg.Browser,
+		SkipVerifyTLS: flags.InsecureTLS,
+		Charset:       flags.Charset,
+	}).HTMLReader(pageURL.String())
+	if err != nil {
+		return fmt.Errorf("cannot download HTML source file: %w", err)
+	}
+	defer reader.Close()
+	if streamGallery(reader, matcher, profile, downloadContext) == 0 {
+		log.Println("No picture found in the HTML source!")
+		return errNoPicture
+	}
+	return nil
+}
+
+// sourceFlags uses the scanner of the sources that are not HTML pages (feeds and IIIF manifests)
+// when the type of gallery is detected. These sources are galleries on their own: they're never crawled
+func sourceFlags(flags Flags, contentType string, source []byte) Flags {
+	if flags.Type != scan.AutoDetect {
+		return flags
+	}
+	switch {
+	case scan.IsFeed(contentType, source):
+		log.Printf("The source is a feed")
+		flags.Type = scan.Feed
+	case scan.IsIIIFManifest(source):
+		log.Printf("The source is a IIIF manifest")
+		flags.Type = scan.IIIF
+	default:
+		return flags
+	}
+	flags.Crawl = false
+	return flags
+}
+
+func logCharset(name string) {
+	if name != transcode.UTF8 {
+		log.Printf("Converting page from %s to UTF-8", name)
+	}
+}
+
+// pageContext returns the context downloading the pages linked from the referer: frames, albums, next pages or sitemaps
+func pageContext(referer string, flags Flags, cfg *config.Configuration) *download.Context {
+	return download.NewContext(download.Config{
+		Referer:       referer,
+		User:          flags.User,
+		Password:      flags.Password,
+		Browser:       cfg.Browser,
+		SkipVerifyTLS: flags.InsecureTLS,
+		Charset:       flags.Charset,
 	})
-	downloadContext.Pictures(pictures)
+}
+
+// documentBase returns the URL used to resolve the relative links of the page:
+// the <base href> of the page when present, or the URL of the page itself
+func documentBase(pageURL *url.URL, source []byte) *url.URL {
+	href := scan.BaseHref(source)
+	if href == "" {
+		return pageURL
+	}
+	base, err := url.Parse(href)
+	if err != nil {
+		log.Printf("Error: invalid base URL %q: %v", href, err)
+		return pageURL
+	}
+	return pageURL.ResolveReference(base)
+}
+
+// progressHandler returns the function displaying the progress of the downloads.
+// The lines are prefixed with the gallery when a batch downloads many of them at the same time
+func progressHandler(flags Flags) func(download.Progress) {
+	if flags.Gallery == "" {
+		return handleProgress
+	}
+	prefix := "[" + flags.Gallery + "] "
+	return func(progress download.Progress) {
+		log.Print(prefix + progressMessage(progress))
+	}
 }
 
 func handleProgress(progress download.Progress) {
+	log.Print(progressMessage(progress))
+}
+
+// progressMessage returns the line displaying an event of a download
+func progressMessage(progress download.Progress) string {
 	count := ""
 	if progress.TotalFiles > 0 {
 		count = fmt.Sprintf("(%d/%d) ", progress.FileID+1, progress.TotalFiles)
+	} else {
+		// streaming: the total is not known yet
+		count = fmt.Sprintf("(%d) ", progress.FileID+1)
 	}
 	message := ""
 	switch progress.Event {
@@ -186,88 +457,132 @@ func handleProgress(progress download.Progress) {
 		message = fmt.Sprintf("download starting: '%s'", progress.URL)
 	case download.EventFinished:
 		message = fmt.Sprintf("  finished downloading %d bytes", progress.Downloaded)
+		if progress.Archived {
+			message = fmt.Sprintf("  finished reading %d bytes from the archive", progress.Downloaded)
+		}
 	case download.EventNotSaving:
 		message = fmt.Sprintf("  not saving file of %d bytes", progress.Downloaded)
 	case download.EventError:
 		message = fmt.Sprintf("error: %s", progress.Err)
+	case download.EventFallback:
+		message = fmt.Sprintf("  %s: falling back to '%s'", progress.Err, progress.URL)
 	}
 	wait := ""
 	if progress.Wait > 0 {
 		wait = fmt.Sprintf(" and wait for %dms", progress.Wait)
 	}
-	log.Printf("%s%s%s", count, message, wait)
+	return count + message + wait
 }
 
-func scanImages(source []byte, flags Flags, cfg *config.Configuration) ([]string, config.Profile) {
-	var pictures []string
+func scanImages(pageURL *url.URL, doc *scan.Document, flags Flags, cfg *config.Configuration) ([]scan.Record, config.Profile) {
+	var pictures []scan.Record
 	var profile config.Profile
 	var err error
 	log.Printf("Using gallery scanner: %s", flags.Type)
 
 	if flags.Type == scan.AutoDetect || flags.Type == scan.ConfigProfiles {
 		// first pass, use regexp profiles from configuration
-		pictures, profile, err = detectFromProfiles(cfg.Profiles, source)
+		if forced, found := cfg.Profile(flags.Profile); found {
+			pictures, profile, err = detectFromForcedProfile(forced, doc)
+		} else if flags.Score || flags.Explain {
+			pictures, profile, err = detectFromScores(cfg.Profiles, pageURL, doc, flags.Explain)
+		} else {
+			pictures, profile, err = detectFromProfiles(cfg.Profiles, pageURL, doc)
+		}
 		if err != nil {
 			log.Printf("Error: %v", err)
 		}
 	}
+	if len(pictures) > 0 || flags.Type == scan.ConfigProfiles {
+		return pictures, profile
+	}
+
+	// second pass, use the scanners written in Go
+	scanners := []string{flags.Type}
+	if flags.Type == scan.AutoDetect {
+		scanners = scan.BuiltinGalleryScanners()
+	}
+	pictures, profile, err = detectFromScanners(scanners, doc, cfg)
+	if err != nil {
+		log.Printf("Error: %v", err)
+	}
 	return pictures, profile
 }
 
-func detectFromProfiles(profiles []config.Profile, source []byte) ([]string, config.Profile, error) {
-	// current minimum priority to choose from
-	priority := -1
-	for {
-		// on each turn, we need to pick the smallest priority number between "priority" and "next"
-		// "next" represents the smallest number bigger than "priority"
-		next := priority
-		run := -1
-		for i, profile := range profiles {
-			// first time here: next == priority
-			if profile.Priority > priority && (next == priority || profile.Priority < next) {
-				next = profile.Priority
-				run = i
+// detectFromScanners returns the pictures from the first gallery scanner finding any
+func detectFromScanners(scanners []string, doc *scan.Document, cfg *config.Configuration) ([]scan.Record, config.Profile, error) {
+	for _, name := range scanners {
+		for _, factory := range scan.GalleryScanners[name] {
+			gallery, err := factory(scan.Config{
+				Name:       name,
+				Extensions: cfg.Extensions,
+				Media:      cfg.Media,
+			}, doc)
+			if err != nil {
+				return nil, config.Profile{}, fmt.Errorf("gallery scanner %s: %w", name, err)
+			}
+			if !gallery.Match() {
+				continue
+			}
+			images := scan.GalleryRecords(gallery)
+			if len(images) > 0 {
+				log.Printf("Found %d images using gallery scanner %s", len(images), name)
+				generatedBy := gallery.GeneratedBy()
+				if generatedBy != "" {
+					log.Printf("Gallery generated by %s", generatedBy)
+				}
+				return images, config.Profile{Name: name}, nil
 			}
 		}
+	}
+	return nil, config.Profile{}, nil
+}
 
-		// no profile was chosen, it means we've been through them all
-		if run < 0 {
-			break
+// profileOrder returns the index of the profiles to try, in order: the profiles matching the URL of the page first,
+// then all the others. Profiles are sorted by priority in each group.
+// When one of the profiles matching the URL is exclusive, the other profiles are not returned
+func profileOrder(profiles []config.Profile, pageURL *url.URL) []int {
+	matching := make([]int, 0)
+	others := make([]int, 0, len(profiles))
+	exclusive := false
+	for i, profile := range profiles {
+		if profile.MatchURL(pageURL) {
+			matching = append(matching, i)
+			exclusive = exclusive || profile.Exclusive
+			continue
 		}
+		others = append(others, i)
+	}
+	byPriority := func(list []int) {
+		sort.SliceStable(list, func(i, j int) bool {
+			return profiles[list[i]].Priority < profiles[list[j]].Priority
+		})
+	}
+	byPriority(matching)
+	if exclusive {
+		return matching
+	}
+	byPriority(others)
+	return append(matching, others...)
+}
 
+func detectFromProfiles(profiles []config.Profile, pageURL *url.URL, doc *scan.Document) ([]scan.Record, config.Profile, error) {
+	for _, run := range profileOrder(profiles, pageURL) {
 		profile := profiles[run]
-
-		generator, err := newMatcher(profile.DetectGenerator)
-		if err != nil {
-			return nil, profile, fmt.Errorf("profile %s: cannot compile generator %s: %w", profile.Name, profile.DetectGenerator, err)
-		}
-
-		gallery, err := newMatcher(profile.DetectGallery)
-		if err != nil {
-			return nil, profile, fmt.Errorf("profile %s: cannot compile generator %s: %w", profile.Name, profile.DetectGallery, err)
-		}
-
-		image, err := newMatcher(profile.DetectImage)
-		if err != nil {
-			return nil, profile, fmt.Errorf("profile %s: cannot compile generator %s: %w", profile.Name, profile.DetectImage, err)
-		}
-		if image == nil {
-			return nil, profile, fmt.Errorf("profile %s: missing detectImage", profile.Name)
+		if profile.DetectImage.Type == "" && profile.Albums.IsSet() {
+			// this profile only detects an index of albums
+			continue
 		}
 
-		scanner, err := scan.NewGallery(scan.Config{
-			Name:            profile.Name,
-			DetectGenerator: generator,
-			DetectGallery:   gallery,
-			DetectImage:     image,
-		}, source)
+		scanner, err := newGallery(profile, doc)
 		if err != nil {
-			return nil, profile, fmt.Errorf("cannot parse source: %w", err)
+			return nil, profile, err
 		}
 
 		if scanner.Match() {
-			images := scanner.Find()
-			if len(images) >= profile.MinImages {
+			// the media don't make a gallery: only the images count toward the minimum
+			if images := scanner.ImageRecords(); len(images) >= profile.MinImages {
+				images = scanner.AppendMedia(images)
 				log.Printf("Found %d images using profile %s (#%d)", len(images), profile.Name, run+1)
 				generatedBy := scanner.GeneratedBy()
 				if generatedBy != "" {
@@ -276,29 +591,26 @@ func detectFromProfiles(profiles []config.Profile, source []byte) ([]string, con
 				return images, profile, nil
 			}
 		}
-		// on next turn, don't pick anything with less priority than this one
-		priority = profiles[run].Priority
 	}
 	return nil, config.Profile{}, nil
 }
 
-func newMatcher(cfg config.Parser) (scan.Matcher, error) {
-	matcherType := strings.ToLower(cfg.Type)
-
-	if strings.HasPrefix(matcherType, "regex") {
-		pattern, err := regexp.Compile(cfg.Match)
-		if err != nil {
-			return nil, err
-		}
-		return scan.NewRegexpMatcher(pattern), nil
+// detectFromForcedProfile returns the pictures found by the profile chosen on the command line, bypassing the detection
+func detectFromForcedProfile(profile config.Profile, doc *scan.Document) ([]scan.Record, config.Profile, error) {
+	scanner, err := newGallery(profile, doc)
+	if err != nil {
+		return nil, profile, err
 	}
+	images := scanner.Records()
+	log.Printf("Found %d images using profile %s", len(images), profile.Name)
+	return images, profile, nil
+}
 
-	if strings.HasPrefix(matcherType, "sel") || strings.HasPrefix(matcherType, "css") {
-		sel, err := cascadia.Parse(cfg.Match)
-		if err != nil {
-			return nil, err
-		}
-		return scan.NewSelectorMatcher(sel, cfg.Attribute), nil
+// newGallery creates a gallery scanner on the document, using the compiled matchers of the profile
+func newGallery(profile config.Profile, doc *scan.Document) (*scan.Gallery, error) {
+	scanCfg, err := profile.ScanConfig()
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil
+	return scan.NewGallery(scanCfg, doc), nil
 }
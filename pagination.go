@@ -0,0 +1,167 @@
+package main
+
+import (
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxPages  = 100
+	defaultStartPage = 2
+)
+
+// followPagination reads the next pages of the gallery (when the profile is configured to do so)
+// and returns the pictures of all pages in order, as absolute URLs and without duplicates
+func followPagination(pageURL *url.URL, doc *scan.Document, pictures []scan.Record, profile config.Profile, flags Flags, cfg *config.Configuration) []scan.Record {
+	pagination := profile.Pagination
+	if !pagination.IsSet() {
+		return pictures
+	}
+
+	next, err := pagination.Next.Matcher()
+	if err != nil {
+		log.Printf("Error: profile %s: cannot compile next page detection: %v", profile.Name, err)
+		return pictures
+	}
+	stop, err := pagination.Stop.Matcher()
+	if err != nil {
+		log.Printf("Error: profile %s: cannot compile stop condition: %v", profile.Name, err)
+		return pictures
+	}
+
+	maxPages := pagination.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+	pageNumber := startPage(pagination)
+
+	// the pattern is relative to the first page, never to the current one
+	firstBase := documentBase(pageURL, doc.Source())
+	collector := newPictureCollector()
+	collector.add(firstBase, pictures)
+	visited := map[string]bool{pageURL.String(): true}
+
+	for page := 2; page <= maxPages; page++ {
+		if isLastPage(stop, doc) {
+			break
+		}
+		nextURL := nextPageURL(next, pagination.Pattern, pageNumber, firstBase, pageURL, doc)
+		if nextURL == nil {
+			break
+		}
+		if visited[nextURL.String()] {
+			log.Printf("Page %s already visited", nextURL)
+			break
+		}
+		visited[nextURL.String()] = true
+		pageNumber++
+
+		downloadContext := pageContext(pageURL.String(), flags, cfg)
+		source, err := downloadContext.HTML(nextURL.String())
+		if err != nil {
+			log.Printf("Error: cannot download page %d: %v", page, err)
+			break
+		}
+		pageURL = nextURL
+		doc = scan.NewPageDocument(source, pageURL)
+
+		found, err := scanProfile(profile, doc)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			break
+		}
+		added := collector.add(documentBase(pageURL, source), found)
+		log.Printf("Page %d: found %d new images (%s)", page, added, pageURL)
+		if added == 0 {
+			// we're probably going round in circles
+			break
+		}
+	}
+	return collector.pictures
+}
+
+// scanProfile returns the pictures found in the source using a single profile
+func scanProfile(profile config.Profile, doc *scan.Document) ([]scan.Record, error) {
+	scanner, err := newGallery(profile, doc)
+	if err != nil {
+		return nil, err
+	}
+	if !scanner.Match() {
+		return nil, nil
+	}
+	return scanner.Records(), nil
+}
+
+// startPage returns the number of the second page, or the default one when it's not set in the configuration
+func startPage(pagination config.Pagination) int {
+	if pagination.Start == nil {
+		return defaultStartPage
+	}
+	return *pagination.Start
+}
+
+// isLastPage returns true when the stop condition matches the page
+func isLastPage(stop scan.Matcher, doc *scan.Document) bool {
+	if stop == nil {
+		return false
+	}
+	return stop.Find(doc) != ""
+}
+
+// nextPageURL returns the absolute URL of the next page, either from the next link found in the current page,
+// or from the URL pattern resolved against the base of the first page. It returns nil when there's no next page
+func nextPageURL(next scan.Matcher, pattern string, pageNumber int, firstBase, pageURL *url.URL, doc *scan.Document) *url.URL {
+	link := ""
+	base := firstBase
+	if next != nil {
+		links := next.FindAll(doc)
+		if len(links) == 0 {
+			return nil
+		}
+		link = links[0]
+		base = documentBase(pageURL, doc.Source())
+	} else {
+		link = strings.ReplaceAll(pattern, "{page}", strconv.Itoa(pageNumber))
+	}
+	nextURL, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		log.Printf("Error: invalid next page URL %q: %v", link, err)
+		return nil
+	}
+	return base.ResolveReference(nextURL)
+}
+
+// pictureCollector accumulates the pictures of many pages, keeping the first occurrence of each one
+type pictureCollector struct {
+	pictures []scan.Record
+	seen     map[string]bool
+}
+
+func newPictureCollector() *pictureCollector {
+	return &pictureCollector{
+		pictures: make([]scan.Record, 0),
+		seen:     make(map[string]bool),
+	}
+}
+
+// add resolves the pictures found on a page and returns the number of new ones
+func (c *pictureCollector) add(base *url.URL, pictures []scan.Record) int {
+	added := 0
+	for _, picture := range pictures {
+		// invalid URLs are kept as they are: the downloader will report the error
+		if pictureURL, err := url.Parse(picture.URL); err == nil {
+			picture.URL = base.ResolveReference(pictureURL).String()
+		}
+		if c.seen[picture.URL] {
+			continue
+		}
+		c.seen[picture.URL] = true
+		c.pictures = append(c.pictures, picture)
+		added++
+	}
+	return added
+}
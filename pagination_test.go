@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// linkMatcher always finds the same links
+type linkMatcher []string
+
+func (m linkMatcher) Find(doc *scan.Document) string {
+	if len(m) == 0 {
+		return ""
+	}
+	return m[0]
+}
+
+func (m linkMatcher) FindAll(doc *scan.Document) []string {
+	return m
+}
+
+func mustParseURL(t *testing.T, link string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestNextPageURL(t *testing.T) {
+	firstBase := mustParseURL(t, "https://example.com/gallery/")
+	currentURL := mustParseURL(t, "https://example.com/gallery/page/2/")
+
+	testData := []struct {
+		name     string
+		next     scan.Matcher
+		pattern  string
+		source   string
+		expected string
+	}{
+		{"path pattern relative to the first page", nil, "page/{page}/", "", "https://example.com/gallery/page/3/"},
+		{"query pattern", nil, "?page={page}", "", "https://example.com/gallery/?page=3"},
+		{"absolute pattern", nil, "https://other.example.com/p{page}.html", "", "https://other.example.com/p3.html"},
+		{"next link relative to the current page", linkMatcher{"../3/"}, "", "", "https://example.com/gallery/page/3/"},
+		{"next link relative to the base of the current page", linkMatcher{"next.html"}, "", `<base href="https://cdn.example.com/pages/">`, "https://cdn.example.com/pages/next.html"},
+		{"first next link", linkMatcher{" ?p=3 ", "?p=4"}, "", "", "https://example.com/gallery/page/2/?p=3"},
+		{"no next link", linkMatcher{}, "", "", ""},
+		{"invalid next link", linkMatcher{"%zz"}, "", "", ""},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			doc := scan.NewPageDocument([]byte(testItem.source), currentURL)
+			nextURL := nextPageURL(testItem.next, testItem.pattern, 3, firstBase, currentURL, doc)
+			if testItem.expected == "" {
+				assert.Nil(t, nextURL)
+				return
+			}
+			require.NotNil(t, nextURL)
+			assert.Equal(t, testItem.expected, nextURL.String())
+		})
+	}
+}
+
+func TestStartPage(t *testing.T) {
+	testData := []struct {
+		source   string
+		expected int
+	}{
+		{`{"pattern": "?page={page}"}`, defaultStartPage},
+		{`{"pattern": "?page={page}", "start": 0}`, 0},
+		{`{"pattern": "?page={page}", "start": 1}`, 1},
+		{`{"pattern": "?offset={page}", "start": 20}`, 20},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.source, func(t *testing.T) {
+			pagination := config.Pagination{}
+			require.NoError(t, json.Unmarshal([]byte(testItem.source), &pagination))
+			assert.Equal(t, testItem.expected, startPage(pagination))
+		})
+	}
+}
+
+func TestPictureCollector(t *testing.T) {
+	collector := newPictureCollector()
+	added := collector.add(mustParseURL(t, "https://example.com/gallery/"), []scan.Record{
+		{URL: "photos/1.jpg", Title: "one"},
+		{URL: "https://example.com/gallery/photos/1.jpg", Title: "duplicate"},
+		{URL: "/photos/2.jpg"},
+	})
+	assert.Equal(t, 2, added)
+
+	added = collector.add(mustParseURL(t, "https://example.com/gallery/page/2/"), []scan.Record{
+		{URL: "../../photos/1.jpg"},
+		{URL: "3.jpg"},
+		{URL: "%zz"},
+	})
+	assert.Equal(t, 2, added)
+	assert.Equal(t, []scan.Record{
+		{URL: "https://example.com/gallery/photos/1.jpg", Title: "one"},
+		{URL: "https://example.com/photos/2.jpg"},
+		{URL: "https://example.com/gallery/page/2/3.jpg"},
+		{URL: "%zz"},
+	}, collector.pictures)
+
+	assert.Equal(t, 0, collector.add(mustParseURL(t, "https://example.com/"), []scan.Record{{URL: "photos/2.jpg"}}))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"gallery-downloader/config"
+	"gallery-downloader/download"
+	"gallery-downloader/scan"
+	"log"
+	"regexp"
+)
+
+// rewriteRule is a compiled rule of a profile
+type rewriteRule struct {
+	pattern *regexp.Regexp
+	replace string
+}
+
+// rewriter applies the rewrite rules of a profile
+type rewriter struct {
+	rules    []rewriteRule
+	fallback bool
+}
+
+func newRewriter(profile config.Profile) *rewriter {
+	rules := make([]rewriteRule, 0, len(profile.Rewrite))
+	for _, rule := range profile.Rewrite {
+		pattern, err := rule.Pattern()
+		if err != nil {
+			// only for the rules not loaded from a configuration file, which are checked when loading it
+			log.Printf("Error: profile %s: cannot compile rewrite rule %q: %v", profile.Name, rule.Match, err)
+			continue
+		}
+		rules = append(rules, rewriteRule{pattern: pattern, replace: rule.Replace})
+	}
+	return &rewriter{
+		rules:    rules,
+		fallback: profile.RewriteFallback,
+	}
+}
+
+// picture applies the rules to the URL of a picture.
+// With the fallback option, the original URL is kept in case the rewritten one is not found
+func (r *rewriter) picture(picture scan.Record) download.Picture {
+	link := picture.URL
+	for _, rule := range r.rules {
+		link = rule.pattern.ReplaceAllString(link, rule.replace)
+	}
+	rewritten := download.Picture{URL: link, Title: picture.Title}
+	if r.fallback && link != picture.URL {
+		rewritten.Fallback = picture.URL
+	}
+	return rewritten
+}
+
+// rewritePictures applies the rewrite rules of the profile to the URL of each picture
+func rewritePictures(pictures []scan.Record, profile config.Profile) []download.Picture {
+	rewriter := newRewriter(profile)
+	rewritten := make([]download.Picture, len(pictures))
+	for i, picture := range pictures {
+		rewritten[i] = rewriter.picture(picture)
+	}
+	return rewritten
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"gallery-downloader/config"
+	"gallery-downloader/download"
+	"gallery-downloader/scan"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRewriter(t *testing.T) {
+	rewriter := newRewriter(config.Profile{
+		Name: "rewrite",
+		Rewrite: []config.RewriteRule{
+			{Match: `/thumbs/`, Replace: "/full/"},
+			{Match: `_(\d+`, Replace: "invalid"},
+			{Match: `_\d+x\d+(\.\w+)$`, Replace: "$1"},
+		},
+		RewriteFallback: true,
+	})
+	// the invalid rule is skipped
+	assert.Len(t, rewriter.rules, 2)
+	assert.True(t, rewriter.fallback)
+
+	rewriter = newRewriter(config.Profile{})
+	assert.Empty(t, rewriter.rules)
+	assert.False(t, rewriter.fallback)
+}
+
+func TestRewritePictures(t *testing.T) {
+	pictures := []scan.Record{
+		{URL: "https://example.com/thumbs/beach_200x150.jpg", Title: "Beach"},
+		{URL: "https://example.com/thumbs/sunset.jpg"},
+		{URL: "https://example.com/full/forest.jpg"},
+	}
+	rules := []config.RewriteRule{
+		{Match: `/thumbs/`, Replace: "/full/"},
+		// applied to the result of the first rule
+		{Match: `/full/(\w+)_\d+x\d+(\.\w+)$`, Replace: "/full/${1}_large$2"},
+	}
+
+	testData := []struct {
+		name     string
+		profile  config.Profile
+		expected []download.Picture
+	}{
+		{"no rule", config.Profile{}, []download.Picture{
+			{URL: "https://example.com/thumbs/beach_200x150.jpg", Title: "Beach"},
+			{URL: "https://example.com/thumbs/sunset.jpg"},
+			{URL: "https://example.com/full/forest.jpg"},
+		}},
+		{"rules in order", config.Profile{Rewrite: rules}, []download.Picture{
+			{URL: "https://example.com/full/beach_large.jpg", Title: "Beach"},
+			{URL: "https://example.com/full/sunset.jpg"},
+			{URL: "https://example.com/full/forest.jpg"},
+		}},
+		{"fallback", config.Profile{Rewrite: rules, RewriteFallback: true}, []download.Picture{
+			{URL: "https://example.com/full/beach_large.jpg", Fallback: "https://example.com/thumbs/beach_200x150.jpg", Title: "Beach"},
+			{URL: "https://example.com/full/sunset.jpg", Fallback: "https://example.com/thumbs/sunset.jpg"},
+			// nothing to fall back to when the URL is not rewritten
+			{URL: "https://example.com/full/forest.jpg"},
+		}},
+		{"fallback without rule", config.Profile{RewriteFallback: true}, []download.Picture{
+			{URL: "https://example.com/thumbs/beach_200x150.jpg", Title: "Beach"},
+			{URL: "https://example.com/thumbs/sunset.jpg"},
+			{URL: "https://example.com/full/forest.jpg"},
+		}},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, rewritePictures(pictures, testItem.profile))
+		})
+	}
+	assert.Empty(t, rewritePictures(nil, config.Profile{Rewrite: rules}))
+}
@@ -1,27 +1,28 @@
 package scan
 
 import (
-	"bytes"
-	"regexp"
-
 	"golang.org/x/net/html"
 )
 
 // LegacyAnchorGallery scans pictures like <a href="picture2.jpg" title="picture2">picture 2</a>
 type LegacyAnchorGallery struct {
-	source []byte
+	doc    *Document
 	node   *html.Node
+	filter *ExtensionFilter
+	media  []string
 }
 
 // NewLegacyAnchorGallery creates a new gallery
-func NewLegacyAnchorGallery(source []byte) (Gal, error) {
-	node, err := html.Parse(bytes.NewReader(source))
+func NewLegacyAnchorGallery(cfg Config, doc *Document) (Gal, error) {
+	node, err := doc.Node()
 	if err != nil {
 		return nil, err
 	}
 	return &LegacyAnchorGallery{
-		source: source,
+		doc:    doc,
 		node:   node,
+		filter: extensionFilter(cfg),
+		media:  cfg.Media,
 	}, nil
 }
 
@@ -39,8 +40,7 @@ func (g *LegacyAnchorGallery) Match() bool {
 // GeneratedBy returns the name of the gallery generator (if available).
 // It returns an empty string if not available
 func (g *LegacyAnchorGallery) GeneratedBy() string {
-	pattern := regexp.MustCompile(`<!--\s*Generated by\s*(.*?)\s*-->`)
-	match := pattern.FindSubmatch(g.source)
+	match := generatorPattern.FindSubmatch(g.doc.Source())
 	if match == nil || len(match) != 2 {
 		return ""
 	}
@@ -53,7 +53,7 @@ func (g *LegacyAnchorGallery) Find() []string {
 
 	var f func(*html.Node)
 	f = func(n *html.Node) {
-		if picture, found := getPictureAttribute(n, "a", "href"); found {
+		if picture, found := getPictureAttribute(n, "a", "href", g.filter); found {
 			pictures = append(pictures, picture)
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -61,7 +61,7 @@ func (g *LegacyAnchorGallery) Find() []string {
 		}
 	}
 	f(g.node)
-	return pictures
+	return appendMedia(pictures, g.doc, g.media, nil)
 }
 
 // Verify interfaces
@@ -0,0 +1,35 @@
+package scan
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// BaseHref returns the href attribute of the first <base> element of the page, or an empty string.
+// Only the beginning of the page is read: the <base> element must be declared before the <body>
+func BaseHref(source []byte) string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(source))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttributes := tokenizer.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				return ""
+			case atom.Base:
+				for hasAttributes {
+					var key, value []byte
+					key, value, hasAttributes = tokenizer.TagAttr()
+					if string(key) == "href" {
+						return strings.TrimSpace(string(value))
+					}
+				}
+			}
+		}
+	}
+}
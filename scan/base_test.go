@@ -0,0 +1,26 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseHref(t *testing.T) {
+	testData := []struct {
+		source   string
+		expected string
+	}{
+		{`<html><head><title>no base</title></head><body></body></html>`, ""},
+		{`<html><head><base href="https://cdn.example.com/gallery/"></head><body></body></html>`, "https://cdn.example.com/gallery/"},
+		{`<html><head><base target="_blank"><base href=" /gallery/ " /></head></html>`, "/gallery/"},
+		{`<html><head></head><body><base href="/too-late/"></body></html>`, ""},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.source, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, BaseHref([]byte(testItem.source)))
+		})
+	}
+	assert.Equal(t, "", BaseHref(getTestData(t, "anchor_href")))
+}
@@ -1,9 +1,18 @@
 package scan
 
+import "github.com/andybalholm/cascadia"
+
 // Config contains the gallery profile configuration
 type Config struct {
 	Name            string
 	DetectGenerator Matcher
 	DetectGallery   Matcher
 	DetectImage     Matcher
+	// Extensions of the pictures to keep. Built-in scanners use DefaultExtensions when empty,
+	// profiles keep all pictures
+	Extensions []string
+	// Media are the kinds of media (MediaKinds) found in addition to the pictures
+	Media []string
+	// MediaScope limits the media to the ones inside the matching elements. The media of the whole page are found when nil
+	MediaScope cascadia.Sel
 }
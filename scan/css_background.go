@@ -0,0 +1,273 @@
+package scan
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+var (
+	cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssURLPattern     = regexp.MustCompile(`(?i)url\(\s*(?:"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'|([^)\s]*))\s*\)`)
+)
+
+// BackgroundMatcher finds pictures in the CSS background of the elements matched by a selector.
+// The background is read from the inline style attribute first,
+// then from the rules of the <style> blocks embedded in the page
+type BackgroundMatcher struct {
+	sel cascadia.Sel
+}
+
+// backgroundRule is a background declared for a single selector in a stylesheet.
+// An empty url means the background was explicitly removed (like "background: none")
+type backgroundRule struct {
+	sel         cascadia.Sel
+	specificity cascadia.Specificity
+	url         string
+}
+
+// NewBackgroundMatcher creates a matcher returning the background pictures of the elements matching the selector
+func NewBackgroundMatcher(sel cascadia.Sel) *BackgroundMatcher {
+	if sel == nil {
+		// might as well panic right now, no need to go much further
+		panic("invalid nil css selector pattern")
+	}
+	return &BackgroundMatcher{
+		sel: sel,
+	}
+}
+
+func (m *BackgroundMatcher) Find(doc *Document) string {
+	node, err := doc.Node()
+	if err != nil {
+		return ""
+	}
+	rules := doc.backgroundRules()
+	for _, element := range cascadia.QueryAll(node, m.sel) {
+		if image := background(element, rules); image != "" {
+			return image
+		}
+	}
+	return ""
+}
+
+func (m *BackgroundMatcher) FindAll(doc *Document) []string {
+	node, err := doc.Node()
+	if err != nil {
+		return nil
+	}
+	elements := cascadia.QueryAll(node, m.sel)
+	if elements == nil {
+		return nil
+	}
+	rules := doc.backgroundRules()
+	images := make([]string, 0, len(elements))
+	for _, element := range elements {
+		if image := background(element, rules); image != "" {
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// background returns the background picture of the node, following a simplified CSS cascade
+func background(n *html.Node, rules []backgroundRule) string {
+	if image, found := backgroundDeclaration(getAttribute(n, "style")); found {
+		return image
+	}
+	var best *backgroundRule
+	for i, rule := range rules {
+		// on equal specificity, the last rule wins
+		if rule.sel.Match(n) && (best == nil || !rule.specificity.Less(best.specificity)) {
+			best = &rules[i]
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.url
+}
+
+// parseBackgroundRules returns the background declarations of a stylesheet.
+// Rules nested in conditional at-rules (@media, @supports) are flattened, other at-rules are ignored
+func parseBackgroundRules(stylesheet string) []backgroundRule {
+	rules := make([]backgroundRule, 0)
+	stylesheet = cssCommentPattern.ReplaceAllString(stylesheet, "")
+	for len(stylesheet) > 0 {
+		open := indexOutside(stylesheet, '{')
+		if open < 0 {
+			break
+		}
+		end := closingBrace(stylesheet, open)
+		prelude := strings.TrimSpace(stylesheet[:open])
+		block := stylesheet[open+1 : end]
+		if end < len(stylesheet) {
+			end++
+		}
+		stylesheet = stylesheet[end:]
+
+		if strings.HasPrefix(prelude, "@") {
+			// at-rules without a block (like @import) end with a semicolon, before the prelude of the next rule
+			if semicolon := strings.LastIndex(prelude, ";"); semicolon >= 0 {
+				prelude = strings.TrimSpace(prelude[semicolon+1:])
+			}
+		}
+		if strings.HasPrefix(prelude, "@") {
+			lower := strings.ToLower(prelude)
+			if strings.HasPrefix(lower, "@media") || strings.HasPrefix(lower, "@supports") {
+				rules = append(rules, parseBackgroundRules(block)...)
+			}
+			continue
+		}
+		image, found := backgroundDeclaration(block)
+		if !found {
+			continue
+		}
+		for _, selector := range splitOutside(prelude, ',') {
+			sel, err := cascadia.Parse(strings.TrimSpace(selector))
+			if err != nil {
+				// pseudo-elements and selectors not supported by cascadia
+				continue
+			}
+			rules = append(rules, backgroundRule{
+				sel:         sel,
+				specificity: sel.Specificity(),
+				url:         image,
+			})
+		}
+	}
+	return rules
+}
+
+// backgroundDeclaration returns the picture declared by the last background or background-image property
+// of a list of declarations. found is true when a background was declared, even without a picture
+func backgroundDeclaration(declarations string) (image string, found bool) {
+	for _, declaration := range splitOutside(declarations, ';') {
+		colon := strings.Index(declaration, ":")
+		if colon < 0 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(declaration[:colon]))
+		if property != "background" && property != "background-image" {
+			continue
+		}
+		found = true
+		image = cssURL(declaration[colon+1:])
+	}
+	return
+}
+
+// cssURL returns the first picture from the url() values of a CSS property
+func cssURL(value string) string {
+	for _, match := range cssURLPattern.FindAllStringSubmatch(value, -1) {
+		link := strings.TrimSpace(unescapeCSS(match[1] + match[2] + match[3]))
+		if link != "" && !isPlaceholder(link) {
+			return link
+		}
+	}
+	return ""
+}
+
+// unescapeCSS removes the backslashes escaping quotes and parentheses in a CSS string
+func unescapeCSS(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	buffer := &strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		buffer.WriteByte(value[i])
+	}
+	return buffer.String()
+}
+
+// splitOutside splits the string around each separator found outside of quotes, parentheses and brackets
+func splitOutside(value string, separator byte) []string {
+	parts := make([]string, 0)
+	for {
+		index := indexOutside(value, separator)
+		if index < 0 {
+			break
+		}
+		parts = append(parts, value[:index])
+		value = value[index+1:]
+	}
+	return append(parts, value)
+}
+
+// indexOutside returns the index of the first character found outside of quotes, parentheses and brackets
+func indexOutside(value string, char byte) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == char && depth == 0:
+			return i
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		}
+	}
+	return -1
+}
+
+// closingBrace returns the index of the brace closing the block opened at index open,
+// or the length of the string if the block is never closed
+func closingBrace(value string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(value)
+}
+
+// NodeText returns the text content of a node and all its descendants
+func NodeText(n *html.Node) string {
+	buffer := &strings.Builder{}
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buffer.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return buffer.String()
+}
+
+// Verify interface
+var _ Matcher = &BackgroundMatcher{}
@@ -0,0 +1,59 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const backgroundGallery = `<html><head>
+<style>
+@import url("theme.css");
+/* .slide { background: url(commented.jpg) } */
+.slide { background: #000 url('pictures/default.jpg') no-repeat center; }
+.slide.second { background-image: url("pictures/002.jpg"); }
+#third { background-image: url(pictures/003.jpg); }
+.slide.fourth::before { background-image: url(pictures/pseudo.jpg); }
+@media (min-width: 800px) {
+	.slide.fifth { background-image: url(pictures/005.jpg), linear-gradient(#fff, #000); }
+}
+@font-face { font-family: "gallery"; src: url(gallery.woff); }
+.slide.none { background: none; }
+</style>
+</head><body>
+<div class="slide" style="background-image:url(&quot;pictures/001.jpg&quot;)"></div>
+<div class="slide second"></div>
+<div class="slide third" id="third"></div>
+<div class="slide fourth"></div>
+<div class="slide fifth"></div>
+<div class="slide none"></div>
+<div class="slide" style="background-image: url(data:image/gif;base64,R0lGODlhAQABAAAAACw=)"></div>
+</body></html>`
+
+func TestBackgroundMatcher(t *testing.T) {
+	sel, err := cascadia.Parse(`div.slide`)
+	require.NoError(t, err)
+
+	var matcher Matcher = NewBackgroundMatcher(sel)
+	doc := NewDocument([]byte(backgroundGallery))
+	assert.Equal(t, "pictures/001.jpg", matcher.Find(doc))
+	assert.Equal(t, []string{
+		"pictures/001.jpg",
+		"pictures/002.jpg",
+		"pictures/003.jpg",
+		"pictures/default.jpg",
+		"pictures/005.jpg",
+	}, matcher.FindAll(doc))
+}
+
+func TestBackgroundMatcherNoPicture(t *testing.T) {
+	sel, err := cascadia.Parse(`li`)
+	require.NoError(t, err)
+
+	var matcher Matcher = NewBackgroundMatcher(sel)
+	doc := NewDocument(getTestData(t, "list_item"))
+	assert.Equal(t, "", matcher.Find(doc))
+	assert.Empty(t, matcher.FindAll(doc))
+}
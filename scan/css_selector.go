@@ -1,7 +1,6 @@
 package scan
 
 import (
-	"bytes"
 	"log"
 	"strings"
 
@@ -10,34 +9,28 @@ import (
 )
 
 type SelectorMatcher struct {
-	sel       cascadia.Sel
-	attribute string
-	source    *html.Node
+	sel        cascadia.Sel
+	attributes []string
 }
 
-func NewSelectorMatcher(sel cascadia.Sel, attribute string) *SelectorMatcher {
+// NewSelectorMatcher creates a matcher returning the value of the first usable attribute, in order
+func NewSelectorMatcher(sel cascadia.Sel, attributes ...string) *SelectorMatcher {
 	if sel == nil {
 		// might as well panic right now, no need to go much further
 		panic("invalid nil css selector pattern")
 	}
 	return &SelectorMatcher{
-		sel:       sel,
-		attribute: attribute,
+		sel:        sel,
+		attributes: attributes,
 	}
 }
 
-func (m *SelectorMatcher) Source(source []byte) error {
-	var err error
-
-	m.source, err = html.Parse(bytes.NewReader(source))
+func (m *SelectorMatcher) Find(doc *Document) string {
+	root, err := doc.Node()
 	if err != nil {
-		return err
+		return ""
 	}
-	return nil
-}
-
-func (m *SelectorMatcher) Find() string {
-	node := cascadia.Query(m.source, m.sel)
+	node := cascadia.Query(root, m.sel)
 	if node == nil {
 		return ""
 	}
@@ -47,14 +40,23 @@ func (m *SelectorMatcher) Find() string {
 	return buffer.String()
 }
 
-func (m *SelectorMatcher) FindAll() []string {
-	nodes := cascadia.QueryAll(m.source, m.sel)
+func (m *SelectorMatcher) FindAll(doc *Document) []string {
+	root, err := doc.Node()
+	if err != nil {
+		return nil
+	}
+	nodes := cascadia.QueryAll(root, m.sel)
 	if nodes == nil {
 		return nil
 	}
-	images := make([]string, len(nodes))
-	for i, node := range nodes {
-		images[i] = getAttribute(node, m.attribute)
+	images := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		image := getImageAttribute(node, m.attributes)
+		if image == "" {
+			// only placeholders or nothing at all
+			continue
+		}
+		images = append(images, image)
 	}
 	log.Printf("FindAll(): %v", images)
 	return images
@@ -0,0 +1,65 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lazyGallery = `<html><body>
+<img class="lazy" src="data:image/gif;base64,R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7" data-src="pictures/001.jpg">
+<img class="lazy" src="/images/blank.gif" data-original="pictures/002.jpg">
+<img class="lazy" src="pictures/003-thumb.jpg" width="1" height="1" data-lazy-src="pictures/003.jpg">
+<img class="lazy" src="spacer.gif" data-srcset="pictures/004-640.jpg 640w, pictures/004-1280.jpg 1280w, pictures/004-320.jpg 320w">
+<img class="lazy" src="pictures/005.jpg">
+<img class="lazy" src="placeholder.png">
+<img class="lazy" src="pictures/lazy-river.png">
+</body></html>`
+
+func TestSelectorMatcherFindAllSingleAttribute(t *testing.T) {
+	sel, err := cascadia.Parse(`li img[id^="wows"]`)
+	require.NoError(t, err)
+
+	matcher := NewSelectorMatcher(sel, "src")
+	doc := NewDocument(getTestData(t, "list_item"))
+	assert.Equal(t, expectedListItem, matcher.FindAll(doc))
+}
+
+func TestSelectorMatcherFindAllLazyAttributes(t *testing.T) {
+	sel, err := cascadia.Parse(`img.lazy`)
+	require.NoError(t, err)
+
+	matcher := NewSelectorMatcher(sel, "data-src", "data-original", "data-lazy-src", "data-srcset", "src")
+	doc := NewDocument([]byte(lazyGallery))
+	assert.Equal(t, []string{
+		"pictures/001.jpg",
+		"pictures/002.jpg",
+		"pictures/003.jpg",
+		"pictures/004-1280.jpg",
+		"pictures/005.jpg",
+		"pictures/lazy-river.png",
+	}, matcher.FindAll(doc))
+}
+
+func TestSrcsetCandidates(t *testing.T) {
+	testData := []struct {
+		srcset   string
+		expected string
+	}{
+		{"", ""},
+		{"picture.jpg", "picture.jpg"},
+		{"picture.jpg, picture@2x.jpg 2x", "picture@2x.jpg"},
+		{"picture@3x.jpg 3x,picture.jpg 1x", "picture@3x.jpg"},
+		{"small.jpg 320w, large.jpg 1280w, medium.jpg 640w", "large.jpg"},
+		{"/w_320,h_200/picture.jpg 320w, /w_1280,h_800/picture.jpg 1280w", "/w_1280,h_800/picture.jpg"},
+		{"data:image/gif;base64,AAAA 4000w, picture.jpg 800w", "picture.jpg"},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.srcset, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, largestSrcsetCandidate(testItem.srcset))
+		})
+	}
+}
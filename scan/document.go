@@ -0,0 +1,75 @@
+package scan
+
+import (
+	"bytes"
+	"net/url"
+	"sync"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+var styleSelector = cascadia.MustCompile("style")
+
+// Document is a page shared by all the matchers. The HTML tree and the stylesheets
+// are only parsed once, the first time a matcher needs them.
+// It is safe for concurrent use
+type Document struct {
+	source []byte
+	url    *url.URL
+
+	nodeOnce sync.Once
+	node     *html.Node
+	err      error
+
+	rulesOnce sync.Once
+	rules     []backgroundRule
+}
+
+// NewDocument creates a document from the source of a page
+func NewDocument(source []byte) *Document {
+	return &Document{
+		source: source,
+	}
+}
+
+// NewPageDocument creates a document from the source of a page downloaded from pageURL
+func NewPageDocument(source []byte, pageURL *url.URL) *Document {
+	return &Document{
+		source: source,
+		url:    pageURL,
+	}
+}
+
+// URL returns the address of the page, or nil when it's unknown
+func (d *Document) URL() *url.URL {
+	return d.url
+}
+
+// Source returns the raw content of the page
+func (d *Document) Source() []byte {
+	return d.source
+}
+
+// Node returns the root of the parsed HTML tree
+func (d *Document) Node() (*html.Node, error) {
+	d.nodeOnce.Do(func() {
+		d.node, d.err = html.Parse(bytes.NewReader(d.source))
+	})
+	return d.node, d.err
+}
+
+// backgroundRules returns the background pictures declared in the <style> blocks of the page
+func (d *Document) backgroundRules() []backgroundRule {
+	d.rulesOnce.Do(func() {
+		d.rules = make([]backgroundRule, 0)
+		node, err := d.Node()
+		if err != nil {
+			return
+		}
+		for _, style := range cascadia.QueryAll(node, styleSelector) {
+			d.rules = append(d.rules, parseBackgroundRules(NodeText(style))...)
+		}
+	})
+	return d.rules
+}
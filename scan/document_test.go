@@ -0,0 +1,108 @@
+package scan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentParsedOnce(t *testing.T) {
+	doc := NewDocument(getTestData(t, "list_item"))
+
+	first, err := doc.Node()
+	require.NoError(t, err)
+	second, err := doc.Node()
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+}
+
+func TestDocumentSharedByMatchers(t *testing.T) {
+	doc := NewDocument(getTestData(t, "list_item"))
+	generator := NewRegexpMatcher(regexp.MustCompile(`<!--\s*Generated by\s*(.*?)\s*-->`))
+	image := NewSelectorMatcher(mustParseSelector(t, `li img[id^="wows"]`), "src")
+
+	gallery := NewGallery(Config{DetectGenerator: generator, DetectImage: image}, doc)
+	assert.True(t, gallery.Match())
+	assert.Equal(t, "WOWSlider.com v5.6", gallery.GeneratedBy())
+	assert.Equal(t, expectedListItem, gallery.Find())
+
+	// the same matchers work on another document
+	other := NewGallery(Config{DetectGenerator: generator, DetectImage: image}, NewDocument([]byte("<html></html>")))
+	assert.Equal(t, "", other.GeneratedBy())
+	assert.Empty(t, other.Find())
+}
+
+func mustParseSelector(tb testing.TB, selector string) cascadia.Sel {
+	sel, err := cascadia.Parse(selector)
+	require.NoError(tb, err)
+	return sel
+}
+
+// largePage generates a gallery page of about 2 MB
+func largePage() []byte {
+	buffer := &strings.Builder{}
+	buffer.WriteString("<html><head><!-- Generated by benchmark --><style>.thumb { background: url(bg.jpg) }</style></head><body><ul>\n")
+	for i := 0; buffer.Len() < 2*1024*1024; i++ {
+		fmt.Fprintf(buffer, `<li class="item"><a href="pictures/%06d.jpg" title="picture %d"><img class="thumb" src="thumbnails/%06d.jpg" alt="picture %d"></a><p>Some description of the picture number %d</p></li>`+"\n", i, i, i, i, i)
+	}
+	buffer.WriteString("</ul></body></html>")
+	return []byte(buffer.String())
+}
+
+// benchmarkProfiles returns ten profiles with three matchers each, none of them detecting the gallery except the last one
+func benchmarkProfiles(tb testing.TB) []Config {
+	profiles := make([]Config, 0, 10)
+	for i := 0; i < 9; i++ {
+		profiles = append(profiles, Config{
+			DetectGenerator: NewRegexpMatcher(regexp.MustCompile(`<!--\s*Generated by\s*(.*?)\s*-->`)),
+			DetectGallery:   NewSelectorMatcher(mustParseSelector(tb, fmt.Sprintf("div.gallery-%d", i))),
+			DetectImage:     NewSelectorMatcher(mustParseSelector(tb, fmt.Sprintf("div.gallery-%d img", i)), "src"),
+		})
+	}
+	profiles = append(profiles, Config{
+		DetectGenerator: NewRegexpMatcher(regexp.MustCompile(`<!--\s*Generated by\s*(.*?)\s*-->`)),
+		DetectGallery:   NewBackgroundMatcher(mustParseSelector(tb, "img.thumb")),
+		DetectImage:     NewSelectorMatcher(mustParseSelector(tb, "li.item a"), "href"),
+	})
+	return profiles
+}
+
+// scanProfiles tries all the profiles, like the detection of a gallery from the configuration
+func scanProfiles(profiles []Config, document func() *Document) int {
+	found := 0
+	for _, profile := range profiles {
+		gallery := NewGallery(profile, document())
+		gallery.GeneratedBy()
+		if gallery.Match() {
+			found += len(gallery.Find())
+		}
+	}
+	return found
+}
+
+func BenchmarkSharedDocument(b *testing.B) {
+	source := largePage()
+	profiles := benchmarkProfiles(b)
+	b.SetBytes(int64(len(source)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		doc := NewDocument(source)
+		scanProfiles(profiles, func() *Document { return doc })
+	}
+}
+
+// BenchmarkDocumentPerMatcher parses the page for each matcher, like it was done before documents were shared
+func BenchmarkDocumentPerMatcher(b *testing.B) {
+	source := largePage()
+	profiles := benchmarkProfiles(b)
+	b.SetBytes(int64(len(source)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scanProfiles(profiles, func() *Document { return NewDocument(source) })
+	}
+}
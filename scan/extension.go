@@ -0,0 +1,59 @@
+package scan
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultExtensions are the picture extensions accepted by the built-in scanners when none is configured
+var DefaultExtensions = []string{"jpg", "jpeg"}
+
+// ExtensionFilter accepts the URLs whose path ends with one of the extensions
+type ExtensionFilter struct {
+	extensions map[string]bool
+}
+
+// NewExtensionFilter creates a filter from a list of extensions like "jpg", ".png" or "WEBP"
+func NewExtensionFilter(extensions []string) *ExtensionFilter {
+	filter := &ExtensionFilter{
+		extensions: make(map[string]bool, len(extensions)),
+	}
+	for _, extension := range extensions {
+		extension = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(extension), "."))
+		if extension != "" {
+			filter.extensions[extension] = true
+		}
+	}
+	return filter
+}
+
+// Accept returns true when the path of the URL ends with one of the extensions.
+// The query string and the fragment are ignored, so "photo.jpg?w=2000" is a jpg picture
+func (f *ExtensionFilter) Accept(link string) bool {
+	linkURL, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return false
+	}
+	extension := strings.ToLower(strings.TrimPrefix(path.Ext(linkURL.Path), "."))
+	return f.extensions[extension]
+}
+
+// Filter returns the URLs accepted by the filter
+func (f *ExtensionFilter) Filter(links []string) []string {
+	accepted := make([]string, 0, len(links))
+	for _, link := range links {
+		if f.Accept(link) {
+			accepted = append(accepted, link)
+		}
+	}
+	return accepted
+}
+
+// extensionFilter returns the filter configured for the gallery, or the default one
+func extensionFilter(cfg Config) *ExtensionFilter {
+	if len(cfg.Extensions) == 0 {
+		return NewExtensionFilter(DefaultExtensions)
+	}
+	return NewExtensionFilter(cfg.Extensions)
+}
@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtensionFilter(t *testing.T) {
+	filter := NewExtensionFilter([]string{"jpg", ".JPEG", " png ", "webp", "avif", ""})
+	testData := []struct {
+		link     string
+		expected bool
+	}{
+		{"picture.jpg", true},
+		{"picture.JPG", true},
+		{"picture.jpeg", true},
+		{"/images/picture.png", true},
+		{"https://example.com/picture.webp", true},
+		{"https://example.com/picture.avif?w=2000", true},
+		{"photo.jpg?w=2000&h=1000", true},
+		{"photo.jpg#top", true},
+		{"picture.gif", false},
+		{"picture", false},
+		{"/view.php?file=picture.jpg", false},
+		{"https://example.com/jpg", false},
+		{"picture.jpg.html", false},
+		{"%zz.jpg", false},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.link, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, filter.Accept(testItem.link))
+		})
+	}
+}
+
+func TestExtensionFilterFilter(t *testing.T) {
+	filter := NewExtensionFilter([]string{"png"})
+	assert.Equal(t, []string{"b.png", "d.png?v=1"}, filter.Filter([]string{"a.jpg", "b.png", "c.gif", "d.png?v=1"}))
+}
@@ -0,0 +1,320 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Feed is the type of gallery of the RSS, Atom and Media RSS feeds
+const Feed = "Feed"
+
+const (
+	atomNamespace     = "http://www.w3.org/2005/Atom"
+	mediaRSSNamespace = "http://search.yahoo.com/mrss/"
+)
+
+// feedTypes are the content types of the feeds. Feeds served as text/xml or application/xml are found by their root element
+var feedTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+	"application/rdf+xml":  true,
+}
+
+// FeedGallery reads the pictures of an RSS 2.0, RSS 1.0 or Atom feed: the enclosures and the Media RSS
+// <media:content> of each item, with the title of the item
+type FeedGallery struct {
+	doc    *Document
+	filter *ExtensionFilter
+	media  []string
+}
+
+// NewFeedGallery creates a new gallery
+func NewFeedGallery(cfg Config, doc *Document) (Gal, error) {
+	return &FeedGallery{
+		doc:    doc,
+		filter: extensionFilter(cfg),
+		media:  cfg.Media,
+	}, nil
+}
+
+// IsFeed returns true when the page is a feed, from its content type or its root element
+func IsFeed(contentType string, source []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && feedTypes[mediaType] {
+		return true
+	}
+	return feedRoot(source)
+}
+
+// feedRoot returns true when the root element is <rss>, <rdf:RDF> or an Atom <feed>
+func feedRoot(source []byte) bool {
+	decoder := newFeedDecoder(source)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch strings.ToLower(start.Name.Local) {
+			case "rss", "rdf":
+				return true
+			case "feed":
+				return start.Name.Space == atomNamespace || start.Name.Space == ""
+			}
+			return false
+		}
+	}
+}
+
+func newFeedDecoder(source []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(source))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	// the page is already converted to UTF-8, whatever the XML declaration says
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+// HasDetection returns true when the current type of gallery can be detected
+func (g *FeedGallery) HasDetection() bool {
+	return true
+}
+
+// Match returns true when the page is a feed
+func (g *FeedGallery) Match() bool {
+	return feedRoot(g.doc.Source())
+}
+
+// GeneratedBy returns the <generator> of the feed, or an empty string
+func (g *FeedGallery) GeneratedBy() string {
+	decoder := newFeedDecoder(g.doc.Source())
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "generator":
+			text := ""
+			if decoder.DecodeElement(&text, &start) != nil {
+				return ""
+			}
+			return strings.TrimSpace(text)
+		case "item", "entry":
+			// the generator comes before the items
+			return ""
+		}
+	}
+}
+
+// Find returns a list of images found in this gallery
+func (g *FeedGallery) Find() []string {
+	records := g.Records()
+	pictures := make([]string, len(records))
+	for i, record := range records {
+		pictures[i] = record.URL
+	}
+	return pictures
+}
+
+// Records returns the pictures of the items, in the order of the feed, titled after their item
+func (g *FeedGallery) Records() []Record {
+	wanted := make(map[string]bool, len(g.media))
+	for _, kind := range g.media {
+		wanted[strings.ToLower(strings.TrimSpace(kind))] = true
+	}
+	records := make([]Record, 0)
+	seen := make(map[string]bool)
+	add := func(link, title string) {
+		link = strings.TrimSpace(link)
+		if link == "" || seen[link] {
+			return
+		}
+		seen[link] = true
+		records = append(records, Record{URL: link, Title: title})
+	}
+
+	decoder := newFeedDecoder(g.doc.Source())
+	var item *feedItem
+	var group *feedGroup
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch {
+			case element.Name.Local == "item" || element.Name.Local == "entry":
+				item = &feedItem{}
+			case item == nil:
+				continue
+			case element.Name.Space == mediaRSSNamespace:
+				g.readMedia(element, item, &group, wanted)
+			case element.Name.Local == "title" && item.title == "":
+				text := ""
+				if decoder.DecodeElement(&text, &element) == nil {
+					item.title = strings.Join(strings.Fields(text), " ")
+				}
+			case element.Name.Local == "enclosure":
+				item.add(g.mediaLink(attribute(element, "url"), attribute(element, "type"), "", wanted))
+			case element.Name.Local == "link" && attribute(element, "rel") == "enclosure":
+				item.add(g.mediaLink(attribute(element, "href"), attribute(element, "type"), "", wanted))
+			}
+		case xml.EndElement:
+			switch {
+			case element.Name.Space == mediaRSSNamespace && element.Name.Local == "group" && item != nil && group != nil:
+				item.add(group.best())
+				group = nil
+			case (element.Name.Local == "item" || element.Name.Local == "entry") && item != nil:
+				for _, link := range item.links {
+					add(link, item.title)
+				}
+				if item.hasVideo {
+					add(item.thumbnail, item.title)
+				}
+				item = nil
+			}
+		}
+	}
+	return records
+}
+
+// readMedia reads a Media RSS element of an item: the <media:content> of a <media:group> are
+// the same media in several sizes, only the largest one is kept
+func (g *FeedGallery) readMedia(element xml.StartElement, item *feedItem, group **feedGroup, wanted map[string]bool) {
+	switch element.Name.Local {
+	case "group":
+		*group = &feedGroup{}
+	case "content":
+		link := g.mediaLink(attribute(element, "url"), attribute(element, "type"), attribute(element, "medium"), wanted)
+		if link == "" {
+			return
+		}
+		if kind := feedMediaKind(attribute(element, "type"), attribute(element, "medium")); kind == MediaVideo {
+			item.hasVideo = true
+		}
+		if *group == nil {
+			item.add(link)
+			return
+		}
+		width, _ := strconv.Atoi(attribute(element, "width"))
+		height, _ := strconv.Atoi(attribute(element, "height"))
+		(*group).candidates = append((*group).candidates, feedCandidate{
+			link:      link,
+			size:      width * height,
+			isDefault: attribute(element, "isDefault") == "true",
+		})
+	case "thumbnail":
+		// the poster of the video
+		if item.thumbnail == "" && wanted[MediaPoster] {
+			item.thumbnail = attribute(element, "url")
+		}
+	}
+}
+
+// mediaLink returns the link when it's a picture, or a kind of media wanted. The pictures of known type
+// are not filtered by extension, unless they have one
+func (g *FeedGallery) mediaLink(link, contentType, medium string, wanted map[string]bool) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+	switch kind := feedMediaKind(contentType, medium); kind {
+	case "image":
+		if g.filter.Accept(link) || !hasExtension(link) {
+			return link
+		}
+	case "":
+		if g.filter.Accept(link) {
+			return link
+		}
+	case MediaVideo, MediaAudio:
+		if wanted[kind] {
+			return link
+		}
+	}
+	return ""
+}
+
+// feedMediaKind returns "image", MediaVideo, MediaAudio, another kind of media, or an empty string when it's not known
+func feedMediaKind(contentType, medium string) string {
+	if medium = strings.ToLower(strings.TrimSpace(medium)); medium != "" {
+		return medium
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	kind, _, _ := strings.Cut(mediaType, "/")
+	return kind
+}
+
+func hasExtension(link string) bool {
+	linkURL, err := url.Parse(link)
+	return err == nil && path.Ext(linkURL.Path) != ""
+}
+
+func attribute(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return strings.TrimSpace(attr.Value)
+		}
+	}
+	return ""
+}
+
+type feedItem struct {
+	title     string
+	links     []string
+	thumbnail string
+	hasVideo  bool
+}
+
+func (i *feedItem) add(link string) {
+	if link != "" {
+		i.links = append(i.links, link)
+	}
+}
+
+type feedGroup struct {
+	candidates []feedCandidate
+}
+
+type feedCandidate struct {
+	link      string
+	size      int
+	isDefault bool
+}
+
+// best returns the largest media of the group, or the default one when the sizes are not known
+func (g *feedGroup) best() string {
+	if len(g.candidates) == 0 {
+		return ""
+	}
+	best := g.candidates[0]
+	for _, candidate := range g.candidates[1:] {
+		if candidate.size > best.size || (candidate.size == best.size && candidate.isDefault && !best.isDefault) {
+			best = candidate
+		}
+	}
+	return best.link
+}
+
+// Verify interfaces
+var (
+	_ RecordGal  = &FeedGallery{}
+	_ GalFactory = NewFeedGallery
+)
@@ -0,0 +1,154 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+<channel>
+	<title>Holidays</title>
+	<generator>PhotoBlog 2.1</generator>
+	<image><url>http://example.com/logo.jpg</url></image>
+	<item>
+		<title>Beach &amp; sunset</title>
+		<enclosure url="http://example.com/photos/beach.jpg" length="12345" type="image/jpeg"/>
+	</item>
+	<item>
+		<title>
+			Mountains
+		</title>
+		<media:group>
+			<media:content url="http://example.com/photos/mountains_s.jpg" width="320" height="240" medium="image"/>
+			<media:content url="http://example.com/photos/mountains_l.jpg" width="2048" height="1536" medium="image"/>
+			<media:content url="http://example.com/photos/mountains_m.jpg" width="1024" height="768" medium="image" isDefault="true"/>
+		</media:group>
+		<media:thumbnail url="http://example.com/thumbs/mountains.jpg"/>
+	</item>
+	<item>
+		<title>Lake</title>
+		<media:content url="http://example.com/photos/lake.jpg" type="image/jpeg"/>
+		<media:content url="http://example.com/photos/lake-night.jpg" type="image/jpeg"/>
+		<enclosure url="http://example.com/photos/lake.jpg" type="image/jpeg"/>
+	</item>
+	<item>
+		<title>Boat trip</title>
+		<media:content url="http://example.com/videos/boat.mp4" type="video/mp4"/>
+		<media:thumbnail url="http://example.com/thumbs/boat.jpg"/>
+	</item>
+	<item>
+		<title>Podcast</title>
+		<enclosure url="http://example.com/audio/episode.mp3" type="audio/mpeg"/>
+		<enclosure url="http://example.com/files/album.zip" type="application/zip"/>
+	</item>
+</channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Gallery</title>
+	<generator uri="https://example.com/">Gallery Engine</generator>
+	<entry>
+		<title type="html">First &lt;b&gt;picture&lt;/b&gt;</title>
+		<link rel="alternate" href="http://example.com/picture/1"/>
+		<link rel="enclosure" type="image/png" href="/pictures/1.png"/>
+	</entry>
+	<entry>
+		<title>Second picture</title>
+		<link rel="enclosure" type="image/webp" href="/pictures/2"/>
+	</entry>
+</feed>`
+
+const rdfFeed = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">
+	<channel rdf:about="http://example.com/"><title>Old feed</title></channel>
+	<item rdf:about="http://example.com/1">
+		<title>Old picture</title>
+		<enclosure xmlns="http://purl.oclc.org/net/rss_2.0/enc#" url="http://example.com/old.jpg" type="image/jpeg"/>
+	</item>
+</rdf:RDF>`
+
+func TestFeedGalleryRSS(t *testing.T) {
+	testData := []struct {
+		name     string
+		media    []string
+		expected []Record
+	}{
+		{"pictures", nil, []Record{
+			{URL: "http://example.com/photos/beach.jpg", Title: "Beach & sunset"},
+			{URL: "http://example.com/photos/mountains_l.jpg", Title: "Mountains"},
+			{URL: "http://example.com/photos/lake.jpg", Title: "Lake"},
+			{URL: "http://example.com/photos/lake-night.jpg", Title: "Lake"},
+		}},
+		{"media", MediaKinds, []Record{
+			{URL: "http://example.com/photos/beach.jpg", Title: "Beach & sunset"},
+			{URL: "http://example.com/photos/mountains_l.jpg", Title: "Mountains"},
+			{URL: "http://example.com/photos/lake.jpg", Title: "Lake"},
+			{URL: "http://example.com/photos/lake-night.jpg", Title: "Lake"},
+			{URL: "http://example.com/videos/boat.mp4", Title: "Boat trip"},
+			{URL: "http://example.com/thumbs/boat.jpg", Title: "Boat trip"},
+			{URL: "http://example.com/audio/episode.mp3", Title: "Podcast"},
+		}},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			gallery, err := NewFeedGallery(Config{Media: testItem.media}, NewDocument([]byte(rssFeed)))
+			require.NoError(t, err)
+			assert.True(t, gallery.Match())
+			assert.Equal(t, "PhotoBlog 2.1", gallery.GeneratedBy())
+			assert.Equal(t, testItem.expected, GalleryRecords(gallery))
+		})
+	}
+}
+
+func TestFeedGalleryAtom(t *testing.T) {
+	gallery, err := NewFeedGallery(Config{Extensions: []string{"png", "webp"}}, NewDocument([]byte(atomFeed)))
+	require.NoError(t, err)
+	assert.True(t, gallery.Match())
+	assert.Equal(t, "Gallery Engine", gallery.GeneratedBy())
+	assert.Equal(t, []Record{
+		{URL: "/pictures/1.png", Title: "First <b>picture</b>"},
+		{URL: "/pictures/2", Title: "Second picture"},
+	}, GalleryRecords(gallery))
+	assert.Equal(t, []string{"/pictures/1.png", "/pictures/2"}, gallery.Find())
+}
+
+func TestFeedGalleryRDF(t *testing.T) {
+	gallery, err := NewFeedGallery(Config{}, NewDocument([]byte(rdfFeed)))
+	require.NoError(t, err)
+	assert.True(t, gallery.Match())
+	assert.Equal(t, "", gallery.GeneratedBy())
+	assert.Equal(t, []Record{{URL: "http://example.com/old.jpg", Title: "Old picture"}}, GalleryRecords(gallery))
+}
+
+func TestFeedGalleryNoMatch(t *testing.T) {
+	gallery, err := NewFeedGallery(Config{}, NewDocument(getTestData(t, "anchor_href")))
+	require.NoError(t, err)
+	assert.False(t, gallery.Match())
+	assert.Empty(t, gallery.Find())
+}
+
+func TestIsFeed(t *testing.T) {
+	testData := []struct {
+		name        string
+		contentType string
+		source      string
+		expected    bool
+	}{
+		{"rss", "text/xml", rssFeed, true},
+		{"atom", "", atomFeed, true},
+		{"rdf", "application/xml", rdfFeed, true},
+		{"content type", "application/rss+xml; charset=utf-8", "", true},
+		{"html", "text/html", "<html><body><a href=\"feed.xml\">feed</a></body></html>", false},
+		{"other xml", "application/xml", `<?xml version="1.0"?><urlset><url><loc>http://example.com/</loc></url></urlset>`, false},
+		{"other feed element", "", `<html><feed>`, false},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, IsFeed(testItem.contentType, []byte(testItem.source)))
+		})
+	}
+}
@@ -1,7 +1,7 @@
 package scan
 
 // GalFactory is a concrete constructor for an object providing a Gal interface
-type GalFactory func(source []byte) (Gal, error)
+type GalFactory func(cfg Config, doc *Document) (Gal, error)
 
 // Gal is a gallery interface
 type Gal interface {
@@ -3,31 +3,15 @@ package scan
 // Gallery profile to detect images
 type Gallery struct {
 	cfg Config
+	doc *Document
 }
 
-// NewGallery creates a new profile of gallery
-func NewGallery(cfg Config, source []byte) (*Gallery, error) {
-	if cfg.DetectGenerator != nil {
-		err := cfg.DetectGenerator.Source(source)
-		if err != nil {
-			return nil, err
-		}
-	}
-	if cfg.DetectGallery != nil {
-		err := cfg.DetectGallery.Source(source)
-		if err != nil {
-			return nil, err
-		}
-	}
-	if cfg.DetectImage != nil {
-		err := cfg.DetectImage.Source(source)
-		if err != nil {
-			return nil, err
-		}
-	}
+// NewGallery creates a new profile of gallery on a document
+func NewGallery(cfg Config, doc *Document) *Gallery {
 	return &Gallery{
 		cfg: cfg,
-	}, nil
+		doc: doc,
+	}
 }
 
 // HasDetection returns true when the current type of gallery can be detected
@@ -38,7 +22,13 @@ func (g *Gallery) HasDetection() bool {
 // Match returns true if this profile *can* be a match for the current file.
 // if there's no gallery detection, it returns true to try to find images
 func (g *Gallery) Match() bool {
-	return g.cfg.DetectGallery == nil || g.cfg.DetectGallery.Find() != ""
+	return g.cfg.DetectGallery == nil || g.Detected()
+}
+
+// Detected returns true when the gallery detection finds the gallery in the document.
+// It returns false when there's no gallery detection
+func (g *Gallery) Detected() bool {
+	return g.cfg.DetectGallery != nil && g.cfg.DetectGallery.Find(g.doc) != ""
 }
 
 // GeneratedBy returns the name of the gallery generator (if available).
@@ -47,13 +37,61 @@ func (g *Gallery) GeneratedBy() string {
 	if g.cfg.DetectGenerator == nil {
 		return ""
 	}
-	return g.cfg.DetectGenerator.Find()
+	return g.cfg.DetectGenerator.Find(g.doc)
 }
 
-// Find returns a list of images found in this gallery
+// Find returns a list of images found in this gallery, followed by the media
 func (g *Gallery) Find() []string {
-	return g.cfg.DetectImage.FindAll()
+	images := g.cfg.DetectImage.FindAll(g.doc)
+	if len(g.cfg.Extensions) > 0 && images != nil {
+		images = NewExtensionFilter(g.cfg.Extensions).Filter(images)
+	}
+	return appendMedia(images, g.doc, g.cfg.Media, g.cfg.MediaScope)
+}
+
+// Records returns the images found in this gallery, with their title when the matcher finds one, followed by the media
+func (g *Gallery) Records() []Record {
+	return g.AppendMedia(g.ImageRecords())
+}
+
+// ImageRecords returns the images found in this gallery, without the media
+func (g *Gallery) ImageRecords() []Record {
+	matcher, ok := g.cfg.DetectImage.(RecordMatcher)
+	if !ok {
+		images := g.cfg.DetectImage.FindAll(g.doc)
+		if len(g.cfg.Extensions) > 0 && images != nil {
+			images = NewExtensionFilter(g.cfg.Extensions).Filter(images)
+		}
+		return NewRecords(images)
+	}
+	records := matcher.FindRecords(g.doc)
+	if len(g.cfg.Extensions) > 0 && records != nil {
+		filter := NewExtensionFilter(g.cfg.Extensions)
+		accepted := make([]Record, 0, len(records))
+		for _, record := range records {
+			if filter.Accept(record.URL) {
+				accepted = append(accepted, record)
+			}
+		}
+		records = accepted
+	}
+	return records
+}
+
+// AppendMedia adds the media of the gallery to the images, skipping the ones already found
+func (g *Gallery) AppendMedia(records []Record) []Record {
+	seen := make(map[string]bool, len(records))
+	for _, record := range records {
+		seen[record.URL] = true
+	}
+	for _, link := range findMedia(g.doc, g.cfg.Media, g.cfg.MediaScope) {
+		if !seen[link] {
+			seen[link] = true
+			records = append(records, Record{URL: link})
+		}
+	}
+	return records
 }
 
 // Verify interface
-var _ Gal = &Gallery{}
+var _ RecordGal = &Gallery{}
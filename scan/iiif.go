@@ -0,0 +1,283 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IIIF is the type of gallery of the IIIF Presentation manifests
+const IIIF = "IIIF"
+
+// iiifResource is any resource of a IIIF Presentation 2 or 3 manifest. Version 2 uses "@id" and "@type",
+// and lists the canvases in sequences; version 3 lists everything in "items"
+type iiifResource struct {
+	ID         string          `json:"id"`
+	LegacyID   string          `json:"@id"`
+	Type       string          `json:"type"`
+	LegacyType string          `json:"@type"`
+	Context    json.RawMessage `json:"@context"`
+	Label      json.RawMessage `json:"label"`
+	Profile    json.RawMessage `json:"profile"`
+	Service    json.RawMessage `json:"service"`
+	Items      []iiifResource  `json:"items"`
+	Body       json.RawMessage `json:"body"`
+	Motivation json.RawMessage `json:"motivation"`
+	Sequences  []iiifResource  `json:"sequences"`
+	Canvases   []iiifResource  `json:"canvases"`
+	Images     []iiifResource  `json:"images"`
+	Resource   *iiifResource   `json:"resource"`
+	Default    *iiifResource   `json:"default"`
+}
+
+func (r *iiifResource) id() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.LegacyID
+}
+
+func (r *iiifResource) resourceType() string {
+	if r.Type != "" {
+		return r.Type
+	}
+	return r.LegacyType
+}
+
+// IIIFGallery reads the pictures of the canvases of a IIIF Presentation 2 or 3 manifest.
+// The pictures are downloaded in full size from their Image API service, and named after their canvas
+type IIIFGallery struct {
+	doc *Document
+}
+
+// NewIIIFGallery creates a new gallery
+func NewIIIFGallery(cfg Config, doc *Document) (Gal, error) {
+	return &IIIFGallery{
+		doc: doc,
+	}, nil
+}
+
+// IsIIIFManifest returns true when the page is a IIIF Presentation manifest
+func IsIIIFManifest(source []byte) bool {
+	_, err := parseIIIFManifest(source)
+	return err == nil
+}
+
+func parseIIIFManifest(source []byte) (*iiifResource, error) {
+	source = bytes.TrimSpace(bytes.TrimPrefix(source, []byte("\xef\xbb\xbf")))
+	if !bytes.HasPrefix(source, []byte("{")) {
+		return nil, fmt.Errorf("not a IIIF manifest")
+	}
+	manifest := &iiifResource{}
+	if err := json.Unmarshal(source, manifest); err != nil {
+		return nil, err
+	}
+	switch manifest.resourceType() {
+	case "Manifest", "sc:Manifest":
+		return manifest, nil
+	}
+	return nil, fmt.Errorf("not a IIIF manifest")
+}
+
+// HasDetection returns true when the current type of gallery can be detected
+func (g *IIIFGallery) HasDetection() bool {
+	return true
+}
+
+// Match returns true when the page is a IIIF manifest
+func (g *IIIFGallery) Match() bool {
+	return IsIIIFManifest(g.doc.Source())
+}
+
+// GeneratedBy returns an empty string: manifests don't name their generator
+func (g *IIIFGallery) GeneratedBy() string {
+	return ""
+}
+
+// Find returns a list of images found in this gallery
+func (g *IIIFGallery) Find() []string {
+	records := g.Records()
+	pictures := make([]string, len(records))
+	for i, record := range records {
+		pictures[i] = record.URL
+	}
+	return pictures
+}
+
+// Records returns the pictures of the canvases in order. A canvas without label is named after the manifest and its position
+func (g *IIIFGallery) Records() []Record {
+	manifest, err := parseIIIFManifest(g.doc.Source())
+	if err != nil {
+		return nil
+	}
+	canvases := manifest.Items
+	for _, sequence := range manifest.Sequences {
+		// version 2: the first sequence is the default order, the others are alternatives
+		canvases = sequence.Canvases
+		break
+	}
+
+	title := iiifLabel(manifest.Label)
+	records := make([]Record, 0, len(canvases))
+	seen := make(map[string]bool)
+	for index, canvas := range canvases {
+		label := iiifLabel(canvas.Label)
+		if label == "" && title != "" {
+			label = fmt.Sprintf("%s %d", title, index+1)
+		}
+		for _, image := range canvasImages(canvas) {
+			link := iiifImageURL(image)
+			if link == "" || seen[link] {
+				continue
+			}
+			seen[link] = true
+			records = append(records, Record{URL: link, Title: label})
+		}
+	}
+	return records
+}
+
+// canvasImages returns the images painted on a canvas: the resources of the version 2 image annotations,
+// or the bodies of the version 3 painting annotations. Only the default item of a choice is kept
+func canvasImages(canvas iiifResource) []iiifResource {
+	images := make([]iiifResource, 0, 1)
+	add := func(image *iiifResource) {
+		if image == nil {
+			return
+		}
+		switch image.resourceType() {
+		case "oa:Choice":
+			image = image.Default
+		case "Choice":
+			if len(image.Items) == 0 {
+				return
+			}
+			image = &image.Items[0]
+		}
+		if image != nil && image.id() != "" {
+			images = append(images, *image)
+		}
+	}
+	for _, annotation := range canvas.Images {
+		add(annotation.Resource)
+	}
+	for _, page := range canvas.Items {
+		for _, annotation := range page.Items {
+			if motivation := iiifStrings(annotation.Motivation); len(motivation) > 0 && motivation[0] != "painting" {
+				continue
+			}
+			for _, body := range iiifResources(annotation.Body) {
+				body := body
+				add(&body)
+			}
+		}
+	}
+	return images
+}
+
+// iiifImageURL returns the full size picture from the Image API service of the image, or the image itself without service
+func iiifImageURL(image iiifResource) string {
+	for _, service := range iiifResources(image.Service) {
+		base := strings.TrimSuffix(strings.TrimSuffix(service.id(), "/info.json"), "/")
+		if base == "" {
+			continue
+		}
+		if iiifImageVersion(service) == 1 {
+			return base + "/full/full/0/native.jpg"
+		}
+		return base + "/full/max/0/default.jpg"
+	}
+	return image.id()
+}
+
+// iiifImageVersion returns the version of the Image API of a service, 2 when it's not known
+func iiifImageVersion(service iiifResource) int {
+	switch service.resourceType() {
+	case "ImageService1":
+		return 1
+	case "ImageService3":
+		return 3
+	}
+	context := strings.Join(iiifStrings(service.Context), " ") + " " + strings.Join(iiifStrings(service.Profile), " ")
+	switch {
+	case strings.Contains(context, "image-api/1"), strings.Contains(context, "iiif.io/api/image/1"):
+		return 1
+	case strings.Contains(context, "iiif.io/api/image/3"):
+		return 3
+	}
+	return 2
+}
+
+// iiifLabel returns the text of a label: a string or a list of values in version 2,
+// a language map in version 3. English is preferred, then the values without language
+func iiifLabel(label json.RawMessage) string {
+	languages := make(map[string][]string)
+	if err := json.Unmarshal(label, &languages); err == nil && len(languages) > 0 {
+		for _, language := range []string{"en", "none"} {
+			if values := languages[language]; len(values) > 0 {
+				return strings.TrimSpace(strings.Join(values, " "))
+			}
+		}
+		keys := make([]string, 0, len(languages))
+		for key := range languages {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return strings.TrimSpace(strings.Join(languages[keys[0]], " "))
+	}
+	return strings.TrimSpace(strings.Join(iiifStrings(label), " "))
+}
+
+// iiifStrings reads a string, or a list of strings or {"@value": "..."} objects
+func iiifStrings(value json.RawMessage) []string {
+	if len(value) == 0 {
+		return nil
+	}
+	single := ""
+	if err := json.Unmarshal(value, &single); err == nil {
+		return []string{single}
+	}
+	list := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(value, &list); err != nil {
+		list = []json.RawMessage{value}
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		text := ""
+		if err := json.Unmarshal(item, &text); err == nil {
+			values = append(values, text)
+			continue
+		}
+		object := struct {
+			Value string `json:"@value"`
+		}{}
+		if err := json.Unmarshal(item, &object); err == nil && object.Value != "" {
+			values = append(values, object.Value)
+		}
+	}
+	return values
+}
+
+// iiifResources reads a resource or a list of resources
+func iiifResources(value json.RawMessage) []iiifResource {
+	if len(value) == 0 {
+		return nil
+	}
+	list := make([]iiifResource, 0)
+	if err := json.Unmarshal(value, &list); err == nil {
+		return list
+	}
+	single := iiifResource{}
+	if err := json.Unmarshal(value, &single); err == nil {
+		return []iiifResource{single}
+	}
+	return nil
+}
+
+// Verify interfaces
+var (
+	_ RecordGal  = &IIIFGallery{}
+	_ GalFactory = NewIIIFGallery
+)
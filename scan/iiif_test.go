@@ -0,0 +1,165 @@
+package scan
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const iiifManifest2 = `{
+	"@context": "http://iiif.io/api/presentation/2/context.json",
+	"@id": "https://example.org/iiif/book/manifest",
+	"@type": "sc:Manifest",
+	"label": "Book of hours",
+	"sequences": [{
+		"@type": "sc:Sequence",
+		"canvases": [{
+			"@id": "https://example.org/iiif/book/canvas/1",
+			"@type": "sc:Canvas",
+			"label": "f. 1r",
+			"images": [{
+				"@type": "oa:Annotation",
+				"motivation": "sc:painting",
+				"resource": {
+					"@id": "https://example.org/iiif/book/page1/full/600,/0/default.jpg",
+					"@type": "dctypes:Image",
+					"service": {
+						"@context": "http://iiif.io/api/image/2/context.json",
+						"@id": "https://example.org/iiif/book/page1/",
+						"profile": "http://iiif.io/api/image/2/level1.json"
+					}
+				}
+			}]
+		}, {
+			"@id": "https://example.org/iiif/book/canvas/2",
+			"@type": "sc:Canvas",
+			"label": [{"@value": "f. 1v", "@language": "en"}],
+			"images": [{
+				"@type": "oa:Annotation",
+				"resource": {
+					"@type": "oa:Choice",
+					"default": {
+						"@id": "https://example.org/iiif/book/page2/full/full/0/native.jpg",
+						"service": {
+							"@context": "http://library.stanford.edu/iiif/image-api/1.1/context.json",
+							"@id": "https://example.org/iiif/book/page2/info.json"
+						}
+					},
+					"item": [{"@id": "https://example.org/iiif/book/page2-uv.jpg"}]
+				}
+			}]
+		}, {
+			"@id": "https://example.org/iiif/book/canvas/3",
+			"@type": "sc:Canvas",
+			"images": [{
+				"@type": "oa:Annotation",
+				"resource": {"@id": "https://example.org/static/page3.jpg", "@type": "dctypes:Image"}
+			}]
+		}]
+	}, {
+		"@type": "sc:Sequence",
+		"canvases": []
+	}]
+}`
+
+const iiifManifest3 = `{
+	"@context": ["http://www.w3.org/ns/anno.jsonld", "http://iiif.io/api/presentation/3/context.json"],
+	"id": "https://example.org/iiif/3/manifest",
+	"type": "Manifest",
+	"label": {"fr": ["Carnet de croquis"], "en": ["Sketchbook"]},
+	"items": [{
+		"id": "https://example.org/iiif/3/canvas/p1",
+		"type": "Canvas",
+		"label": {"none": ["p. 1"]},
+		"items": [{
+			"id": "https://example.org/iiif/3/page/p1/1",
+			"type": "AnnotationPage",
+			"items": [{
+				"id": "https://example.org/iiif/3/annotation/p1",
+				"type": "Annotation",
+				"motivation": "painting",
+				"body": {
+					"id": "https://example.org/iiif/3/image/p1/full/max/0/default.jpg",
+					"type": "Image",
+					"format": "image/jpeg",
+					"service": [{"id": "https://example.org/iiif/3/image/p1", "type": "ImageService3", "profile": "level1"}]
+				},
+				"target": "https://example.org/iiif/3/canvas/p1"
+			}, {
+				"type": "Annotation",
+				"motivation": "commenting",
+				"body": {"type": "TextualBody", "id": "https://example.org/comment"}
+			}]
+		}]
+	}, {
+		"id": "https://example.org/iiif/3/canvas/p2",
+		"type": "Canvas",
+		"label": {"de": ["Seite 2"]},
+		"items": [{
+			"type": "AnnotationPage",
+			"items": [{
+				"type": "Annotation",
+				"motivation": "painting",
+				"body": {
+					"type": "Choice",
+					"items": [
+						{"id": "https://example.org/iiif/3/image/p2-natural.jpg", "type": "Image",
+							"service": [{"@id": "https://example.org/iiif/2/p2", "@type": "ImageService2"}]},
+						{"id": "https://example.org/iiif/3/image/p2-infrared.jpg", "type": "Image"}
+					]
+				}
+			}]
+		}]
+	}]
+}`
+
+func TestIIIFGalleryPresentation2(t *testing.T) {
+	gallery, err := NewIIIFGallery(Config{}, NewDocument([]byte(iiifManifest2)))
+	require.NoError(t, err)
+	assert.True(t, gallery.Match())
+	assert.Equal(t, []Record{
+		{URL: "https://example.org/iiif/book/page1/full/max/0/default.jpg", Title: "f. 1r"},
+		{URL: "https://example.org/iiif/book/page2/full/full/0/native.jpg", Title: "f. 1v"},
+		{URL: "https://example.org/static/page3.jpg", Title: "Book of hours 3"},
+	}, GalleryRecords(gallery))
+}
+
+func TestIIIFGalleryPresentation3(t *testing.T) {
+	gallery, err := NewIIIFGallery(Config{}, NewDocument([]byte(iiifManifest3)))
+	require.NoError(t, err)
+	assert.True(t, gallery.Match())
+	assert.Equal(t, []Record{
+		{URL: "https://example.org/iiif/3/image/p1/full/max/0/default.jpg", Title: "p. 1"},
+		{URL: "https://example.org/iiif/2/p2/full/max/0/default.jpg", Title: "Seite 2"},
+	}, GalleryRecords(gallery))
+}
+
+func TestIsIIIFManifest(t *testing.T) {
+	assert.True(t, IsIIIFManifest([]byte(iiifManifest2)))
+	assert.True(t, IsIIIFManifest([]byte("\xef\xbb\xbf "+iiifManifest3)))
+	assert.False(t, IsIIIFManifest([]byte(`{"@context": "http://iiif.io/api/presentation/2/context.json", "@type": "sc:Collection"}`)))
+	assert.False(t, IsIIIFManifest([]byte(`{"type": "Manifest"`)))
+	assert.False(t, IsIIIFManifest([]byte(`<html><body>{"type": "Manifest"}</body></html>`)))
+}
+
+func TestIIIFLabel(t *testing.T) {
+	testData := []struct {
+		label    string
+		expected string
+	}{
+		{`"Title"`, "Title"},
+		{`["First", "Second"]`, "First Second"},
+		{`{"@value": "Value", "@language": "en"}`, "Value"},
+		{`{"en": ["English"], "none": ["None"]}`, "English"},
+		{`{"none": ["None"], "fr": ["Français"]}`, "None"},
+		{`{"it": ["Italiano"], "de": ["Deutsch"]}`, "Deutsch"},
+		{``, ""},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.expected, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, iiifLabel(json.RawMessage(testItem.label)))
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// jsLiteralParser reads JavaScript array and object literals, which are a superset of JSON:
+// single quoted or backtick strings, unquoted keys, trailing commas, comments, hexadecimal numbers and undefined.
+// Nothing is executed: any other expression (a variable, a function call, a concatenation) is an error.
+// The values are the same as decodeJSON: *jsonObject, []interface{}, string, json.Number, bool or nil
+type jsLiteralParser struct {
+	input    string
+	position int
+	depth    int
+}
+
+// maxJSLiteralDepth stops the parser on absurdly nested literals
+const maxJSLiteralDepth = 200
+
+var errNotLiteral = errors.New("not a JavaScript literal")
+
+// parseJSLiteral reads the literal at the beginning of source. It returns the value and the number of bytes read
+func parseJSLiteral(source string) (interface{}, int, error) {
+	parser := &jsLiteralParser{input: source}
+	value, err := parser.value()
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, parser.position, nil
+}
+
+// findJSLiterals returns all the top-level arrays and objects found in a script
+func findJSLiterals(script string) []interface{} {
+	literals := make([]interface{}, 0)
+	for position := 0; position < len(script); {
+		switch script[position] {
+		case '[', '{':
+			value, length, err := parseJSLiteral(script[position:])
+			if err == nil {
+				literals = append(literals, value)
+				position += length
+				continue
+			}
+			position++
+		case '"', '\'', '`':
+			// skip the string so its content is not taken for a literal
+			parser := &jsLiteralParser{input: script, position: position}
+			if _, err := parser.string(); err != nil {
+				position++
+				continue
+			}
+			position = parser.position
+		case '/':
+			parser := &jsLiteralParser{input: script, position: position}
+			if parser.skipComment() {
+				position = parser.position
+				continue
+			}
+			position++
+		default:
+			position++
+		}
+	}
+	return literals
+}
+
+func (p *jsLiteralParser) value() (interface{}, error) {
+	p.skipSpaces()
+	if p.position >= len(p.input) {
+		return nil, errNotLiteral
+	}
+	switch character := p.input[p.position]; {
+	case character == '{':
+		return p.object()
+	case character == '[':
+		return p.array()
+	case character == '"' || character == '\'' || character == '`':
+		return p.string()
+	case character == '-' || character == '+' || character == '.' || isDigit(character):
+		return p.number()
+	}
+	switch word := p.identifier(); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null", "undefined":
+		return nil, nil
+	case "":
+		return nil, errNotLiteral
+	default:
+		return nil, fmt.Errorf("unsupported expression %q", word)
+	}
+}
+
+func (p *jsLiteralParser) object() (interface{}, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	object := newJSONObject()
+	for {
+		p.skipSpaces()
+		if p.consume('}') {
+			return object, nil
+		}
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.consume(':') {
+			return nil, fmt.Errorf("expected ':' after key %q", key)
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		object.set(key, value)
+		p.skipSpaces()
+		if p.consume('}') {
+			return object, nil
+		}
+		if !p.consume(',') {
+			return nil, errors.New("expected ',' or '}' in object")
+		}
+	}
+}
+
+func (p *jsLiteralParser) key() (string, error) {
+	if p.position >= len(p.input) {
+		return "", errNotLiteral
+	}
+	character := p.input[p.position]
+	switch {
+	case character == '"' || character == '\'' || character == '`':
+		return p.string()
+	case isDigit(character):
+		number, err := p.number()
+		if err != nil {
+			return "", err
+		}
+		return number.String(), nil
+	}
+	key := p.identifier()
+	if key == "" {
+		return "", errors.New("invalid object key")
+	}
+	return key, nil
+}
+
+func (p *jsLiteralParser) array() (interface{}, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	array := make([]interface{}, 0)
+	for {
+		p.skipSpaces()
+		if p.consume(']') {
+			return array, nil
+		}
+		if p.consume(',') {
+			// hole in a sparse array
+			array = append(array, nil)
+			continue
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+		p.skipSpaces()
+		if p.consume(']') {
+			return array, nil
+		}
+		if !p.consume(',') {
+			return nil, errors.New("expected ',' or ']' in array")
+		}
+	}
+}
+
+// enter consumes the opening delimiter of an object or an array
+func (p *jsLiteralParser) enter() error {
+	p.depth++
+	if p.depth > maxJSLiteralDepth {
+		return errors.New("literal nested too deeply")
+	}
+	p.position++
+	return nil
+}
+
+func (p *jsLiteralParser) leave() {
+	p.depth--
+}
+
+func (p *jsLiteralParser) string() (string, error) {
+	quote := p.input[p.position]
+	p.position++
+	value := &strings.Builder{}
+	for p.position < len(p.input) {
+		character := p.input[p.position]
+		switch {
+		case character == quote:
+			p.position++
+			return value.String(), nil
+		case character == '\\':
+			if err := p.escape(value); err != nil {
+				return "", err
+			}
+		case quote == '`' && strings.HasPrefix(p.input[p.position:], "${"):
+			return "", errors.New("template literals with expressions are not supported")
+		case (character == '\n' || character == '\r') && quote != '`':
+			return "", errors.New("unterminated string")
+		default:
+			value.WriteByte(character)
+			p.position++
+		}
+	}
+	return "", errors.New("unterminated string")
+}
+
+func (p *jsLiteralParser) escape(value *strings.Builder) error {
+	// skip the backslash
+	p.position++
+	if p.position >= len(p.input) {
+		return errors.New("unterminated string")
+	}
+	character := p.input[p.position]
+	p.position++
+	switch character {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case 'b':
+		value.WriteByte('\b')
+	case 'f':
+		value.WriteByte('\f')
+	case 'v':
+		value.WriteByte('\v')
+	case '0':
+		value.WriteByte(0)
+	case '\n':
+		// line continuation
+	case '\r':
+		p.consume('\n')
+	case 'x':
+		code, err := p.hex(2)
+		if err != nil {
+			return err
+		}
+		value.WriteRune(rune(code))
+	case 'u':
+		code, err := p.unicode()
+		if err != nil {
+			return err
+		}
+		value.WriteRune(code)
+	default:
+		// \' \" \\ \/ and any other character escaped for no reason
+		value.WriteByte(character)
+	}
+	return nil
+}
+
+// unicode reads the code point of a \uXXXX or \u{X...} escape sequence, combining surrogate pairs
+func (p *jsLiteralParser) unicode() (rune, error) {
+	if p.consume('{') {
+		end := strings.IndexByte(p.input[p.position:], '}')
+		if end < 0 {
+			return 0, errors.New("invalid unicode escape sequence")
+		}
+		code, err := strconv.ParseUint(p.input[p.position:p.position+end], 16, 32)
+		if err != nil || code > unicode.MaxRune {
+			return 0, errors.New("invalid unicode escape sequence")
+		}
+		p.position += end + 1
+		return rune(code), nil
+	}
+	code, err := p.hex(4)
+	if err != nil {
+		return 0, err
+	}
+	if code >= 0xD800 && code < 0xDC00 && strings.HasPrefix(p.input[p.position:], `\u`) {
+		saved := p.position
+		p.position += 2
+		low, err := p.hex(4)
+		if err == nil && low >= 0xDC00 && low < 0xE000 {
+			return (rune(code)-0xD800)<<10 + (rune(low) - 0xDC00) + 0x10000, nil
+		}
+		p.position = saved
+	}
+	if code >= 0xD800 && code < 0xE000 {
+		return utf8.RuneError, nil
+	}
+	return rune(code), nil
+}
+
+func (p *jsLiteralParser) hex(digits int) (uint64, error) {
+	if p.position+digits > len(p.input) {
+		return 0, errors.New("invalid escape sequence")
+	}
+	code, err := strconv.ParseUint(p.input[p.position:p.position+digits], 16, 32)
+	if err != nil {
+		return 0, errors.New("invalid escape sequence")
+	}
+	p.position += digits
+	return code, nil
+}
+
+// number reads a decimal or hexadecimal number, returned as a JSON number
+func (p *jsLiteralParser) number() (json.Number, error) {
+	start := p.position
+	sign := ""
+	if p.input[p.position] == '-' || p.input[p.position] == '+' {
+		if p.input[p.position] == '-' {
+			sign = "-"
+		}
+		p.position++
+	}
+	digitsStart := p.position
+	for p.position < len(p.input) && isNumberCharacter(p.input[p.position], p.input[digitsStart:p.position]) {
+		p.position++
+	}
+	digits := strings.ToLower(p.input[digitsStart:p.position])
+	if strings.HasPrefix(digits, "0x") {
+		value, err := strconv.ParseUint(digits[2:], 16, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid number %q", p.input[start:p.position])
+		}
+		return json.Number(sign + strconv.FormatUint(value, 10)), nil
+	}
+	value, err := strconv.ParseFloat(digits, 64)
+	if err != nil || strings.ContainsAny(digits, "x_") {
+		return "", fmt.Errorf("invalid number %q", p.input[start:p.position])
+	}
+	if strings.HasPrefix(digits, ".") || strings.HasSuffix(digits, ".") {
+		// ".5" and "5." are not valid JSON numbers
+		return json.Number(sign + strconv.FormatFloat(value, 'f', -1, 64)), nil
+	}
+	return json.Number(sign + digits), nil
+}
+
+func (p *jsLiteralParser) identifier() string {
+	start := p.position
+	for p.position < len(p.input) {
+		character, size := utf8.DecodeRuneInString(p.input[p.position:])
+		if !(character == '_' || character == '$' || unicode.IsLetter(character) || (p.position > start && unicode.IsDigit(character))) {
+			break
+		}
+		p.position += size
+	}
+	return p.input[start:p.position]
+}
+
+// skipSpaces skips the white spaces and the comments
+func (p *jsLiteralParser) skipSpaces() {
+	for p.position < len(p.input) {
+		character, size := utf8.DecodeRuneInString(p.input[p.position:])
+		if unicode.IsSpace(character) || character == '\uFEFF' {
+			p.position += size
+			continue
+		}
+		if !p.skipComment() {
+			return
+		}
+	}
+}
+
+// skipComment skips a // or /* */ comment, and returns false when there's no comment at the current position
+func (p *jsLiteralParser) skipComment() bool {
+	rest := p.input[p.position:]
+	switch {
+	case strings.HasPrefix(rest, "//"):
+		end := strings.IndexAny(rest, "\r\n")
+		if end < 0 {
+			end = len(rest)
+		}
+		p.position += end
+		return true
+	case strings.HasPrefix(rest, "/*"):
+		end := strings.Index(rest[2:], "*/")
+		if end < 0 {
+			p.position = len(p.input)
+			return true
+		}
+		p.position += end + 4
+		return true
+	}
+	return false
+}
+
+func (p *jsLiteralParser) consume(character byte) bool {
+	if p.position < len(p.input) && p.input[p.position] == character {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func isDigit(character byte) bool {
+	return character >= '0' && character <= '9'
+}
+
+// isNumberCharacter returns true when the character can follow the beginning of a number.
+// A sign is only accepted in the exponent of a decimal number
+func isNumberCharacter(character byte, number string) bool {
+	if character == '+' || character == '-' {
+		return strings.HasSuffix(strings.ToLower(number), "e") && !strings.HasPrefix(strings.ToLower(number), "0x")
+	}
+	return isDigit(character) || character == '.' || character == 'x' || character == 'X' || character == '_' ||
+		(character >= 'a' && character <= 'f') || (character >= 'A' && character <= 'F')
+}
@@ -0,0 +1,137 @@
+package scan
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var expectedSlideshow = []string{
+	"data1/images/picture001.jpg",
+	"data1/images/picture002.jpg",
+	"data1/images/picture003.jpg",
+}
+
+func TestParseJSLiteral(t *testing.T) {
+	testData := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"json", `{"a": [1, 2.5, true, null]}`, `{"a":[1,2.5,true,null]}`},
+		{"unquoted keys", `{src: "a.jpg", $id: 1, _x2: 2, 3: 'three'}`, `{"$id":1,"3":"three","_x2":2,"src":"a.jpg"}`},
+		{"single quotes", `['it\'s', 'say "hi"', "\x41é\u{1F600}"]`, `["it's","say \"hi\"","Aé😀"]`},
+		{"backticks", "[`multi\nline`]", `["multi\nline"]`},
+		{"trailing commas", `{a: [1, 2,], b: {c: 3,},}`, `{"a":[1,2],"b":{"c":3}}`},
+		{"holes", `[1,,2]`, `[1,null,2]`},
+		{"comments", "[ // first\n 1, /* second */ 2 ]", `[1,2]`},
+		{"numbers", `[-1, +2, .5, 5., 0x1F, 1e3, -2.5E-2]`, `[-1,2,0.5,5,31,1e3,-2.5E-2]`},
+		{"undefined", `{a: undefined}`, `{"a":null}`},
+		{"surrogate pair", `["\uD83D\uDE00", "\ud83d\ude00"]`, `["😀","😀"]`},
+		{"lone surrogate", `["\uD83D", "\uDE00", "\uD83Dx", "\uD83D\u0041", "\uDE00\uD83D", "\u{D83D}"]`, `["\uFFFD","\uFFFD","\uFFFDx","\uFFFDA","\uFFFD\uFFFD","\uFFFD"]`},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			value, length, err := parseJSLiteral(testItem.source + "; var next = 1;")
+			require.NoError(t, err)
+			assert.Equal(t, len(testItem.source), length)
+			assert.JSONEq(t, testItem.expected, toJSON(t, value))
+		})
+	}
+}
+
+func TestParseJSLiteralInvalid(t *testing.T) {
+	for _, source := range []string{
+		`{a: 1 + 2}`,
+		`[images[0]]`,
+		`{src: base}`,
+		`{src: f()}`,
+		"[`${base}/a.jpg`]",
+		`['unterminated]`,
+		`{a 1}`,
+		`[1 2]`,
+		`[1-2]`,
+		``,
+	} {
+		t.Run(source, func(t *testing.T) {
+			_, _, err := parseJSLiteral(source)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFindJSLiterals(t *testing.T) {
+	script := `
+	var title = "[not an array]";
+	// var old = ['old.jpg'];
+	function show(index) { return slides[index]; }
+	var slides = ['a.jpg', 'b.jpg'];
+	new Gallery({images: slides, delay: 2 * 1000}, {speed: 3});
+	`
+	literals := findJSLiterals(script)
+	require.Len(t, literals, 2)
+	assert.JSONEq(t, `["a.jpg","b.jpg"]`, toJSON(t, literals[0]))
+	assert.JSONEq(t, `{"speed":3}`, toJSON(t, literals[1]))
+}
+
+func TestJSSelectorMatcher(t *testing.T) {
+	path, err := CompileJSONPath(`$[*].src`)
+	require.NoError(t, err)
+	sel, err := cascadia.Parse("script")
+	require.NoError(t, err)
+
+	var matcher Matcher = NewJSSelectorMatcher(sel, path)
+	doc := NewDocument(getTestData(t, "slideshow"))
+	assert.Equal(t, expectedSlideshow, matcher.FindAll(doc))
+	assert.Equal(t, "data1/images/picture001.jpg", matcher.Find(doc))
+
+	// the strict JSON matcher cannot read the literals
+	assert.Empty(t, NewJSONSelectorMatcher(sel, path).FindAll(doc))
+}
+
+func TestJSRegexpMatcher(t *testing.T) {
+	path, err := CompileJSONPath(`$[*]`)
+	require.NoError(t, err)
+
+	var matcher Matcher = NewJSRegexpMatcher(regexp.MustCompile(`var ws_tooltips\s*=\s*`), path)
+	doc := NewDocument(getTestData(t, "slideshow"))
+	assert.Equal(t, []string{
+		"data1/tooltips/picture001.jpg",
+		"data1/tooltips/picture002.jpg",
+		"data1/tooltips/picture003.jpg",
+	}, matcher.FindAll(doc))
+
+	matcher = NewJSRegexpMatcher(regexp.MustCompile(`var missing\s*=\s*`), path)
+	assert.Nil(t, matcher.FindAll(doc))
+}
+
+// toJSON encodes a value returned by the parser, for comparison
+func toJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(plainJSON(value))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func plainJSON(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case *jsonObject:
+		object := make(map[string]interface{}, len(typed.keys))
+		for _, key := range typed.keys {
+			object[key] = plainJSON(typed.values[key])
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(typed))
+		for i, item := range typed {
+			array[i] = plainJSON(item)
+		}
+		return array
+	}
+	return value
+}
@@ -0,0 +1,179 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+)
+
+// JSONMatcher finds pictures in JSON documents embedded in the page,
+// like <script type="application/ld+json"> or window.__DATA__ = {...}.
+// The documents are located with a CSS selector (text content of the elements)
+// or with a regular expression, then the pictures are extracted with a JSONPath-like expression.
+// The JavaScript variant reads array and object literals from the scripts instead of strict JSON
+type JSONMatcher struct {
+	sel        cascadia.Sel
+	pattern    *regexp.Regexp
+	path       *JSONPath
+	javascript bool
+}
+
+// NewJSONSelectorMatcher creates a matcher reading JSON documents from the text content of the elements matching the selector
+func NewJSONSelectorMatcher(sel cascadia.Sel, path *JSONPath) *JSONMatcher {
+	if sel == nil {
+		// might as well panic right now, no need to go much further
+		panic("invalid nil css selector pattern")
+	}
+	if path == nil {
+		panic("invalid nil JSON path")
+	}
+	return &JSONMatcher{
+		sel:  sel,
+		path: path,
+	}
+}
+
+// NewJSONRegexpMatcher creates a matcher reading JSON documents located by a regular expression.
+// The document starts at the first submatch, or right after the whole match if there was no catching parenthesis
+// (like `window\.__DATA__\s*=\s*`). Only one JSON value is read from there, so it doesn't matter what follows
+func NewJSONRegexpMatcher(pattern *regexp.Regexp, path *JSONPath) *JSONMatcher {
+	if pattern == nil {
+		// might as well panic right now, no need to go much further
+		panic("invalid nil regexp pattern")
+	}
+	if path == nil {
+		panic("invalid nil JSON path")
+	}
+	return &JSONMatcher{
+		pattern: pattern,
+		path:    path,
+	}
+}
+
+// NewJSSelectorMatcher creates a matcher reading all the JavaScript array and object literals
+// from the text content of the elements matching the selector (usually "script").
+// The code is never executed, so the literals must be written in full in the page
+func NewJSSelectorMatcher(sel cascadia.Sel, path *JSONPath) *JSONMatcher {
+	matcher := NewJSONSelectorMatcher(sel, path)
+	matcher.javascript = true
+	return matcher
+}
+
+// NewJSRegexpMatcher creates a matcher reading the JavaScript literal located by a regular expression,
+// like `var slides\s*=\s*`
+func NewJSRegexpMatcher(pattern *regexp.Regexp, path *JSONPath) *JSONMatcher {
+	matcher := NewJSONRegexpMatcher(pattern, path)
+	matcher.javascript = true
+	return matcher
+}
+
+// documents returns the JSON documents found in the page. Invalid documents are simply ignored
+func (m *JSONMatcher) documents(doc *Document) []interface{} {
+	documents := make([]interface{}, 0)
+
+	if m.pattern != nil {
+		source := doc.Source()
+		text := ""
+		if m.javascript {
+			text = string(source)
+		}
+		for _, match := range m.pattern.FindAllSubmatchIndex(source, -1) {
+			start := match[1]
+			if len(match) >= 4 && match[2] >= 0 {
+				start = match[2]
+			}
+			if m.javascript {
+				if document, _, err := parseJSLiteral(text[start:]); err == nil {
+					documents = append(documents, document)
+				}
+				continue
+			}
+			documents = appendJSONDocument(documents, source[start:])
+		}
+		return documents
+	}
+
+	node, err := doc.Node()
+	if err != nil {
+		return documents
+	}
+	for _, element := range cascadia.QueryAll(node, m.sel) {
+		if m.javascript {
+			documents = append(documents, findJSLiterals(unwrapScript(NodeText(element)))...)
+			continue
+		}
+		documents = appendJSONDocument(documents, []byte(unwrapScript(NodeText(element))))
+	}
+	return documents
+}
+
+// appendJSONDocument decodes the first JSON value from source
+func appendJSONDocument(documents []interface{}, source []byte) []interface{} {
+	document, err := decodeJSON(json.NewDecoder(bytes.NewReader(source)))
+	if err != nil {
+		return documents
+	}
+	return append(documents, document)
+}
+
+func (m *JSONMatcher) Find(doc *Document) string {
+	for _, document := range m.documents(doc) {
+		if found := jsonLinks(m.path.Evaluate(document), nil); len(found) > 0 {
+			return found[0]
+		}
+	}
+	return ""
+}
+
+func (m *JSONMatcher) FindAll(doc *Document) []string {
+	documents := m.documents(doc)
+	if len(documents) == 0 {
+		return nil
+	}
+	images := make([]string, 0)
+	for _, document := range documents {
+		images = jsonLinks(m.path.Evaluate(document), images)
+	}
+	return images
+}
+
+// jsonLinks appends the links found in the values:
+// strings are taken as they are, arrays are flattened,
+// and objects (like a schema.org ImageObject) give their "contentUrl" or "url" field
+func jsonLinks(values []interface{}, links []string) []string {
+	for _, value := range values {
+		switch typed := value.(type) {
+		case string:
+			if typed != "" && !isPlaceholder(typed) {
+				links = append(links, typed)
+			}
+		case []interface{}:
+			links = jsonLinks(typed, links)
+		case *jsonObject:
+			for _, key := range []string{"contentUrl", "url", "@id"} {
+				if link, ok := typed.get(key); ok {
+					links = jsonLinks([]interface{}{link}, links)
+					break
+				}
+			}
+		}
+	}
+	return links
+}
+
+// unwrapScript removes the HTML comment or CDATA markers sometimes surrounding the content of a script
+func unwrapScript(content string) string {
+	content = strings.TrimSpace(content)
+	for _, markers := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"//<![CDATA[", "//]]>"}} {
+		if strings.HasPrefix(content, markers[0]) && strings.HasSuffix(content, markers[1]) {
+			content = strings.TrimSpace(content[len(markers[0]) : len(content)-len(markers[1])])
+		}
+	}
+	return content
+}
+
+// Verify interface
+var _ Matcher = &JSONMatcher{}
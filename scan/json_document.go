@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// jsonObject is a decoded JSON object keeping its keys in the order of the document,
+// so the pictures are returned in the same order as the gallery
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{
+		keys:   make([]string, 0),
+		values: make(map[string]interface{}),
+	}
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, found := o.values[key]; !found {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) get(key string) (interface{}, bool) {
+	value, found := o.values[key]
+	return value, found
+}
+
+// decodeJSON reads the next JSON value from the decoder.
+// Objects are decoded as *jsonObject, arrays as []interface{}, numbers as json.Number
+func decodeJSON(decoder *json.Decoder) (interface{}, error) {
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONToken(decoder, token)
+}
+
+func decodeJSONToken(decoder *json.Decoder, token json.Token) (interface{}, error) {
+	delim, ok := token.(json.Delim)
+	if !ok {
+		// string, json.Number, bool or nil
+		return token, nil
+	}
+	switch delim {
+	case '{':
+		object := newJSONObject()
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := token.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid JSON object key %v", token)
+			}
+			value, err := decodeJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object.set(key, value)
+		}
+		// consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return object, nil
+
+	case '[':
+		array := make([]interface{}, 0)
+		for decoder.More() {
+			value, err := decodeJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		// consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return array, nil
+	}
+	return nil, errors.New("unexpected JSON delimiter " + delim.String())
+}
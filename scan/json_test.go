@@ -0,0 +1,76 @@
+package scan
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const jsonGallery = `<html><head>
+<meta property="og:image" content="https://example.com/cover.jpg">
+<script type="application/ld+json">
+{
+	"@context": "https://schema.org",
+	"@type": "ImageGallery",
+	"name": "Holidays",
+	"associatedMedia": [
+		{"@type": "ImageObject", "contentUrl": "https://example.com/pictures/001.jpg", "name": "first"},
+		{"@type": "ImageObject", "contentUrl": "https://example.com/pictures/002.jpg", "name": "second"},
+		"https://example.com/pictures/003.jpg"
+	]
+}
+</script>
+<script type="application/ld+json">{ invalid json }</script>
+<script>
+window.__DATA__ = {"gallery": {"items": [{"src": "/full/1.jpg"}, {"src": "/full/2.jpg"}]}};
+var other = {"items": [{"src": "/not-this-one.jpg"}]};
+</script>
+</head><body></body></html>`
+
+func TestJSONSelectorMatcher(t *testing.T) {
+	sel, err := cascadia.Parse(`script[type="application/ld+json"]`)
+	require.NoError(t, err)
+	path, err := CompileJSONPath(`$..associatedMedia[*]`)
+	require.NoError(t, err)
+
+	var matcher Matcher = NewJSONSelectorMatcher(sel, path)
+	doc := NewDocument([]byte(jsonGallery))
+	assert.Equal(t, "https://example.com/pictures/001.jpg", matcher.Find(doc))
+	assert.Equal(t, []string{
+		"https://example.com/pictures/001.jpg",
+		"https://example.com/pictures/002.jpg",
+		"https://example.com/pictures/003.jpg",
+	}, matcher.FindAll(doc))
+}
+
+func TestJSONRegexpMatcher(t *testing.T) {
+	path, err := CompileJSONPath(`$.gallery.items[*].src`)
+	require.NoError(t, err)
+
+	for _, pattern := range []string{
+		`window\.__DATA__\s*=\s*`,
+		`window\.__DATA__\s*=\s*(\{.*?\});`,
+	} {
+		t.Run(pattern, func(t *testing.T) {
+			var matcher Matcher = NewJSONRegexpMatcher(regexp.MustCompile(pattern), path)
+			doc := NewDocument([]byte(jsonGallery))
+			assert.Equal(t, "/full/1.jpg", matcher.Find(doc))
+			assert.Equal(t, []string{"/full/1.jpg", "/full/2.jpg"}, matcher.FindAll(doc))
+		})
+	}
+}
+
+func TestJSONMatcherNoDocument(t *testing.T) {
+	sel, err := cascadia.Parse(`script[type="application/ld+json"]`)
+	require.NoError(t, err)
+	path, err := CompileJSONPath(`$..contentUrl`)
+	require.NoError(t, err)
+
+	var matcher Matcher = NewJSONSelectorMatcher(sel, path)
+	doc := NewDocument(getTestData(t, "list_item"))
+	assert.Equal(t, "", matcher.Find(doc))
+	assert.Empty(t, matcher.FindAll(doc))
+}
@@ -0,0 +1,413 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a compiled JSONPath-like expression. The supported syntax is:
+//
+//	$                   root of the document (optional)
+//	.name  ['name']     child by name
+//	..name              any descendant by name
+//	.*  [*]             all children
+//	[0]  [-1]  [1:3]    array index and slice
+//	[?(@.type=='jpg')]  children matching a condition (==, != or existence)
+type JSONPath struct {
+	expression string
+	steps      []jsonStep
+}
+
+type jsonStepKind int
+
+const (
+	jsonStepName jsonStepKind = iota
+	jsonStepWildcard
+	jsonStepIndex
+	jsonStepSlice
+	jsonStepFilter
+)
+
+type jsonStep struct {
+	kind      jsonStepKind
+	recursive bool
+	names     []string
+	indexes   []int
+	start     *int
+	end       *int
+	filter    *jsonFilter
+}
+
+type jsonFilter struct {
+	path     *JSONPath
+	operator string
+	value    string
+}
+
+// CompileJSONPath parses a JSONPath expression
+func CompileJSONPath(expression string) (*JSONPath, error) {
+	parser := &jsonPathParser{input: strings.TrimSpace(expression)}
+	steps, err := parser.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON path %q: %w", expression, err)
+	}
+	return &JSONPath{
+		expression: expression,
+		steps:      steps,
+	}, nil
+}
+
+// String returns the source expression
+func (p *JSONPath) String() string {
+	return p.expression
+}
+
+// Evaluate returns all the values selected by the path, in document order
+func (p *JSONPath) Evaluate(document interface{}) []interface{} {
+	current := []interface{}{document}
+	for _, step := range p.steps {
+		next := make([]interface{}, 0)
+		for _, value := range current {
+			candidates := []interface{}{value}
+			if step.recursive {
+				candidates = jsonDescendants(value, candidates)
+			}
+			for _, candidate := range candidates {
+				next = append(next, step.apply(candidate)...)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func (s jsonStep) apply(value interface{}) []interface{} {
+	switch s.kind {
+	case jsonStepName:
+		found := make([]interface{}, 0, len(s.names))
+		if object, ok := value.(*jsonObject); ok {
+			for _, name := range s.names {
+				if child, ok := object.get(name); ok {
+					found = append(found, child)
+				}
+			}
+		}
+		return found
+
+	case jsonStepWildcard:
+		return jsonChildren(value)
+
+	case jsonStepIndex:
+		array, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		found := make([]interface{}, 0, len(s.indexes))
+		for _, index := range s.indexes {
+			if index < 0 {
+				index += len(array)
+			}
+			if index >= 0 && index < len(array) {
+				found = append(found, array[index])
+			}
+		}
+		return found
+
+	case jsonStepSlice:
+		array, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		start, end := 0, len(array)
+		if s.start != nil {
+			start = sliceBound(*s.start, len(array))
+		}
+		if s.end != nil {
+			end = sliceBound(*s.end, len(array))
+		}
+		if start >= end {
+			return nil
+		}
+		return array[start:end]
+
+	case jsonStepFilter:
+		found := make([]interface{}, 0)
+		for _, child := range jsonChildren(value) {
+			if s.filter.match(child) {
+				found = append(found, child)
+			}
+		}
+		return found
+	}
+	return nil
+}
+
+func (f *jsonFilter) match(value interface{}) bool {
+	found := f.path.Evaluate(value)
+	if f.operator == "" {
+		return len(found) > 0
+	}
+	equal := len(found) > 0 && jsonScalar(found[0]) == f.value
+	if f.operator == "!=" {
+		return !equal
+	}
+	return equal
+}
+
+// jsonScalar returns the string representation of a scalar value, used to compare values in filters
+func jsonScalar(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case json.Number:
+		if number, err := strconv.ParseFloat(typed.String(), 64); err == nil {
+			return strconv.FormatFloat(number, 'g', -1, 64)
+		}
+		return typed.String()
+	case bool:
+		return strconv.FormatBool(typed)
+	case nil:
+		return "null"
+	}
+	return ""
+}
+
+func sliceBound(bound, length int) int {
+	if bound < 0 {
+		bound += length
+	}
+	if bound < 0 {
+		return 0
+	}
+	if bound > length {
+		return length
+	}
+	return bound
+}
+
+// jsonChildren returns the direct children of an object or an array, in document order
+func jsonChildren(value interface{}) []interface{} {
+	switch typed := value.(type) {
+	case *jsonObject:
+		children := make([]interface{}, len(typed.keys))
+		for i, key := range typed.keys {
+			children[i] = typed.values[key]
+		}
+		return children
+	case []interface{}:
+		return typed
+	}
+	return nil
+}
+
+// jsonDescendants appends all descendants of value to the list, depth first
+func jsonDescendants(value interface{}, list []interface{}) []interface{} {
+	for _, child := range jsonChildren(value) {
+		list = append(list, child)
+		list = jsonDescendants(child, list)
+	}
+	return list
+}
+
+type jsonPathParser struct {
+	input    string
+	position int
+}
+
+func (p *jsonPathParser) parse() ([]jsonStep, error) {
+	steps := make([]jsonStep, 0)
+	if p.peek() == '$' || p.peek() == '@' {
+		p.position++
+	} else if p.position < len(p.input) && p.peek() != '.' && p.peek() != '[' {
+		// a path without its root: "images[*].url"
+		name := p.parseName()
+		steps = append(steps, jsonStep{kind: jsonStepName, names: []string{name}})
+	}
+	for p.position < len(p.input) {
+		recursive := false
+		switch {
+		case strings.HasPrefix(p.input[p.position:], ".."):
+			recursive = true
+			p.position += 2
+		case p.peek() == '.':
+			p.position++
+		case p.peek() == '[':
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", p.peek(), p.position)
+		}
+
+		var step jsonStep
+		var err error
+		switch p.peek() {
+		case '[':
+			step, err = p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+		case '*':
+			p.position++
+			step = jsonStep{kind: jsonStepWildcard}
+		default:
+			name := p.parseName()
+			if name == "" {
+				return nil, fmt.Errorf("missing name at position %d", p.position)
+			}
+			step = jsonStep{kind: jsonStepName, names: []string{name}}
+		}
+		step.recursive = recursive
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.position >= len(p.input) {
+		return 0
+	}
+	return p.input[p.position]
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for p.position < len(p.input) && isSpace(p.input[p.position]) {
+		p.position++
+	}
+}
+
+func (p *jsonPathParser) parseName() string {
+	start := p.position
+	for p.position < len(p.input) && p.peek() != '.' && p.peek() != '[' {
+		p.position++
+	}
+	return p.input[start:p.position]
+}
+
+func (p *jsonPathParser) parseBracket() (jsonStep, error) {
+	// skip the opening bracket
+	p.position++
+	end := indexOutside(p.input[p.position:], ']')
+	if end < 0 {
+		return jsonStep{}, fmt.Errorf("missing closing bracket after position %d", p.position)
+	}
+	content := strings.TrimSpace(p.input[p.position : p.position+end])
+	p.position += end + 1
+
+	switch {
+	case content == "*":
+		return jsonStep{kind: jsonStepWildcard}, nil
+
+	case strings.HasPrefix(content, "?"):
+		filter, err := parseJSONFilter(strings.TrimSpace(content[1:]))
+		if err != nil {
+			return jsonStep{}, err
+		}
+		return jsonStep{kind: jsonStepFilter, filter: filter}, nil
+
+	case strings.HasPrefix(content, "'") || strings.HasPrefix(content, `"`):
+		names := make([]string, 0)
+		for _, part := range splitOutside(content, ',') {
+			name, err := unquoteJSONPath(strings.TrimSpace(part))
+			if err != nil {
+				return jsonStep{}, err
+			}
+			names = append(names, name)
+		}
+		return jsonStep{kind: jsonStepName, names: names}, nil
+
+	case strings.Contains(content, ":"):
+		bounds := strings.SplitN(content, ":", 3)
+		step := jsonStep{kind: jsonStepSlice}
+		for i, pointer := range []**int{&step.start, &step.end} {
+			bound := strings.TrimSpace(bounds[i])
+			if bound == "" {
+				continue
+			}
+			value, err := strconv.Atoi(bound)
+			if err != nil {
+				return jsonStep{}, fmt.Errorf("invalid slice bound %q", bound)
+			}
+			*pointer = &value
+		}
+		return step, nil
+	}
+
+	indexes := make([]int, 0)
+	for _, part := range strings.Split(content, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return jsonStep{}, fmt.Errorf("invalid index %q", part)
+		}
+		indexes = append(indexes, index)
+	}
+	return jsonStep{kind: jsonStepIndex, indexes: indexes}, nil
+}
+
+// parseJSONFilter parses a condition like "(@.type == 'ImageObject')" or "(@.url)"
+func parseJSONFilter(content string) (*jsonFilter, error) {
+	if !strings.HasPrefix(content, "(") || !strings.HasSuffix(content, ")") {
+		return nil, fmt.Errorf("invalid filter %q", content)
+	}
+	content = strings.TrimSpace(content[1 : len(content)-1])
+	filter := &jsonFilter{}
+	for _, operator := range []string{"==", "!="} {
+		index := indexOutsideString(content, operator)
+		if index < 0 {
+			continue
+		}
+		filter.operator = operator
+		value := strings.TrimSpace(content[index+len(operator):])
+		content = strings.TrimSpace(content[:index])
+		if strings.HasPrefix(value, "'") || strings.HasPrefix(value, `"`) {
+			unquoted, err := unquoteJSONPath(value)
+			if err != nil {
+				return nil, err
+			}
+			filter.value = unquoted
+		} else {
+			var literal interface{}
+			decoder := json.NewDecoder(strings.NewReader(value))
+			decoder.UseNumber()
+			if err := decoder.Decode(&literal); err != nil {
+				return nil, fmt.Errorf("invalid filter value %q", value)
+			}
+			filter.value = jsonScalar(literal)
+		}
+		break
+	}
+	if !strings.HasPrefix(content, "@") {
+		return nil, fmt.Errorf("filter should start with @: %q", content)
+	}
+	path, err := CompileJSONPath(content)
+	if err != nil {
+		return nil, err
+	}
+	filter.path = path
+	return filter, nil
+}
+
+// indexOutsideString returns the index of the first occurrence of search outside of quotes
+func indexOutsideString(value, search string) int {
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(value[i:], search):
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteJSONPath(value string) (string, error) {
+	if len(value) < 2 || value[0] != value[len(value)-1] || (value[0] != '\'' && value[0] != '"') {
+		return "", fmt.Errorf("invalid quoted name %s", value)
+	}
+	return value[1 : len(value)-1], nil
+}
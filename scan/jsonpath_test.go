@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const jsonPathDocument = `{
+	"title": "gallery",
+	"pictures": [
+		{"type": "jpg", "url": "picture1.jpg", "size": 100},
+		{"type": "png", "url": "picture2.png", "size": 200},
+		{"type": "jpg", "url": "picture3.jpg", "size": 300, "thumbnail": {"url": "thumb3.jpg"}}
+	],
+	"z": {"url": "z.jpg"},
+	"a": {"url": "a.jpg"}
+}`
+
+func TestJSONPath(t *testing.T) {
+	document, err := decodeJSON(json.NewDecoder(strings.NewReader(jsonPathDocument)))
+	require.NoError(t, err)
+
+	testData := []struct {
+		path     string
+		expected []string
+	}{
+		{"$.title", []string{"gallery"}},
+		{"title", []string{"gallery"}},
+		{"$['title']", []string{"gallery"}},
+		{"$.pictures[*].url", []string{"picture1.jpg", "picture2.png", "picture3.jpg"}},
+		{"pictures[*].url", []string{"picture1.jpg", "picture2.png", "picture3.jpg"}},
+		{"$.pictures[0].url", []string{"picture1.jpg"}},
+		{"$.pictures[-1].url", []string{"picture3.jpg"}},
+		{"$.pictures[0,2].url", []string{"picture1.jpg", "picture3.jpg"}},
+		{"$.pictures[1:].url", []string{"picture2.png", "picture3.jpg"}},
+		{"$.pictures[:-1].url", []string{"picture1.jpg", "picture2.png"}},
+		{"$..url", []string{"picture1.jpg", "picture2.png", "picture3.jpg", "thumb3.jpg", "z.jpg", "a.jpg"}},
+		{"$.*.url", []string{"z.jpg", "a.jpg"}},
+		{"$.pictures[?(@.type == 'jpg')].url", []string{"picture1.jpg", "picture3.jpg"}},
+		{"$.pictures[?(@.type != \"jpg\")].url", []string{"picture2.png"}},
+		{"$.pictures[?(@.size == 200)].url", []string{"picture2.png"}},
+		{"$.pictures[?(@.thumbnail)].thumbnail.url", []string{"thumb3.jpg"}},
+		{"$.missing[*]", []string{}},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.path, func(t *testing.T) {
+			path, err := CompileJSONPath(testItem.path)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.expected, jsonLinks(path.Evaluate(document), []string{}))
+		})
+	}
+}
+
+func TestInvalidJSONPath(t *testing.T) {
+	for _, expression := range []string{
+		"$.pictures[",
+		"$.pictures[x]",
+		"$.pictures[?(type == 'jpg')]",
+		"$$",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := CompileJSONPath(expression)
+			assert.Error(t, err)
+		})
+	}
+}
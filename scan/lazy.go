@@ -0,0 +1,143 @@
+package scan
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// placeholderPattern detects the usual images displayed by lazy-loading galleries before the real picture is loaded.
+// Only whole file names match: "lazy-river.png" or "pixel-art.png" are real pictures
+var placeholderPattern = regexp.MustCompile(`(?i)(^|/)(blank|spacer|pixel|transparent|placeholder|empty|1x1|lazy|loading|loader)\.(gif|png|svg|webp)$`)
+
+// getImageAttribute returns the value of the first attribute holding a real picture.
+// srcset attributes return their largest candidate, and placeholders are skipped
+func getImageAttribute(n *html.Node, attributes []string) string {
+	for _, attribute := range attributes {
+		value := strings.TrimSpace(getAttribute(n, attribute))
+		if isSrcset(attribute) {
+			value = largestSrcsetCandidate(value)
+		}
+		if value == "" || isPlaceholder(value) {
+			continue
+		}
+		if !strings.HasPrefix(attribute, "data-") && isPlaceholderNode(n) {
+			// the browser is currently displaying a 1x1 image: the real one must be somewhere else
+			continue
+		}
+		return value
+	}
+	return ""
+}
+
+func isSrcset(attribute string) bool {
+	return strings.HasSuffix(strings.ToLower(attribute), "srcset")
+}
+
+// isPlaceholder returns true for inline data, empty links, and well known placeholder file names
+func isPlaceholder(value string) bool {
+	lower := strings.ToLower(value)
+	if strings.HasPrefix(lower, "data:") ||
+		strings.HasPrefix(lower, "javascript:") ||
+		lower == "about:blank" ||
+		lower == "#" {
+		return true
+	}
+	link, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return placeholderPattern.MatchString(link.Path)
+}
+
+// isPlaceholderNode returns true when the element is declared as a 1x1 image
+func isPlaceholderNode(n *html.Node) bool {
+	return getAttribute(n, "width") == "1" && getAttribute(n, "height") == "1"
+}
+
+// largestSrcsetCandidate returns the URL of the biggest picture from a srcset attribute,
+// like "picture-320.jpg 320w, picture-1280.jpg 1280w" or "picture.jpg, picture@2x.jpg 2x"
+func largestSrcsetCandidate(srcset string) string {
+	best := ""
+	bestSize := -1.0
+	for _, candidate := range parseSrcset(srcset) {
+		if candidate.size > bestSize && !isPlaceholder(candidate.url) {
+			best = candidate.url
+			bestSize = candidate.size
+		}
+	}
+	return best
+}
+
+type srcsetCandidate struct {
+	url  string
+	size float64
+}
+
+// parseSrcset follows the HTML algorithm loosely: URLs can contain commas,
+// and candidates are separated by a comma placed after the descriptor
+func parseSrcset(srcset string) []srcsetCandidate {
+	candidates := make([]srcsetCandidate, 0)
+	position := 0
+	for position < len(srcset) {
+		// skip whitespace and separators
+		for position < len(srcset) && (isSpace(srcset[position]) || srcset[position] == ',') {
+			position++
+		}
+		if position >= len(srcset) {
+			break
+		}
+		start := position
+		for position < len(srcset) && !isSpace(srcset[position]) {
+			position++
+		}
+		link := srcset[start:position]
+		descriptor := ""
+		if strings.HasSuffix(link, ",") {
+			link = strings.TrimRight(link, ",")
+		} else {
+			start = position
+			depth := 0
+			for position < len(srcset) && (srcset[position] != ',' || depth > 0) {
+				switch srcset[position] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+				position++
+			}
+			descriptor = strings.TrimSpace(srcset[start:position])
+		}
+		if link == "" {
+			continue
+		}
+		candidates = append(candidates, srcsetCandidate{url: link, size: srcsetSize(descriptor)})
+	}
+	return candidates
+}
+
+// srcsetSize converts a descriptor into a comparable size.
+// Width and density descriptors cannot be mixed in a valid srcset, so the number alone is enough
+func srcsetSize(descriptor string) float64 {
+	if descriptor == "" {
+		return 1
+	}
+	descriptor = strings.ToLower(descriptor)
+	switch descriptor[len(descriptor)-1] {
+	case 'w', 'x', 'h':
+		value, err := strconv.ParseFloat(descriptor[:len(descriptor)-1], 64)
+		if err != nil {
+			return 0
+		}
+		return value
+	}
+	return 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
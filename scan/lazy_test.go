@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPlaceholder(t *testing.T) {
+	testData := []struct {
+		value       string
+		placeholder bool
+	}{
+		{"data:image/gif;base64,R0lGODlhAQABAAAAACw=", true},
+		{"javascript:void(0)", true},
+		{"about:blank", true},
+		{"#", true},
+		{"blank.gif", true},
+		{"/img/spacer.gif", true},
+		{"https://example.com/assets/1x1.png", true},
+		{"pixel.gif", true},
+		{"PLACEHOLDER.SVG", true},
+		{"placeholder.png?v=2", true},
+		{"/js/lazy.gif", true},
+		{"lazy.png", true},
+		{"loading.gif", true},
+		{"photos/beach.jpg", false},
+		{"lazy-river.png", false},
+		{"/photos/empty-beach.webp", false},
+		{"pixel-art.png", false},
+		{"transparent-glass.png", false},
+		{"loading-dock.png", false},
+		{"my_blank.gif", false},
+		{"placeholder.jpg", false},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.value, func(t *testing.T) {
+			assert.Equal(t, testItem.placeholder, isPlaceholder(testItem.value))
+		})
+	}
+}
@@ -1,27 +1,28 @@
 package scan
 
 import (
-	"bytes"
-	"regexp"
-
 	"golang.org/x/net/html"
 )
 
 // LegacyListItemGallery scans pictures like <li><img src="data1/images/picture002.jpg" alt="picture-002" title="picture-002" id="wows1_1"/></li>
 type LegacyListItemGallery struct {
-	source []byte
+	doc    *Document
 	node   *html.Node
+	filter *ExtensionFilter
+	media  []string
 }
 
 // NewLegacyListItemGallery creates a new gallery
-func NewLegacyListItemGallery(source []byte) (Gal, error) {
-	node, err := html.Parse(bytes.NewReader(source))
+func NewLegacyListItemGallery(cfg Config, doc *Document) (Gal, error) {
+	node, err := doc.Node()
 	if err != nil {
 		return nil, err
 	}
 	return &LegacyListItemGallery{
-		source: source,
+		doc:    doc,
 		node:   node,
+		filter: extensionFilter(cfg),
+		media:  cfg.Media,
 	}, nil
 }
 
@@ -39,8 +40,7 @@ func (g *LegacyListItemGallery) Match() bool {
 // GeneratedBy returns the name of the gallery generator (if available).
 // It returns an empty string if not available
 func (g *LegacyListItemGallery) GeneratedBy() string {
-	pattern := regexp.MustCompile(`<!--\s*Generated by\s*(.*?)\s*-->`)
-	match := pattern.FindSubmatch(g.source)
+	match := generatorPattern.FindSubmatch(g.doc.Source())
 	if match == nil || len(match) != 2 {
 		return ""
 	}
@@ -55,7 +55,7 @@ func (g *LegacyListItemGallery) Find() []string {
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "li" {
 			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if picture, found := getPictureAttribute(c, "img", "src"); found {
+				if picture, found := getPictureAttribute(c, "img", "src", g.filter); found {
 					pictures = append(pictures, picture)
 				}
 			}
@@ -65,7 +65,7 @@ func (g *LegacyListItemGallery) Find() []string {
 		}
 	}
 	f(g.node)
-	return pictures
+	return appendMedia(pictures, g.doc, g.media, nil)
 }
 
 // Verify interfaces
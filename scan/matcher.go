@@ -1,7 +1,8 @@
 package scan
 
+// Matcher finds values in a document. Matchers don't keep any state,
+// so a matcher can be compiled once and used on many documents at the same time
 type Matcher interface {
-	Source(source []byte) error
-	Find() string
-	FindAll() []string
+	Find(doc *Document) string
+	FindAll(doc *Document) []string
 }
@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Kinds of media found in addition to the pictures
+const (
+	MediaVideo  = "video"
+	MediaAudio  = "audio"
+	MediaPoster = "poster"
+)
+
+// MediaKinds are all the kinds of media
+var MediaKinds = []string{MediaVideo, MediaAudio, MediaPoster}
+
+// lazy-loading attributes first
+var (
+	mediaAttributes  = []string{"data-src", "src"}
+	posterAttributes = []string{"data-poster", "poster"}
+)
+
+// IsMediaKind returns true when the kind of media is known (case insensitive)
+func IsMediaKind(kind string) bool {
+	for _, known := range MediaKinds {
+		if strings.EqualFold(strings.TrimSpace(kind), known) {
+			return true
+		}
+	}
+	return false
+}
+
+// findMedia returns the links of the <video> and <audio> elements and the poster images of the videos, in document order.
+// Only the kinds of media asked are returned, and only inside the elements matching the scope when it's not nil
+func findMedia(doc *Document, kinds []string, scope cascadia.Sel) []string {
+	if len(kinds) == 0 {
+		return nil
+	}
+	node, err := doc.Node()
+	if err != nil {
+		return nil
+	}
+	wanted := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[strings.ToLower(strings.TrimSpace(kind))] = true
+	}
+
+	links := make([]string, 0)
+	var f func(*html.Node, bool)
+	f = func(n *html.Node, inScope bool) {
+		inScope = inScope || (n.Type == html.ElementNode && scope.Match(n))
+		if n.Type == html.ElementNode && inScope {
+			switch n.DataAtom {
+			case atom.Video:
+				if poster := getImageAttribute(n, posterAttributes); wanted[MediaPoster] && poster != "" {
+					links = append(links, poster)
+				}
+				if source := mediaSource(n); wanted[MediaVideo] && source != "" {
+					links = append(links, source)
+				}
+				return
+			case atom.Audio:
+				if source := mediaSource(n); wanted[MediaAudio] && source != "" {
+					links = append(links, source)
+				}
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c, inScope)
+		}
+	}
+	f(node, scope == nil)
+	return links
+}
+
+// mediaSource returns the link of a <video> or <audio> element, or the one of its first <source>.
+// The other sources are the same media in other formats
+func mediaSource(n *html.Node) string {
+	if source := getImageAttribute(n, mediaAttributes); source != "" {
+		return source
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Source {
+			if source := getImageAttribute(c, mediaAttributes); source != "" {
+				return source
+			}
+		}
+	}
+	return ""
+}
+
+// appendMedia adds the media of the page (or of its scope) to the pictures, skipping the links already found
+func appendMedia(pictures []string, doc *Document, kinds []string, scope cascadia.Sel) []string {
+	media := findMedia(doc, kinds, scope)
+	if len(media) == 0 {
+		return pictures
+	}
+	seen := make(map[string]bool, len(pictures))
+	for _, picture := range pictures {
+		seen[picture] = true
+	}
+	for _, link := range media {
+		if !seen[link] {
+			seen[link] = true
+			pictures = append(pictures, link)
+		}
+	}
+	return pictures
+}
@@ -0,0 +1,105 @@
+package scan
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/stretchr/testify/assert"
+)
+
+const mediaGallery = `<html><body>
+<ul>
+	<li><img src="photos/001.jpg"></li>
+	<li><img src="photos/002.jpg"></li>
+</ul>
+<video poster="posters/clip.jpg" controls>
+	<source src="videos/clip.webm" type="video/webm">
+	<source src="videos/clip.mp4" type="video/mp4">
+</video>
+<video src="videos/intro.mp4" data-poster="posters/intro.jpg" poster="data:image/gif;base64,R0lGODlhAQABAAAAACw="></video>
+<audio controls><source data-src="audio/theme.mp3" src=""></audio>
+</body></html>`
+
+const scopedMediaGallery = `<html><body>
+<header><video src="videos/advert.mp4" poster="posters/advert.jpg"></video></header>
+<div class="gallery">
+	<img src="photos/001.jpg">
+	<figure><video src="videos/clip.mp4" poster="posters/clip.jpg"></video></figure>
+</div>
+<div class="gallery"><audio src="audio/theme.mp3"></audio></div>
+<footer><audio src="audio/jingle.mp3"></audio></footer>
+</body></html>`
+
+func TestFindMedia(t *testing.T) {
+	doc := NewDocument([]byte(mediaGallery))
+
+	testData := []struct {
+		name     string
+		kinds    []string
+		expected []string
+	}{
+		{"none", nil, nil},
+		{"video", []string{MediaVideo}, []string{"videos/clip.webm", "videos/intro.mp4"}},
+		{"audio", []string{"AUDIO"}, []string{"audio/theme.mp3"}},
+		{"poster", []string{MediaPoster}, []string{"posters/clip.jpg", "posters/intro.jpg"}},
+		{"all", MediaKinds, []string{"posters/clip.jpg", "videos/clip.webm", "posters/intro.jpg", "videos/intro.mp4", "audio/theme.mp3"}},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, findMedia(doc, testItem.kinds, nil))
+		})
+	}
+}
+
+func TestFindMediaInScope(t *testing.T) {
+	doc := NewDocument([]byte(scopedMediaGallery))
+
+	testData := []struct {
+		name     string
+		scope    cascadia.Sel
+		expected []string
+	}{
+		{"whole page", nil, []string{"posters/advert.jpg", "videos/advert.mp4", "posters/clip.jpg", "videos/clip.mp4", "audio/theme.mp3", "audio/jingle.mp3"}},
+		{"gallery", mustParseSelector(t, "div.gallery"), []string{"posters/clip.jpg", "videos/clip.mp4", "audio/theme.mp3"}},
+		{"media element", mustParseSelector(t, "footer audio"), []string{"audio/jingle.mp3"}},
+		{"no match", mustParseSelector(t, "#missing"), []string{}},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, findMedia(doc, MediaKinds, testItem.scope))
+		})
+	}
+}
+
+func TestIsMediaKind(t *testing.T) {
+	assert.True(t, IsMediaKind("Video"))
+	assert.True(t, IsMediaKind("poster"))
+	assert.False(t, IsMediaKind("image"))
+}
+
+func TestGalleryMedia(t *testing.T) {
+	doc := NewDocument([]byte(mediaGallery))
+	cfg := Config{
+		DetectImage: NewRegexpMatcher(regexp.MustCompile(`src="(photos/[^"]+)"`)),
+		Extensions:  []string{"jpg"},
+		Media:       []string{MediaVideo},
+	}
+
+	// the media are not filtered by extension
+	expected := []string{"photos/001.jpg", "photos/002.jpg", "videos/clip.webm", "videos/intro.mp4"}
+	assert.Equal(t, expected, NewGallery(cfg, doc).Find())
+	assert.Equal(t, NewRecords(expected), NewGallery(cfg, doc).Records())
+
+	gallery, err := NewLegacyListItemGallery(Config{Media: []string{MediaPoster}}, doc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"photos/001.jpg", "photos/002.jpg", "posters/clip.jpg", "posters/intro.jpg"}, gallery.Find())
+
+	// the media outside the scope of the profile are left out
+	doc = NewDocument([]byte(scopedMediaGallery))
+	cfg.MediaScope = mustParseSelector(t, "div.gallery")
+	cfg.Media = MediaKinds
+	expected = []string{"photos/001.jpg", "posters/clip.jpg", "videos/clip.mp4", "audio/theme.mp3"}
+	assert.Equal(t, expected, NewGallery(cfg, doc).Find())
+	assert.Equal(t, NewRecords(expected), NewGallery(cfg, doc).Records())
+}
@@ -0,0 +1,39 @@
+package scan
+
+// Record is a picture found in a page. The title is optional
+type Record struct {
+	URL   string
+	Title string
+}
+
+// NewRecords creates a list of records from the URL of the pictures
+func NewRecords(links []string) []Record {
+	if links == nil {
+		return nil
+	}
+	records := make([]Record, len(links))
+	for i, link := range links {
+		records[i] = Record{URL: link}
+	}
+	return records
+}
+
+// RecordMatcher is a matcher able to return a title with each picture
+type RecordMatcher interface {
+	Matcher
+	FindRecords(doc *Document) []Record
+}
+
+// RecordGal is a gallery able to return a title with each picture
+type RecordGal interface {
+	Gal
+	Records() []Record
+}
+
+// GalleryRecords returns the pictures of the gallery with their title when it knows them
+func GalleryRecords(gallery Gal) []Record {
+	if recordGallery, ok := gallery.(RecordGal); ok {
+		return recordGallery.Records()
+	}
+	return NewRecords(gallery.Find())
+}
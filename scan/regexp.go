@@ -4,7 +4,6 @@ import "regexp"
 
 type RegexpMatcher struct {
 	pattern *regexp.Regexp
-	source  []byte
 }
 
 func NewRegexpMatcher(pattern *regexp.Regexp) *RegexpMatcher {
@@ -17,13 +16,8 @@ func NewRegexpMatcher(pattern *regexp.Regexp) *RegexpMatcher {
 	}
 }
 
-func (m *RegexpMatcher) Source(source []byte) error {
-	m.source = source
-	return nil
-}
-
-func (m *RegexpMatcher) Find() string {
-	found := m.pattern.FindSubmatch(m.source)
+func (m *RegexpMatcher) Find(doc *Document) string {
+	found := m.pattern.FindSubmatch(doc.Source())
 	if found == nil {
 		return ""
 	}
@@ -35,8 +29,8 @@ func (m *RegexpMatcher) Find() string {
 	return string(found[0])
 }
 
-func (m *RegexpMatcher) FindAll() []string {
-	all := m.pattern.FindAllSubmatch(m.source, -1)
+func (m *RegexpMatcher) FindAll(doc *Document) []string {
+	all := m.pattern.FindAllSubmatch(doc.Source(), -1)
 	if all == nil {
 		return nil
 	}
@@ -0,0 +1,393 @@
+package scan
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/xpath"
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+	"golang.org/x/net/html"
+)
+
+// Default limits of a script run
+const (
+	DefaultScriptTimeout   = 5 * time.Second
+	DefaultScriptMaxSteps  = 10_000_000
+	DefaultScriptMaxMemory = 256 << 20
+)
+
+// scriptFunction is the function the script must define
+const scriptFunction = "find"
+
+// ScriptLimits bound each run of a script. A zero value uses the default limit.
+// Starlark doesn't account for the memory of a script, so each run has a process of its own where MaxMemory
+// bounds the memory used on top of the page
+type ScriptLimits struct {
+	Timeout  time.Duration
+	MaxSteps uint64
+	// MaxMemory is in bytes
+	MaxMemory uint64
+}
+
+func (l ScriptLimits) withDefaults() ScriptLimits {
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultScriptTimeout
+	}
+	if l.MaxSteps == 0 {
+		l.MaxSteps = DefaultScriptMaxSteps
+	}
+	if l.MaxMemory == 0 {
+		l.MaxMemory = DefaultScriptMaxMemory
+	}
+	return l
+}
+
+// ScriptMatcher runs a Starlark script to find the pictures. The script defines a function find(page)
+// returning a list of URLs, or a list of dicts {"url": ..., "title": ...}.
+// The page gives access to its source, its URL and the parsed HTML tree (see the README for the full API).
+// Scripts have no access to the file system or the network
+type ScriptMatcher struct {
+	name    string
+	source  []byte
+	program *starlark.Program
+	limits  ScriptLimits
+}
+
+// NewScriptMatcher compiles a Starlark script. The name is used in the error messages
+func NewScriptMatcher(name string, source []byte, limits ScriptLimits) (*ScriptMatcher, error) {
+	_, program, err := starlark.SourceProgramOptions(scriptOptions, name, source, scriptPredeclared.Has)
+	if err != nil {
+		return nil, err
+	}
+	return &ScriptMatcher{
+		name:    name,
+		source:  source,
+		program: program,
+		limits:  limits.withDefaults(),
+	}, nil
+}
+
+var scriptOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+var scriptPredeclared = starlark.StringDict{
+	"json": json.Module,
+	"re": &starlarkstruct.Module{
+		Name: "re",
+		Members: starlark.StringDict{
+			"findall": starlark.NewBuiltin("re.findall", scriptFindAll),
+			"search":  starlark.NewBuiltin("re.search", scriptSearch),
+		},
+	},
+	"base64": &starlarkstruct.Module{
+		Name: "base64",
+		Members: starlark.StringDict{
+			"decode": starlark.NewBuiltin("base64.decode", scriptBase64Decode),
+		},
+	},
+}
+
+func (m *ScriptMatcher) Find(doc *Document) string {
+	records := m.FindRecords(doc)
+	if len(records) == 0 {
+		return ""
+	}
+	return records[0].URL
+}
+
+func (m *ScriptMatcher) FindAll(doc *Document) []string {
+	records := m.FindRecords(doc)
+	if records == nil {
+		return nil
+	}
+	links := make([]string, len(records))
+	for i, record := range records {
+		links[i] = record.URL
+	}
+	return links
+}
+
+// FindRecords runs the script on the document. Errors are logged and give no picture
+func (m *ScriptMatcher) FindRecords(doc *Document) []Record {
+	records, err := m.Run(doc)
+	if err != nil {
+		log.Printf("Error: script %s: %v", m.name, err)
+		return nil
+	}
+	return records
+}
+
+// Run runs the script on the document, within the limits
+func (m *ScriptMatcher) Run(doc *Document) ([]Record, error) {
+	return m.runProcess(doc)
+}
+
+// run runs the script in the current process: only its time and its steps are limited
+func (m *ScriptMatcher) run(doc *Document) ([]Record, error) {
+	thread := &starlark.Thread{
+		Name: m.name,
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Printf("script %s: %s", m.name, msg)
+		},
+		Load: func(*starlark.Thread, string) (starlark.StringDict, error) {
+			return nil, errors.New("load is not allowed in scripts")
+		},
+	}
+	thread.SetMaxExecutionSteps(m.limits.MaxSteps)
+	stop := watchScript(thread, m.limits)
+	defer stop()
+
+	globals, err := m.program.Init(thread, scriptPredeclared)
+	if err != nil {
+		return nil, err
+	}
+	find, ok := globals[scriptFunction].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("the script must define a function %s(page)", scriptFunction)
+	}
+	result, err := starlark.Call(thread, find, starlark.Tuple{newScriptPage(doc)}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return scriptRecords(result)
+}
+
+// watchScript cancels the thread when the script runs for too long.
+// The returned function stops watching
+func watchScript(thread *starlark.Thread, limits ScriptLimits) func() {
+	timeout := time.AfterFunc(limits.Timeout, func() {
+		thread.Cancel(fmt.Sprintf("timeout after %s", limits.Timeout))
+	})
+	return func() {
+		timeout.Stop()
+	}
+}
+
+// scriptRecords converts the value returned by the script
+func scriptRecords(result starlark.Value) ([]Record, error) {
+	if result == starlark.None {
+		return nil, nil
+	}
+	iterable, ok := result.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("%s must return a list, not %s", scriptFunction, result.Type())
+	}
+	records := make([]Record, 0)
+	iterator := iterable.Iterate()
+	defer iterator.Done()
+	var item starlark.Value
+	for iterator.Next(&item) {
+		record, err := scriptRecord(item)
+		if err != nil {
+			return nil, err
+		}
+		if record.URL != "" {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func scriptRecord(item starlark.Value) (Record, error) {
+	switch typed := item.(type) {
+	case starlark.String:
+		return Record{URL: strings.TrimSpace(string(typed))}, nil
+	case *starlark.Dict:
+		record := Record{}
+		for key, field := range map[string]*string{"url": &record.URL, "title": &record.Title} {
+			value, found, err := typed.Get(starlark.String(key))
+			if err != nil {
+				return record, err
+			}
+			if !found || value == starlark.None {
+				continue
+			}
+			text, ok := starlark.AsString(value)
+			if !ok {
+				return record, fmt.Errorf("%q must be a string, not %s", key, value.Type())
+			}
+			*field = strings.TrimSpace(text)
+		}
+		return record, nil
+	}
+	return Record{}, fmt.Errorf("a picture must be a string or a dict, not %s", item.Type())
+}
+
+// newScriptPage creates the page given to the script
+func newScriptPage(doc *Document) starlark.Value {
+	pageURL := ""
+	if doc.URL() != nil {
+		pageURL = doc.URL().String()
+	}
+	return starlarkstruct.FromStringDict(starlark.String("page"), starlark.StringDict{
+		"source": starlark.String(doc.Source()),
+		"url":    starlark.String(pageURL),
+		"select": starlark.NewBuiltin("select", func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			root, err := doc.Node()
+			if err != nil {
+				return nil, err
+			}
+			return scriptSelect(fn, root, args, kwargs)
+		}),
+		"xpath": starlark.NewBuiltin("xpath", func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var expression string
+			if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &expression); err != nil {
+				return nil, err
+			}
+			expr, err := xpath.Compile(expression)
+			if err != nil {
+				return nil, err
+			}
+			return scriptStrings(NewXPathMatcher(expr).FindAll(doc)), nil
+		}),
+		"resolve": starlark.NewBuiltin("resolve", func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var link string
+			if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &link); err != nil {
+				return nil, err
+			}
+			linkURL, err := url.Parse(strings.TrimSpace(link))
+			if err != nil {
+				return nil, err
+			}
+			base := doc.URL()
+			if href := BaseHref(doc.Source()); href != "" {
+				if baseURL, err := url.Parse(href); err == nil {
+					if base != nil {
+						baseURL = base.ResolveReference(baseURL)
+					}
+					base = baseURL
+				}
+			}
+			if base == nil {
+				return starlark.String(linkURL.String()), nil
+			}
+			return starlark.String(base.ResolveReference(linkURL).String()), nil
+		}),
+	})
+}
+
+// scriptSelect returns the elements matching a CSS selector under the node
+func scriptSelect(fn *starlark.Builtin, node *html.Node, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var selector string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &selector); err != nil {
+		return nil, err
+	}
+	sel, err := cascadia.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	nodes := cascadia.QueryAll(node, sel)
+	elements := make([]starlark.Value, len(nodes))
+	for i, found := range nodes {
+		elements[i] = newScriptElement(found)
+	}
+	return starlark.NewList(elements), nil
+}
+
+// newScriptElement creates an HTML element given to the script
+func newScriptElement(node *html.Node) starlark.Value {
+	return starlarkstruct.FromStringDict(starlark.String("element"), starlark.StringDict{
+		"tag":  starlark.String(node.Data),
+		"text": starlark.String(strings.TrimSpace(NodeText(node))),
+		"attr": starlark.NewBuiltin("attr", func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var name, fallback string
+			if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "name", &name, "default?", &fallback); err != nil {
+				return nil, err
+			}
+			for _, attribute := range node.Attr {
+				if strings.EqualFold(attribute.Key, name) {
+					return starlark.String(attribute.Val), nil
+				}
+			}
+			return starlark.String(fallback), nil
+		}),
+		"select": starlark.NewBuiltin("select", func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			return scriptSelect(fn, node, args, kwargs)
+		}),
+	})
+}
+
+// scriptFindAll returns all the matches of the pattern in the text: the first group when the pattern has one
+func scriptFindAll(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, text string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 2, &pattern, &text); err != nil {
+		return nil, err
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0)
+	for _, match := range compiled.FindAllStringSubmatch(text, -1) {
+		if len(match) > 1 {
+			values = append(values, match[1])
+			continue
+		}
+		values = append(values, match[0])
+	}
+	return scriptStrings(values), nil
+}
+
+// scriptSearch returns the first match of the pattern in the text (the first group when the pattern has one), or None
+func scriptSearch(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, text string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 2, &pattern, &text); err != nil {
+		return nil, err
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	match := compiled.FindStringSubmatch(text)
+	if match == nil {
+		return starlark.None, nil
+	}
+	if len(match) > 1 {
+		return starlark.String(match[1]), nil
+	}
+	return starlark.String(match[0]), nil
+}
+
+// scriptBase64Decode decodes a standard or URL-safe base64 string, with or without padding
+func scriptBase64Decode(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var encoded string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &encoded); err != nil {
+		return nil, err
+	}
+	encoded = strings.TrimRight(strings.TrimSpace(encoded), "=")
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(encoded, "-_") {
+		encoding = base64.RawURLEncoding
+	}
+	decoded, err := encoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.String(decoded), nil
+}
+
+func scriptStrings(values []string) *starlark.List {
+	list := make([]starlark.Value, len(values))
+	for i, value := range values {
+		list[i] = starlark.String(value)
+	}
+	return starlark.NewList(list)
+}
+
+// Verify interface
+var _ RecordMatcher = &ScriptMatcher{}
@@ -0,0 +1,157 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scriptProcessVariable is set in the environment of the process started to run a script
+const scriptProcessVariable = "GALLERY_DOWNLOADER_SCRIPT"
+
+// scriptStartTime is the time given to the process to start, on top of the timeout of the script
+const scriptStartTime = 5 * time.Second
+
+// memoryCheckInterval is how often the memory used by the script is checked
+const memoryCheckInterval = 5 * time.Millisecond
+
+// scriptRequest is sent to the process running a script
+type scriptRequest struct {
+	Name   string
+	Script []byte
+	Limits ScriptLimits
+	Source []byte
+	URL    string
+}
+
+// scriptResponse is sent back by the process running a script
+type scriptResponse struct {
+	Records []Record
+	Error   string
+}
+
+// ServeScript runs the script sent by the parent process, when the current process was started to do so, then exits.
+// It must be called at the start of main by the programs using script matchers, and does nothing otherwise
+func ServeScript() {
+	if os.Getenv(scriptProcessVariable) == "" {
+		return
+	}
+	output := json.NewEncoder(os.Stdout)
+	once := sync.Once{}
+	respond := func(response scriptResponse) {
+		once.Do(func() {
+			_ = output.Encode(response)
+		})
+	}
+
+	request := scriptRequest{}
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		respond(scriptResponse{Error: fmt.Sprintf("cannot read the script: %v", err)})
+		os.Exit(0)
+	}
+	var pageURL *url.URL
+	if request.URL != "" {
+		pageURL, _ = url.Parse(request.URL)
+	}
+	doc := NewPageDocument(request.Source, pageURL)
+	matcher, err := NewScriptMatcher(request.Name, request.Script, request.Limits)
+	if err != nil {
+		respond(scriptResponse{Error: err.Error()})
+		os.Exit(0)
+	}
+
+	// the process only runs this script: the memory above the one used by the page is the memory of the script
+	maxMemory := heapBytes() + matcher.limits.MaxMemory
+	debug.SetMemoryLimit(int64(maxMemory))
+	go func() {
+		for range time.Tick(memoryCheckInterval) {
+			if heapBytes() > maxMemory {
+				respond(scriptResponse{Error: fmt.Sprintf("memory limit of %d MB exceeded", matcher.limits.MaxMemory>>20)})
+				os.Exit(0)
+			}
+		}
+	}()
+
+	records, err := matcher.run(doc)
+	if err != nil {
+		respond(scriptResponse{Error: err.Error()})
+		os.Exit(0)
+	}
+	respond(scriptResponse{Records: records})
+	os.Exit(0)
+}
+
+// heapBytes returns the memory used by the objects of the heap
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// runProcess runs the script on the document in a new process of the current program (see ServeScript),
+// so that its memory is only the memory of the script
+func (m *ScriptMatcher) runProcess(doc *Document) ([]Record, error) {
+	if os.Getenv(scriptProcessVariable) != "" {
+		return nil, errors.New("a script cannot run another script")
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot start the script: %w", err)
+	}
+	request := scriptRequest{
+		Name:   m.name,
+		Script: m.source,
+		Limits: m.limits,
+		Source: doc.Source(),
+	}
+	if doc.URL() != nil {
+		request.URL = doc.URL().String()
+	}
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.limits.Timeout+scriptStartTime)
+	defer cancel()
+	command := exec.CommandContext(ctx, executable)
+	command.Env = append(os.Environ(), scriptProcessVariable+"=1")
+	command.Stdin = bytes.NewReader(input)
+	output := &bytes.Buffer{}
+	errorOutput := &bytes.Buffer{}
+	command.Stdout = output
+	command.Stderr = errorOutput
+	err = command.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("timeout after %s", m.limits.Timeout)
+	}
+
+	response := scriptResponse{}
+	if decodeErr := json.Unmarshal(output.Bytes(), &response); decodeErr != nil {
+		if err == nil {
+			err = decodeErr
+		}
+		if message := strings.TrimSpace(errorOutput.String()); message != "" {
+			// the first line of a crash, like "fatal error: runtime: out of memory"
+			err = fmt.Errorf("%w: %s", err, strings.SplitN(message, "\n", 2)[0])
+		}
+		return nil, fmt.Errorf("script process failed: %w", err)
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	return response.Records, nil
+}
@@ -0,0 +1,138 @@
+package scan
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scriptGallery = `<html><head><base href="/gallery/"></head><body>
+<div class="photo" data-id="MDAx"><span>First</span></div>
+<div class="photo" data-id="MDAy"><span>Second</span></div>
+<script>var images = {"list": [{"file": "003.jpg"}]};</script>
+</body></html>`
+
+// TestMain lets the test binary run the scripts of the tests in a process of their own
+func TestMain(m *testing.M) {
+	ServeScript()
+	os.Exit(m.Run())
+}
+
+func mustScriptMatcher(t *testing.T, script string, limits ScriptLimits) *ScriptMatcher {
+	t.Helper()
+	matcher, err := NewScriptMatcher("test.star", []byte(script), limits)
+	require.NoError(t, err)
+	return matcher
+}
+
+func TestScriptMatcherRecords(t *testing.T) {
+	pageURL, err := url.Parse("https://example.com/albums/1.html")
+	require.NoError(t, err)
+	doc := NewPageDocument([]byte(scriptGallery), pageURL)
+
+	matcher := mustScriptMatcher(t, `
+def find(page):
+    pictures = []
+    for photo in page.select("div.photo"):
+        name = base64.decode(photo.attr("data-id")) + ".jpg"
+        pictures.append({"url": page.resolve(name), "title": photo.select("span")[0].text})
+    data = json.decode(re.search(r"var images = (\{.*?\});", page.source))
+    for image in data["list"]:
+        pictures.append(page.resolve(image["file"]))
+    return pictures
+`, ScriptLimits{})
+
+	expected := []Record{
+		{URL: "https://example.com/gallery/001.jpg", Title: "First"},
+		{URL: "https://example.com/gallery/002.jpg", Title: "Second"},
+		{URL: "https://example.com/gallery/003.jpg"},
+	}
+	assert.Equal(t, expected, matcher.FindRecords(doc))
+	assert.Equal(t, "https://example.com/gallery/001.jpg", matcher.Find(doc))
+	assert.Len(t, matcher.FindAll(doc), 3)
+}
+
+func TestScriptMatcherHelpers(t *testing.T) {
+	doc := NewDocument(getTestData(t, "list_item"))
+
+	testData := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{"url", `def find(page): return [page.url]`, []string{}},
+		{"xpath", `def find(page): return page.xpath("//ul/li[last()]/img/@src")`, []string{"data1/images/picture010.jpg"}},
+		{"findall", `def find(page): return re.findall(r'src="(data1/images/picture00[12]\.jpg)"', page.source)`, []string{"data1/images/picture001.jpg", "data1/images/picture002.jpg"}},
+		{"attribute default", `def find(page): return [page.select("li img")[0].attr("data-src", "none")]`, []string{"none"}},
+		{"none", `def find(page): return None`, nil},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			matcher := mustScriptMatcher(t, testItem.script, ScriptLimits{})
+			records, err := matcher.Run(doc)
+			require.NoError(t, err)
+			links := make([]string, 0)
+			for _, record := range records {
+				links = append(links, record.URL)
+			}
+			if testItem.expected == nil {
+				assert.Nil(t, records)
+				return
+			}
+			assert.Equal(t, testItem.expected, links)
+		})
+	}
+}
+
+func TestScriptMatcherErrors(t *testing.T) {
+	doc := NewDocument([]byte(scriptGallery))
+
+	testData := []struct {
+		name     string
+		script   string
+		limits   ScriptLimits
+		expected string
+	}{
+		{"no find function", `pictures = []`, ScriptLimits{}, "must define a function find"},
+		{"invalid result", `def find(page): return 1`, ScriptLimits{}, "must return a list"},
+		{"invalid picture", `def find(page): return [1]`, ScriptLimits{}, "must be a string or a dict"},
+		{"load", `load("other.star", "x")` + "\ndef find(page): return []", ScriptLimits{}, "load is not allowed"},
+		{"steps", "def find(page):\n    while True:\n        pass", ScriptLimits{MaxSteps: 1000}, "too many steps"},
+		{"timeout", "def find(page):\n    while True:\n        pass", ScriptLimits{Timeout: 50 * time.Millisecond, MaxSteps: 1 << 62}, "timeout"},
+		{"memory", "def find(page):\n    text = \"x\"\n    while True:\n        text += text", ScriptLimits{MaxSteps: 1 << 62, MaxMemory: 16 << 20}, "memory limit of 16 MB exceeded"},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			matcher := mustScriptMatcher(t, testItem.script, testItem.limits)
+			_, err := matcher.Run(doc)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testItem.expected)
+			assert.Nil(t, matcher.FindRecords(doc))
+		})
+	}
+}
+
+func TestScriptMatcherSyntaxError(t *testing.T) {
+	_, err := NewScriptMatcher("test.star", []byte("def find(page)\n    return []"), ScriptLimits{})
+	assert.Error(t, err)
+}
+
+func TestGalleryRecords(t *testing.T) {
+	doc := NewDocument([]byte(scriptGallery))
+	matcher := mustScriptMatcher(t, `def find(page): return [{"url": "a.jpg", "title": "A"}, "b.png"]`, ScriptLimits{})
+
+	gallery := NewGallery(Config{DetectImage: matcher, Extensions: []string{"jpg"}}, doc)
+	assert.Equal(t, []Record{{URL: "a.jpg", Title: "A"}}, GalleryRecords(gallery))
+	assert.Equal(t, []string{"a.jpg"}, gallery.Find())
+
+	// matchers without title
+	gallery = NewGallery(Config{DetectImage: NewRegexpMatcher(regexp.MustCompile(`<base href="(.+?)"`))}, doc)
+	assert.Equal(t, []Record{{URL: "/gallery/"}}, GalleryRecords(gallery))
+}
@@ -0,0 +1,91 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"gallery-downloader/transcode"
+	"io"
+	"strings"
+)
+
+// Sitemap is an XML sitemap listing pages, or a sitemap index listing other sitemaps
+type Sitemap struct {
+	Sitemaps []string
+	Pages    []SitemapPage
+}
+
+// SitemapPage is a page of a sitemap, with the pictures of its <image:image> entries
+type SitemapPage struct {
+	URL      string
+	Pictures []Record
+}
+
+type sitemapXML struct {
+	XMLName  xml.Name
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+	URLs []struct {
+		Loc    string `xml:"loc"`
+		Images []struct {
+			Loc     string `xml:"loc"`
+			Title   string `xml:"title"`
+			Caption string `xml:"caption"`
+		} `xml:"image"`
+	} `xml:"url"`
+}
+
+// ParseSitemap reads a sitemap (<urlset>) or a sitemap index (<sitemapindex>).
+// The pictures are titled after their <image:title>, or their <image:caption>
+func ParseSitemap(source []byte) (*Sitemap, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(source))
+	// sitemaps are read as raw data: they're converted from the encoding of their XML declaration
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, _, err := transcode.Lookup(label)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported sitemap encoding: %w", err)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	document := sitemapXML{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid sitemap: %w", err)
+	}
+	switch document.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return nil, fmt.Errorf("not a sitemap: root element is <%s>", document.XMLName.Local)
+	}
+
+	sitemap := &Sitemap{
+		Sitemaps: make([]string, 0, len(document.Sitemaps)),
+		Pages:    make([]SitemapPage, 0, len(document.URLs)),
+	}
+	for _, entry := range document.Sitemaps {
+		if loc := strings.TrimSpace(entry.Loc); loc != "" {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+		}
+	}
+	for _, entry := range document.URLs {
+		page := SitemapPage{
+			URL:      strings.TrimSpace(entry.Loc),
+			Pictures: make([]Record, 0, len(entry.Images)),
+		}
+		for _, image := range entry.Images {
+			loc := strings.TrimSpace(image.Loc)
+			if loc == "" {
+				continue
+			}
+			title := strings.TrimSpace(image.Title)
+			if title == "" {
+				title = strings.TrimSpace(image.Caption)
+			}
+			page.Pictures = append(page.Pictures, Record{URL: loc, Title: title})
+		}
+		if page.URL != "" || len(page.Pictures) > 0 {
+			sitemap.Pages = append(sitemap.Pages, page)
+		}
+	}
+	return sitemap, nil
+}
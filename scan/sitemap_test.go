@@ -0,0 +1,93 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSitemap(t *testing.T) {
+	source := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+	<url>
+		<loc>https://example.com/galleries/summer/</loc>
+		<lastmod>2020-06-01</lastmod>
+		<image:image>
+			<image:loc> https://example.com/photos/beach.jpg </image:loc>
+			<image:title>Beach</image:title>
+			<image:caption>At the beach</image:caption>
+		</image:image>
+		<image:image>
+			<image:loc>https://example.com/photos/sea.jpg</image:loc>
+			<image:caption>The sea</image:caption>
+		</image:image>
+		<image:image>
+			<image:loc>https://example.com/photos/sand.jpg</image:loc>
+		</image:image>
+	</url>
+	<url>
+		<loc>https://example.com/about</loc>
+	</url>
+</urlset>`
+
+	sitemap, err := ParseSitemap([]byte(source))
+	require.NoError(t, err)
+	assert.Empty(t, sitemap.Sitemaps)
+	assert.Equal(t, []SitemapPage{
+		{URL: "https://example.com/galleries/summer/", Pictures: []Record{
+			{URL: "https://example.com/photos/beach.jpg", Title: "Beach"},
+			{URL: "https://example.com/photos/sea.jpg", Title: "The sea"},
+			{URL: "https://example.com/photos/sand.jpg"},
+		}},
+		{URL: "https://example.com/about", Pictures: []Record{}},
+	}, sitemap.Pages)
+}
+
+func TestParseSitemapIndex(t *testing.T) {
+	source := `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>https://example.com/sitemap-pages.xml</loc></sitemap>
+	<sitemap><loc>
+		https://example.com/sitemap-galleries.xml.gz
+	</loc></sitemap>
+</sitemapindex>`
+
+	sitemap, err := ParseSitemap([]byte(source))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/sitemap-pages.xml", "https://example.com/sitemap-galleries.xml.gz"}, sitemap.Sitemaps)
+	assert.Empty(t, sitemap.Pages)
+}
+
+func TestParseSitemapEncoding(t *testing.T) {
+	// "Été" in ISO-8859-1
+	source := append([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+<url><loc>https://example.com/summer</loc><image:image><image:loc>https://example.com/1.jpg</image:loc><image:title>`),
+		0xc9, 't', 0xe9)
+	source = append(source, []byte(`</image:title></image:image></url>
+</urlset>`)...)
+
+	sitemap, err := ParseSitemap(source)
+	require.NoError(t, err)
+	require.Len(t, sitemap.Pages, 1)
+	assert.Equal(t, []Record{{URL: "https://example.com/1.jpg", Title: "Été"}}, sitemap.Pages[0].Pictures)
+}
+
+func TestParseSitemapErrors(t *testing.T) {
+	testData := []struct {
+		name   string
+		source string
+	}{
+		{"html", "<html><body></body></html>"},
+		{"feed", `<rss version="2.0"><channel></channel></rss>`},
+		{"not xml", "User-agent: *"},
+		{"encoding", `<?xml version="1.0" encoding="x-unknown"?><urlset></urlset>`},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			_, err := ParseSitemap([]byte(testItem.source))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -2,7 +2,7 @@ package scan
 
 import (
 	"io"
-	"strings"
+	"regexp"
 
 	"golang.org/x/net/html"
 )
@@ -19,25 +19,47 @@ const (
 )
 
 var (
-	// AvailableGalleryScanners lists the available gallery scanners
-	AvailableGalleryScanners = [...]string{AutoDetect, ConfigProfiles, AnchorHREF, ListItem}
+	// generatorPattern finds the name of the generator in the comments of the page
+	generatorPattern = regexp.MustCompile(`<!--\s*Generated by\s*(.*?)\s*-->`)
+	// AvailableGalleryScanners lists the available gallery scanners, in the order AutoDetect tries them
+	AvailableGalleryScanners = []string{AutoDetect, ConfigProfiles}
 	// GalleryScanners maps the gallery scanner constructors
 	GalleryScanners map[string][]GalFactory
 )
 
 func init() {
-	GalleryScanners = map[string][]GalFactory{
-		AnchorHREF: {NewLegacyAnchorGallery},
-		ListItem:   {NewLegacyListItemGallery},
+	GalleryScanners = make(map[string][]GalFactory)
+	RegisterGalleryScanner(AnchorHREF, NewLegacyAnchorGallery)
+	RegisterGalleryScanner(ListItem, NewLegacyListItemGallery)
+	RegisterGalleryScanner(Feed, NewFeedGallery)
+	RegisterGalleryScanner(IIIF, NewIIIFGallery)
+}
+
+// RegisterGalleryScanner adds constructors to a type of gallery, creating the type if needed.
+// It is not safe for concurrent use: third-party scanners should be registered from an init function
+func RegisterGalleryScanner(name string, factories ...GalFactory) {
+	if _, found := GalleryScanners[name]; !found {
+		AvailableGalleryScanners = append(AvailableGalleryScanners, name)
+	}
+	GalleryScanners[name] = append(GalleryScanners[name], factories...)
+}
+
+// BuiltinGalleryScanners returns the types of gallery implemented in Go code (not from configuration profiles), in registration order
+func BuiltinGalleryScanners() []string {
+	names := make([]string, 0, len(AvailableGalleryScanners))
+	for _, name := range AvailableGalleryScanners {
+		if _, found := GalleryScanners[name]; found {
+			names = append(names, name)
+		}
 	}
+	return names
 }
 
-func getPictureAttribute(n *html.Node, element, attribute string) (string, bool) {
+func getPictureAttribute(n *html.Node, element, attribute string, filter *ExtensionFilter) (string, bool) {
 	if n.Type == html.ElementNode && n.Data == element {
 		for _, a := range n.Attr {
 			if a.Key == attribute {
-				value := strings.ToLower(a.Val)
-				if strings.HasSuffix(value, "jpg") || strings.HasSuffix(value, "jpeg") {
+				if filter.Accept(a.Val) {
 					return a.Val, true
 				}
 				break
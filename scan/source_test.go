@@ -55,7 +55,7 @@ func getTestData(t *testing.T, name string) []byte {
 
 func TestLoadGalleryAnchorHREF(t *testing.T) {
 	galleryAnchorHREF := getTestData(t, "anchor_href")
-	gallery, err := NewLegacyAnchorGallery(galleryAnchorHREF)
+	gallery, err := NewLegacyAnchorGallery(Config{}, NewDocument(galleryAnchorHREF))
 	require.NoError(t, err)
 
 	pictures := gallery.Find()
@@ -67,7 +67,7 @@ func TestLoadGalleryAnchorHREF(t *testing.T) {
 
 func TestEmptyGalleryAnchorHREF(t *testing.T) {
 	galleryListItem := getTestData(t, "list_item")
-	gallery, err := NewLegacyAnchorGallery(galleryListItem)
+	gallery, err := NewLegacyAnchorGallery(Config{}, NewDocument(galleryListItem))
 	require.NoError(t, err)
 
 	pictures := gallery.Find()
@@ -78,7 +78,7 @@ func TestEmptyGalleryAnchorHREF(t *testing.T) {
 
 func TestLoadGalleryListItem(t *testing.T) {
 	galleryListItem := getTestData(t, "list_item")
-	gallery, err := NewLegacyListItemGallery(galleryListItem)
+	gallery, err := NewLegacyListItemGallery(Config{}, NewDocument(galleryListItem))
 	require.NoError(t, err)
 
 	pictures := gallery.Find()
@@ -87,3 +87,38 @@ func TestLoadGalleryListItem(t *testing.T) {
 	}
 	assert.ElementsMatch(t, expectedListItem, pictures)
 }
+
+func TestLoadGalleryAnchorHREFWithExtensions(t *testing.T) {
+	galleryAnchorHREF := getTestData(t, "anchor_href")
+	gallery, err := NewLegacyAnchorGallery(Config{Extensions: []string{"jpg", "png"}}, NewDocument(galleryAnchorHREF))
+	require.NoError(t, err)
+
+	pictures := gallery.Find()
+	assert.ElementsMatch(t, append(expectedAnchorHREF, "other.png"), pictures)
+}
+
+func TestLoadGalleryListItemWithExtensions(t *testing.T) {
+	galleryListItem := getTestData(t, "list_item")
+	gallery, err := NewLegacyListItemGallery(Config{Extensions: []string{"png"}}, NewDocument(galleryListItem))
+	require.NoError(t, err)
+
+	pictures := gallery.Find()
+	assert.Empty(t, pictures)
+}
+
+func TestRegisterGalleryScanner(t *testing.T) {
+	const name = "TestScanner"
+	available := AvailableGalleryScanners
+	defer func() {
+		AvailableGalleryScanners = available
+		delete(GalleryScanners, name)
+	}()
+
+	assert.Equal(t, []string{AnchorHREF, ListItem, Feed, IIIF}, BuiltinGalleryScanners())
+
+	RegisterGalleryScanner(name, NewLegacyAnchorGallery)
+	RegisterGalleryScanner(name, NewLegacyListItemGallery)
+	assert.Equal(t, []string{AutoDetect, ConfigProfiles, AnchorHREF, ListItem, Feed, IIIF, name}, AvailableGalleryScanners)
+	assert.Equal(t, []string{AnchorHREF, ListItem, Feed, IIIF, name}, BuiltinGalleryScanners())
+	assert.Len(t, GalleryScanners[name], 2)
+}
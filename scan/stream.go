@@ -0,0 +1,164 @@
+package scan
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNotStreamable is returned for the selectors needing the whole document, like sibling combinators or pseudo-classes
+var ErrNotStreamable = errors.New("selector cannot be used on a stream")
+
+// voidElements never have any content, so they never become the parent of the next elements
+var voidElements = map[atom.Atom]bool{
+	atom.Area: true, atom.Base: true, atom.Br: true, atom.Col: true, atom.Embed: true, atom.Hr: true, atom.Img: true,
+	atom.Input: true, atom.Link: true, atom.Meta: true, atom.Param: true, atom.Source: true, atom.Track: true, atom.Wbr: true,
+}
+
+// implicitlyClosed elements are closed by the start of another element of the same type, like <li>one<li>two
+var implicitlyClosed = map[atom.Atom]bool{
+	atom.Li: true, atom.P: true, atom.Option: true, atom.Dt: true, atom.Dd: true, atom.Tr: true, atom.Td: true, atom.Th: true,
+}
+
+// StreamMatcher finds pictures while reading the page, without keeping the page nor its HTML tree in memory.
+// The elements are matched as soon as their start tag is read: only their ancestors are known at that time,
+// so the selector can only use tags, classes, ids, attributes, and descendant or child combinators.
+// Unlike html.Parse, the tokenizer doesn't create the missing <html> or <body> elements
+type StreamMatcher struct {
+	sel        cascadia.Sel
+	attributes []string
+	filter     *ExtensionFilter
+}
+
+// NewStreamMatcher creates a matcher returning the value of the first usable attribute of the elements matching the selector
+func NewStreamMatcher(selector string, attributes ...string) (*StreamMatcher, error) {
+	if !isStreamable(selector) {
+		return nil, ErrNotStreamable
+	}
+	sel, err := cascadia.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamMatcher{
+		sel:        sel,
+		attributes: attributes,
+	}, nil
+}
+
+// NewBuiltinStreamMatcher creates a matcher finding the same pictures as a built-in gallery scanner
+func NewBuiltinStreamMatcher(name string, cfg Config) (*StreamMatcher, error) {
+	var matcher *StreamMatcher
+	var err error
+	switch name {
+	case AnchorHREF:
+		matcher, err = NewStreamMatcher("a", "href")
+	case ListItem:
+		matcher, err = NewStreamMatcher("li > img", "src")
+	default:
+		return nil, ErrNotStreamable
+	}
+	if err != nil {
+		return nil, err
+	}
+	matcher.filter = extensionFilter(cfg)
+	return matcher, nil
+}
+
+// SetExtensions only keeps the pictures with one of the extensions. All the pictures are kept when empty
+func (m *StreamMatcher) SetExtensions(extensions []string) {
+	m.filter = nil
+	if len(extensions) > 0 {
+		m.filter = NewExtensionFilter(extensions)
+	}
+}
+
+// Stream reads the page and calls found for each picture, in order.
+// Relative links are resolved against the <base href> of the page when present
+func (m *StreamMatcher) Stream(reader io.Reader, found func(link string)) error {
+	tokenizer := html.NewTokenizer(reader)
+	root := &html.Node{Type: html.DocumentNode}
+	parent := root
+	var base *url.URL
+	inBody := false
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return err
+			}
+			return nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if implicitlyClosed[token.DataAtom] && parent.DataAtom == token.DataAtom {
+				parent = parent.Parent
+			}
+			node := &html.Node{
+				Type:     html.ElementNode,
+				Data:     token.Data,
+				DataAtom: token.DataAtom,
+				Attr:     token.Attr,
+				Parent:   parent,
+			}
+			switch token.DataAtom {
+			case atom.Body:
+				inBody = true
+			case atom.Base:
+				if base == nil && !inBody {
+					base, _ = url.Parse(strings.TrimSpace(getAttribute(node, "href")))
+				}
+			}
+			if m.sel.Match(node) {
+				if link := m.link(node, base); link != "" {
+					found(link)
+				}
+			}
+			if tokenType == html.StartTagToken && !voidElements[token.DataAtom] {
+				parent = node
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			// also closes the elements left open inside this one
+			for n := parent; n != root; n = n.Parent {
+				if n.Data == string(name) {
+					parent = n.Parent
+					break
+				}
+			}
+		}
+	}
+}
+
+// link returns the picture of the node, resolved against the base of the page
+func (m *StreamMatcher) link(node *html.Node, base *url.URL) string {
+	link := getImageAttribute(node, m.attributes)
+	if link == "" || (m.filter != nil && !m.filter.Accept(link)) {
+		return ""
+	}
+	if base == nil {
+		return link
+	}
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(linkURL).String()
+}
+
+// isStreamable returns false for the selectors using siblings or pseudo-classes
+func isStreamable(selector string) bool {
+	for _, char := range []byte{':', '+', '~', ','} {
+		if indexOutside(selector, char) >= 0 {
+			return false
+		}
+	}
+	return strings.TrimSpace(selector) != ""
+}
@@ -0,0 +1,107 @@
+package scan
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func streamAll(t *testing.T, matcher *StreamMatcher, source []byte) []string {
+	links := make([]string, 0)
+	// one byte at a time, like a slow network
+	err := matcher.Stream(iotest.OneByteReader(bytes.NewReader(source)), func(link string) {
+		links = append(links, link)
+	})
+	require.NoError(t, err)
+	return links
+}
+
+func TestStreamMatcherBuiltin(t *testing.T) {
+	testData := []struct {
+		name     string
+		source   string
+		expected []string
+	}{
+		{AnchorHREF, "anchor_href", expectedAnchorHREF},
+		{ListItem, "list_item", expectedListItem},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			matcher, err := NewBuiltinStreamMatcher(testItem.name, Config{})
+			require.NoError(t, err)
+			assert.Equal(t, testItem.expected, streamAll(t, matcher, getTestData(t, testItem.source)))
+		})
+	}
+}
+
+func TestStreamMatcherLazyAttributes(t *testing.T) {
+	matcher, err := NewStreamMatcher("img.lazy", "data-src", "data-original", "data-lazy-src", "data-srcset", "src")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"pictures/001.jpg",
+		"pictures/002.jpg",
+		"pictures/003.jpg",
+		"pictures/004-1280.jpg",
+		"pictures/005.jpg",
+		"pictures/lazy-river.png",
+	}, streamAll(t, matcher, []byte(lazyGallery)))
+}
+
+func TestStreamMatcherAncestors(t *testing.T) {
+	source := `<html><head><base href="https://cdn.example.com/gallery/"></head><body>
+<ul class="gallery">
+	<li><img src="001.jpg">
+	<li><p><img src="/002.jpg"></p>
+	<li><img src="003.png">
+</ul>
+<ul class="other"><li><img src="other.jpg"></li></ul>
+<div class="gallery"><img src="not-in-a-list.jpg"><br/><img src="004.jpg"></div>
+</body></html>`
+
+	matcher, err := NewStreamMatcher("ul.gallery > li > img", "src")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://cdn.example.com/gallery/001.jpg",
+		"https://cdn.example.com/gallery/003.png",
+	}, streamAll(t, matcher, []byte(source)))
+
+	matcher, err = NewStreamMatcher(".gallery img", "src")
+	require.NoError(t, err)
+	matcher.SetExtensions([]string{"jpg"})
+	assert.Equal(t, []string{
+		"https://cdn.example.com/gallery/001.jpg",
+		"https://cdn.example.com/002.jpg",
+		"https://cdn.example.com/gallery/not-in-a-list.jpg",
+		"https://cdn.example.com/gallery/004.jpg",
+	}, streamAll(t, matcher, []byte(source)))
+}
+
+func TestStreamMatcherNotStreamable(t *testing.T) {
+	for _, selector := range []string{"", "li:first-child img", "h1 + img", "h1 ~ img", "a, img", "div:has(img)"} {
+		t.Run(selector, func(t *testing.T) {
+			_, err := NewStreamMatcher(selector, "src")
+			assert.ErrorIs(t, err, ErrNotStreamable)
+		})
+	}
+
+	matcher, err := NewStreamMatcher(`a[href^="https:"]`, "href")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/1.jpg"}, streamAll(t, matcher, []byte(`<a href="https://example.com/1.jpg"></a><a href="2.jpg"></a>`)))
+
+	_, err = NewBuiltinStreamMatcher("unknown", Config{})
+	assert.ErrorIs(t, err, ErrNotStreamable)
+}
+
+func TestStreamMatcherReaderError(t *testing.T) {
+	matcher, err := NewStreamMatcher("img", "src")
+	require.NoError(t, err)
+
+	err = matcher.Stream(iotest.TimeoutReader(strings.NewReader(`<img src="1.jpg"><img src="2.jpg">`)), func(string) {})
+	assert.ErrorIs(t, err, iotest.ErrTimeout)
+}
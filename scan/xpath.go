@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// XPathMatcher finds values with an XPath expression:
+//   - attribute nodes (//img/@src) give their value
+//   - element nodes give the value of the first usable attribute when attributes are configured, or their text otherwise.
+//     Like SelectorMatcher, Find returns the HTML of the element instead of its text, so an empty element is still found
+//   - text nodes (//h1/text()) give their text
+//   - other expressions (string(//title), count(//img)) give their value
+type XPathMatcher struct {
+	expr       *xpath.Expr
+	attributes []string
+	// evaluating an expression is not safe for concurrent use
+	lock sync.Mutex
+}
+
+// NewXPathMatcher creates a matcher returning the values selected by the expression
+func NewXPathMatcher(expr *xpath.Expr, attributes ...string) *XPathMatcher {
+	if expr == nil {
+		// might as well panic right now, no need to go much further
+		panic("invalid nil xpath expression")
+	}
+	return &XPathMatcher{
+		expr:       expr,
+		attributes: attributes,
+	}
+}
+
+func (m *XPathMatcher) Find(doc *Document) string {
+	values := m.values(doc, 1, true)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (m *XPathMatcher) FindAll(doc *Document) []string {
+	return m.values(doc, 0, false)
+}
+
+// values returns the non-empty values selected by the expression, up to limit values (0 for no limit)
+func (m *XPathMatcher) values(doc *Document, limit int, render bool) []string {
+	root, err := doc.Node()
+	if err != nil {
+		return nil
+	}
+	m.lock.Lock()
+	result := m.expr.Evaluate(htmlquery.CreateXPathNavigator(root))
+	m.lock.Unlock()
+
+	value := ""
+	switch typed := result.(type) {
+	case *xpath.NodeIterator:
+		values := make([]string, 0)
+		for typed.MoveNext() {
+			navigator, ok := typed.Current().(*htmlquery.NodeNavigator)
+			if !ok {
+				continue
+			}
+			if value := m.nodeValue(navigator, render); value != "" {
+				values = append(values, value)
+				if len(values) == limit {
+					break
+				}
+			}
+		}
+		return values
+	case string:
+		value = strings.TrimSpace(typed)
+	case float64:
+		value = strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		value = strconv.FormatBool(typed)
+	}
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+func (m *XPathMatcher) nodeValue(navigator *htmlquery.NodeNavigator, render bool) string {
+	switch navigator.NodeType() {
+	case xpath.AttributeNode:
+		value := strings.TrimSpace(navigator.Value())
+		if isSrcset(navigator.LocalName()) {
+			value = largestSrcsetCandidate(value)
+		}
+		if isPlaceholder(value) {
+			return ""
+		}
+		return value
+	case xpath.ElementNode:
+		if len(m.attributes) > 0 {
+			return getImageAttribute(navigator.Current(), m.attributes)
+		}
+		if render {
+			return htmlquery.OutputHTML(navigator.Current(), true)
+		}
+		return strings.TrimSpace(htmlquery.InnerText(navigator.Current()))
+	default:
+		return strings.TrimSpace(navigator.Value())
+	}
+}
+
+// Verify interface
+var _ Matcher = &XPathMatcher{}
@@ -0,0 +1,73 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/antchfx/xpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXPathMatcherFindAll(t *testing.T) {
+	testData := []struct {
+		name       string
+		source     string
+		expr       string
+		attributes []string
+		expected   []string
+	}{
+		{"attribute", "list_item", "//li/img/@src", nil, expectedListItem},
+		{"element attribute", "list_item", "//li/img", []string{"data-src", "src"}, expectedListItem},
+		{"predicate", "anchor_href", "//a[starts-with(@href, 'data/images/')]/@href", nil, expectedAnchorHREF},
+		{"text content", "anchor_href", "//a[text()='3']/@href", nil, []string{"data/images/picture_1280_003.jpg"}},
+		{"position", "list_item", "//ul/li[last()]/img/@src", nil, []string{"data1/images/picture010.jpg"}},
+		{"ancestor", "list_item", "//img[@id='wows1_2']/ancestor::div[@id][1]/@id", nil, []string{"wowslider-container1"}},
+		{"text node", "list_item", "//title/text()", nil, []string{"Gallery"}},
+		{"element text", "list_item", "//title", nil, []string{"Gallery"}},
+		{"count", "list_item", "count(//li/img)", nil, []string{"10"}},
+		{"string", "anchor_href", "string(//a[1]/@title)", nil, []string{"Picture_1280_001"}},
+		{"not found", "anchor_href", "//li/img/@data-src", nil, []string{}},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			expr, err := xpath.Compile(testItem.expr)
+			require.NoError(t, err)
+			var matcher Matcher = NewXPathMatcher(expr, testItem.attributes...)
+			assert.Equal(t, testItem.expected, matcher.FindAll(NewDocument(getTestData(t, testItem.source))))
+		})
+	}
+}
+
+func TestXPathMatcherFind(t *testing.T) {
+	doc := NewDocument(getTestData(t, "list_item"))
+
+	var matcher Matcher = NewXPathMatcher(xpath.MustCompile("//li/img/@src"))
+	assert.Equal(t, "data1/images/picture001.jpg", matcher.Find(doc))
+
+	// an element without text is still found
+	matcher = NewXPathMatcher(xpath.MustCompile("//li[1]/img"))
+	assert.Equal(t, `<img src="data1/images/picture001.jpg" alt="Picture-001" title="Picture-001" id="wows1_0"/>`, matcher.Find(doc))
+
+	matcher = NewXPathMatcher(xpath.MustCompile("//div[@id='gallery']"))
+	assert.Equal(t, "", matcher.Find(doc))
+	assert.Empty(t, matcher.FindAll(doc))
+}
+
+func TestXPathMatcherLazyAttributes(t *testing.T) {
+	doc := NewDocument([]byte(lazyGallery))
+
+	matcher := NewXPathMatcher(xpath.MustCompile("//img[contains(@class, 'lazy')]"), "data-src", "data-original", "data-lazy-src", "data-srcset", "src")
+	assert.Equal(t, []string{
+		"pictures/001.jpg",
+		"pictures/002.jpg",
+		"pictures/003.jpg",
+		"pictures/004-1280.jpg",
+		"pictures/005.jpg",
+		"pictures/lazy-river.png",
+	}, matcher.FindAll(doc))
+
+	// placeholders are skipped from attribute nodes too
+	matcher = NewXPathMatcher(xpath.MustCompile("//img/@src"))
+	assert.Equal(t, []string{"pictures/003-thumb.jpg", "pictures/005.jpg", "pictures/lazy-river.png"}, matcher.FindAll(doc))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// bonus added to the number of images found by a profile
+const (
+	scoreGenerator = 10
+	scoreGallery   = 20
+	scoreURL       = 50
+)
+
+// profileScore is the result of a profile on the current page
+type profileScore struct {
+	index     int
+	profile   config.Profile
+	images    []scan.Record
+	generator string
+	gallery   bool
+	url       bool
+	score     int
+	// reason is set when the profile cannot be chosen
+	reason string
+}
+
+// detectFromScores evaluates all the profiles and returns the pictures found by the best one.
+// The score is the number of images found, plus a bonus when the generator is detected,
+// when the gallery detection matches and when the URL of the page matches the profile hosts and paths
+func detectFromScores(profiles []config.Profile, pageURL *url.URL, doc *scan.Document, explain bool) ([]scan.Record, config.Profile, error) {
+	scores := make([]profileScore, 0, len(profiles))
+	for _, index := range profileOrder(profiles, pageURL) {
+		profile := profiles[index]
+		if profile.DetectImage.Type == "" && profile.Albums.IsSet() {
+			// this profile only detects an index of albums
+			continue
+		}
+		scores = append(scores, scoreProfile(index, profile, pageURL, doc))
+	}
+
+	// highest score first, then by priority. The sort is stable, so the ties keep the order of profileOrder:
+	// the profiles matching the URL of the page first, then in configuration order
+	sort.SliceStable(scores, func(i, j int) bool {
+		if (scores[i].reason == "") != (scores[j].reason == "") {
+			return scores[i].reason == ""
+		}
+		if scores[i].score != scores[j].score {
+			return scores[i].score > scores[j].score
+		}
+		return scores[i].profile.Priority < scores[j].profile.Priority
+	})
+
+	if explain {
+		explainScores(scores)
+	}
+
+	if len(scores) == 0 || scores[0].reason != "" {
+		return nil, config.Profile{}, nil
+	}
+	best := scores[0]
+	log.Printf("Found %d images using profile %s (#%d) with a score of %d", len(best.images), best.profile.Name, best.index+1, best.score)
+	if best.generator != "" {
+		log.Printf("Gallery generated by %s", best.generator)
+	}
+	return best.images, best.profile, nil
+}
+
+func scoreProfile(index int, profile config.Profile, pageURL *url.URL, doc *scan.Document) profileScore {
+	result := profileScore{
+		index:   index,
+		profile: profile,
+	}
+	scanner, err := newGallery(profile, doc)
+	if err != nil {
+		result.reason = err.Error()
+		return result
+	}
+	// the bonus is only given when the detection finds the gallery in the document
+	result.gallery = scanner.Detected()
+	if scanner.HasDetection() && !result.gallery {
+		result.reason = "gallery not detected"
+		return result
+	}
+	// the media don't make a gallery: only the images count toward the minimum
+	images := scanner.ImageRecords()
+	result.images = scanner.AppendMedia(images)
+	result.generator = scanner.GeneratedBy()
+	result.url = profile.MatchURL(pageURL)
+
+	if len(images) == 0 || len(images) < profile.MinImages {
+		result.reason = fmt.Sprintf("not enough images (minimum %d)", profile.MinImages)
+		return result
+	}
+	result.score = len(result.images)
+	if result.generator != "" {
+		result.score += scoreGenerator
+	}
+	if result.gallery {
+		result.score += scoreGallery
+	}
+	if result.url {
+		result.score += scoreURL
+	}
+	return result
+}
+
+// explainScores displays a table with the score of each profile, the chosen one first
+func explainScores(scores []profileScore) {
+	writer := tabwriter.NewWriter(log.Writer(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "\tPROFILE\tPRIORITY\tIMAGES\tGENERATOR\tGALLERY\tURL\tSCORE")
+	for i, score := range scores {
+		selected := ""
+		if i == 0 && score.reason == "" {
+			selected = "*"
+		}
+		result := strconv.Itoa(score.score)
+		if score.reason != "" {
+			result = "- " + score.reason
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+			selected,
+			score.profile.Name,
+			score.profile.Priority,
+			len(score.images),
+			yesNo(score.generator != ""),
+			yesNo(score.gallery),
+			yesNo(score.url),
+			result,
+		)
+	}
+	writer.Flush()
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
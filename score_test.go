@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scoredGallery = `<html><body>
+<!-- Generated by PhotoBook -->
+<div class="gallery">
+	<img src="photos/001.jpg">
+	<img src="photos/002.jpg">
+	<img src="photos/003.jpg">
+</div>
+<div class="thumbnails"><a href="big/001.jpg"></a></div>
+<video src="videos/clip.mp4"></video>
+</body></html>`
+
+func regexpParser(match string) config.Parser {
+	return config.Parser{Type: "regexp", Match: match}
+}
+
+// scoredProfile finds the 3 photos of the scored gallery
+func scoredProfile(name string) config.Profile {
+	return config.Profile{
+		Name:        name,
+		DetectImage: regexpParser(`src="(photos/[^"]+)"`),
+	}
+}
+
+func TestScoreProfile(t *testing.T) {
+	pageURL := mustParseURL(t, "https://photos.example.com/albums/summer/")
+	doc := scan.NewDocument([]byte(scoredGallery))
+
+	testData := []struct {
+		name      string
+		profile   func(profile *config.Profile)
+		images    int
+		generator string
+		gallery   bool
+		url       bool
+		score     int
+		reason    string
+	}{
+		{"images only", func(profile *config.Profile) {}, 3, "", false, false, 3, ""},
+		{"generator", func(profile *config.Profile) {
+			profile.DetectGenerator = regexpParser(`Generated by (\w+)`)
+		}, 3, "PhotoBook", false, false, 3 + scoreGenerator, ""},
+		{"generator not found", func(profile *config.Profile) {
+			profile.DetectGenerator = regexpParser(`Powered by (\w+)`)
+		}, 3, "", false, false, 3, ""},
+		{"gallery", func(profile *config.Profile) {
+			profile.DetectGallery = regexpParser(`class="(gallery)"`)
+		}, 3, "", true, false, 3 + scoreGallery, ""},
+		{"host", func(profile *config.Profile) {
+			profile.Hosts = []string{".example.com"}
+		}, 3, "", false, true, 3 + scoreURL, ""},
+		{"other host", func(profile *config.Profile) {
+			profile.Hosts = []string{"example.org"}
+		}, 3, "", false, false, 3, ""},
+		{"path", func(profile *config.Profile) {
+			profile.Paths = []string{"/albums/**"}
+		}, 3, "", false, true, 3 + scoreURL, ""},
+		{"all bonuses", func(profile *config.Profile) {
+			profile.DetectGenerator = regexpParser(`Generated by (\w+)`)
+			profile.DetectGallery = regexpParser(`class="(gallery)"`)
+			profile.Hosts = []string{"photos.example.com"}
+		}, 3, "PhotoBook", true, true, 3 + scoreGenerator + scoreGallery + scoreURL, ""},
+		{"gallery not detected", func(profile *config.Profile) {
+			profile.DetectGallery = regexpParser(`class="(slideshow)"`)
+		}, 0, "", false, false, 0, "gallery not detected"},
+		{"not enough images", func(profile *config.Profile) {
+			profile.MinImages = 4
+			profile.Hosts = []string{".example.com"}
+		}, 3, "", false, true, 0, "not enough images (minimum 4)"},
+		{"no image", func(profile *config.Profile) {
+			profile.DetectImage = regexpParser(`src="(missing/[^"]+)"`)
+		}, 0, "", false, false, 0, "not enough images (minimum 0)"},
+		{"media", func(profile *config.Profile) {
+			profile.Media = []string{"video"}
+		}, 4, "", false, false, 4, ""},
+		{"media are not images", func(profile *config.Profile) {
+			profile.Media = []string{"video"}
+			profile.MinImages = 4
+		}, 4, "", false, false, 0, "not enough images (minimum 4)"},
+		{"media only", func(profile *config.Profile) {
+			profile.DetectImage = regexpParser(`src="(missing/[^"]+)"`)
+			profile.Media = []string{"video"}
+		}, 1, "", false, false, 0, "not enough images (minimum 0)"},
+		{"invalid", func(profile *config.Profile) {
+			profile.DetectImage = regexpParser(`src="(`)
+		}, 0, "", false, false, 0, "profile test: cannot compile image detection"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			profile := scoredProfile("test")
+			testItem.profile(&profile)
+
+			result := scoreProfile(2, profile, pageURL, doc)
+			assert.Equal(t, 2, result.index)
+			assert.Equal(t, "test", result.profile.Name)
+			assert.Len(t, result.images, testItem.images)
+			assert.Equal(t, testItem.generator, result.generator)
+			assert.Equal(t, testItem.gallery, result.gallery)
+			assert.Equal(t, testItem.url, result.url)
+			assert.Equal(t, testItem.score, result.score)
+			if testItem.reason == "" {
+				assert.Empty(t, result.reason)
+			} else {
+				assert.True(t, strings.HasPrefix(result.reason, testItem.reason), result.reason)
+			}
+		})
+	}
+}
+
+func TestDetectFromScores(t *testing.T) {
+	pageURL := mustParseURL(t, "https://photos.example.com/albums/summer/")
+	doc := scan.NewDocument([]byte(scoredGallery))
+
+	// thumbnails finds a single picture
+	thumbnails := func(name string, priority int) config.Profile {
+		return config.Profile{
+			Priority:    priority,
+			Name:        name,
+			DetectImage: regexpParser(`href="(big/[^"]+)"`),
+		}
+	}
+	photos := func(name string, priority int) config.Profile {
+		profile := scoredProfile(name)
+		profile.Priority = priority
+		return profile
+	}
+	withGallery := func(profile config.Profile) config.Profile {
+		profile.DetectGallery = regexpParser(`class="(thumbnails)"`)
+		return profile
+	}
+	withHost := func(profile config.Profile) config.Profile {
+		profile.Hosts = []string{".example.com"}
+		return profile
+	}
+	failing := func(profile config.Profile) config.Profile {
+		profile.MinImages = 10
+		return profile
+	}
+
+	testData := []struct {
+		name     string
+		profiles []config.Profile
+		expected string
+		images   int
+	}{
+		{"no profile", nil, "", 0},
+		{"most images", []config.Profile{thumbnails("thumbnails", 10), photos("photos", 20)}, "photos", 3},
+		{"gallery bonus", []config.Profile{photos("photos", 10), withGallery(thumbnails("thumbnails", 20))}, "thumbnails", 1},
+		{"url bonus", []config.Profile{withGallery(photos("photos", 10)), withHost(thumbnails("thumbnails", 20))}, "thumbnails", 1},
+		{"same score by priority", []config.Profile{photos("low", 20), photos("high", 10)}, "high", 3},
+		{"same score and priority in order", []config.Profile{photos("first", 10), photos("second", 10)}, "first", 3},
+		{"failing profile never chosen", []config.Profile{failing(withHost(photos("failing", 10))), thumbnails("thumbnails", 20)}, "thumbnails", 1},
+		{"all failing", []config.Profile{failing(photos("failing", 10))}, "", 0},
+		{"albums only skipped", []config.Profile{
+			{Name: "albums", Albums: config.Albums{Link: regexpParser(`href="([^"]+)"`)}},
+			thumbnails("thumbnails", 20),
+		}, "thumbnails", 1},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			images, profile, err := detectFromScores(testItem.profiles, pageURL, doc, false)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.expected, profile.Name)
+			assert.Len(t, images, testItem.images)
+		})
+	}
+}
+
+func TestExplainScores(t *testing.T) {
+	output := &bytes.Buffer{}
+	log.SetOutput(output)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+
+	explainScores([]profileScore{
+		{profile: config.Profile{Name: "best", Priority: 10}, images: make([]scan.Record, 3), generator: "PhotoBook", gallery: true, url: true, score: 83},
+		{profile: config.Profile{Name: "second", Priority: 20}, images: make([]scan.Record, 3), score: 3},
+		{profile: config.Profile{Name: "failing", Priority: 30}, reason: "gallery not detected"},
+	})
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, []string{"PROFILE", "PRIORITY", "IMAGES", "GENERATOR", "GALLERY", "URL", "SCORE"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"*", "best", "10", "3", "yes", "yes", "yes", "83"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"second", "20", "3", "no", "no", "no", "3"}, strings.Fields(lines[2]))
+	assert.Equal(t, []string{"failing", "30", "0", "no", "no", "no", "-", "gallery", "not", "detected"}, strings.Fields(lines[3]))
+
+	// the first profile is not marked when it cannot be chosen
+	output.Reset()
+	explainScores([]profileScore{{profile: config.Profile{Name: "failing"}, reason: "gallery not detected"}})
+	assert.False(t, strings.Contains(output.String(), "*"))
+}
@@ -8,6 +8,7 @@ import (
 	"gallery-downloader/headers"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"os/signal"
@@ -49,6 +50,12 @@ func main() {
 		log.Fatalf("Client configuration file not found")
 	}
 
+	// types of the video playlists and their segments, missing from most systems
+	_ = mime.AddExtensionType(".m3u8", "application/vnd.apple.mpegurl")
+	_ = mime.AddExtensionType(".mpd", "application/dash+xml")
+	_ = mime.AddExtensionType(".ts", "video/mp2t")
+	_ = mime.AddExtensionType(".m4s", "video/iso.segment")
+
 	log.Printf("Serving files from '%s' (use -root option to change the default)", root)
 	fs := http.FileServer(http.Dir(root))
 
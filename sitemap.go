@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"gallery-downloader/config"
+	"gallery-downloader/download"
+	"gallery-downloader/scan"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxSitemapSize is the maximum size of an uncompressed sitemap (50MB from the sitemaps protocol)
+	maxSitemapSize = 50 << 20
+	// maxSitemaps is the maximum number of sitemaps read from the indexes
+	maxSitemaps = 1000
+)
+
+// sitemapWalker reads a sitemap, or all the sitemaps of an index, and keeps the pages listing pictures
+type sitemapWalker struct {
+	pattern *regexp.Regexp
+	context *download.Context
+	visited map[string]bool
+	pages   []scan.SitemapPage
+	// index of the page in pages, to merge the pictures of a page listed twice
+	pageIndex map[string]int
+}
+
+// downloadSitemap downloads the pictures of the <image:image> entries of a sitemap, into a subfolder per page
+func downloadSitemap(sitemapURL *url.URL, flags Flags, cfg *config.Configuration) error {
+	walker := &sitemapWalker{
+		pattern:   regexp.MustCompile(flags.Pattern),
+		context:   pageContext(flags.Referer, flags, cfg),
+		visited:   make(map[string]bool),
+		pages:     make([]scan.SitemapPage, 0),
+		pageIndex: make(map[string]int),
+	}
+	if err := walker.walk(sitemapURL); err != nil {
+		return err
+	}
+
+	total := 0
+	for _, page := range walker.pages {
+		total += len(page.Pictures)
+	}
+	log.Printf("Found %d pictures in %d pages of %d sitemaps", total, len(walker.pages), len(walker.visited))
+	if total == 0 {
+		log.Println("No picture found in the sitemap!")
+		return errNoPicture
+	}
+
+	folders := make(map[string]bool)
+	for index, page := range walker.pages {
+		pageURL, err := url.Parse(page.URL)
+		if err != nil {
+			log.Printf("Error: invalid page URL %q: %v", page.URL, err)
+			continue
+		}
+		output := path.Join(flags.Output, uniqueFolderName(pageFolderName(pageURL, index+1), folders))
+		if err := os.MkdirAll(output, 0755); err != nil {
+			log.Printf("Error: cannot create page folder: %v", err)
+			continue
+		}
+		log.Printf("Page %d/%d: %s (%d pictures)", index+1, len(walker.pages), pageURL, len(page.Pictures))
+		profile := sitemapProfile(pageURL, flags, cfg)
+		downloadContext := download.NewContext(download.Config{
+			User:          flags.User,
+			Password:      flags.Password,
+			Browser:       cfg.Browser,
+			SkipVerifyTLS: flags.InsecureTLS,
+			BaseURL:       pageURL,
+			Referer:       pageURL.String(),
+			Output:        output,
+			WaitMin:       profile.MinWait,
+			WaitMax:       profile.MaxWait,
+			Parallel:      profile.Parallel,
+			Progress:      progressHandler(flags),
+		})
+		downloadContext.Pictures(rewritePictures(page.Pictures, profile))
+	}
+	return nil
+}
+
+// walk reads a sitemap, and the sitemaps it lists when it's an index.
+// It only returns an error when the first sitemap cannot be read
+func (w *sitemapWalker) walk(sitemapURL *url.URL) error {
+	queue := []*url.URL{sitemapURL}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if w.visited[current.String()] {
+			continue
+		}
+		if len(w.visited) >= maxSitemaps {
+			log.Printf("Error: more than %d sitemaps, the others are skipped", maxSitemaps)
+			return nil
+		}
+		w.visited[current.String()] = true
+
+		var sitemap *scan.Sitemap
+		source, err := w.fetch(current)
+		if err == nil {
+			sitemap, err = scan.ParseSitemap(source)
+		}
+		if err != nil && current == sitemapURL {
+			return fmt.Errorf("cannot read sitemap: %w", err)
+		}
+		if err != nil {
+			log.Printf("Error: cannot read sitemap %s: %v", current, err)
+			continue
+		}
+		if len(sitemap.Sitemaps) > 0 {
+			log.Printf("Sitemap index %s lists %d sitemaps", current, len(sitemap.Sitemaps))
+		}
+		for _, link := range sitemap.Sitemaps {
+			linkURL, err := url.Parse(link)
+			if err != nil {
+				log.Printf("Error: invalid sitemap URL %q: %v", link, err)
+				continue
+			}
+			queue = append(queue, current.ResolveReference(linkURL))
+		}
+		w.addPages(sitemap.Pages)
+	}
+	return nil
+}
+
+// addPages keeps the pages matching the pattern and listing pictures
+func (w *sitemapWalker) addPages(pages []scan.SitemapPage) {
+	for _, page := range pages {
+		if len(page.Pictures) == 0 || !w.pattern.MatchString(page.URL) {
+			continue
+		}
+		if index, found := w.pageIndex[page.URL]; found {
+			w.pages[index].Pictures = appendNewPictures(w.pages[index].Pictures, page.Pictures)
+			continue
+		}
+		w.pageIndex[page.URL] = len(w.pages)
+		w.pages = append(w.pages, page)
+	}
+}
+
+// appendNewPictures adds the pictures missing from the list, when a page is listed in many sitemaps
+func appendNewPictures(pictures, others []scan.Record) []scan.Record {
+	seen := make(map[string]bool, len(pictures))
+	for _, picture := range pictures {
+		seen[picture.URL] = true
+	}
+	for _, picture := range others {
+		if !seen[picture.URL] {
+			seen[picture.URL] = true
+			pictures = append(pictures, picture)
+		}
+	}
+	return pictures
+}
+
+// fetch downloads a sitemap, or reads it from a file, and decompresses it when it's gzipped (.xml.gz)
+func (w *sitemapWalker) fetch(sitemapURL *url.URL) ([]byte, error) {
+	var source []byte
+	var err error
+	if sitemapURL.Scheme == "" {
+		source, err = ioutil.ReadFile(sitemapURL.Path)
+	} else {
+		source, err = w.context.Data(sitemapURL.String(), maxSitemapSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(source, []byte{0x1f, 0x8b}) {
+		return source, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	source, err = ioutil.ReadAll(io.LimitReader(reader, maxSitemapSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(source) > maxSitemapSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes once decompressed", sitemapURL, maxSitemapSize)
+	}
+	return source, nil
+}
+
+// pageFolderName returns the name of the folder of a page: the last part of its path,
+// or the one before for an index page like /summer/index.html
+func pageFolderName(pageURL *url.URL, index int) string {
+	pagePath := strings.TrimSuffix(pageURL.Path, "/")
+	if name := strings.ToLower(path.Base(pagePath)); strings.HasPrefix(name, "index.") || strings.HasPrefix(name, "default.") {
+		pagePath = path.Dir(pagePath)
+	}
+	name := path.Base(pagePath)
+	if name == "" || name == "/" || name == "." {
+		if pageURL.Host != "" {
+			return pageURL.Host
+		}
+		return fmt.Sprintf("page-%d", index)
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// sitemapProfile returns the profile forced by -profile, or the first one matching the URL of the page,
+// for its waits, parallel downloads and rewrite rules
+func sitemapProfile(pageURL *url.URL, flags Flags, cfg *config.Configuration) config.Profile {
+	if profile, found := cfg.Profile(flags.Profile); found {
+		return profile
+	}
+	order := profileOrder(cfg.Profiles, pageURL)
+	if len(order) > 0 && cfg.Profiles[order[0]].MatchURL(pageURL) {
+		return cfg.Profiles[order[0]]
+	}
+	return config.Profile{}
+}
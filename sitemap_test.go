@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"gallery-downloader/scan"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSitemap saves the content into a file, gzipped when the name ends with .gz
+func writeSitemap(t *testing.T, name string, content []byte) *url.URL {
+	filename := filepath.Join(t.TempDir(), name)
+	if filepath.Ext(name) == ".gz" {
+		buffer := &bytes.Buffer{}
+		writer := gzip.NewWriter(buffer)
+		_, err := writer.Write(content)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		content = buffer.Bytes()
+	}
+	require.NoError(t, ioutil.WriteFile(filename, content, 0644))
+	return &url.URL{Path: filename}
+}
+
+func TestFetchSitemap(t *testing.T) {
+	sitemap := []byte(`<?xml version="1.0" encoding="UTF-8"?><urlset></urlset>`)
+	walker := &sitemapWalker{}
+
+	source, err := walker.fetch(writeSitemap(t, "sitemap.xml", sitemap))
+	require.NoError(t, err)
+	assert.Equal(t, sitemap, source)
+
+	source, err = walker.fetch(writeSitemap(t, "sitemap.xml.gz", sitemap))
+	require.NoError(t, err)
+	assert.Equal(t, sitemap, source)
+
+	source, err = walker.fetch(writeSitemap(t, "sitemap.xml.gz", make([]byte, maxSitemapSize)))
+	require.NoError(t, err)
+	assert.Len(t, source, maxSitemapSize)
+
+	// never truncated
+	_, err = walker.fetch(writeSitemap(t, "sitemap.xml.gz", make([]byte, maxSitemapSize+1)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is larger than 52428800 bytes once decompressed")
+}
+
+func TestPageFolderName(t *testing.T) {
+	testData := []struct {
+		page     string
+		expected string
+	}{
+		{"https://example.com/galleries/summer", "summer"},
+		{"https://example.com/galleries/summer/", "summer"},
+		{"https://example.com/galleries/beach.html", "beach"},
+		{"https://example.com/galleries/summer/index.html", "summer"},
+		{"https://example.com/galleries/summer/INDEX.PHP", "summer"},
+		{"https://example.com/galleries/summer/default.aspx", "summer"},
+		{"https://example.com/galleries/indexes", "indexes"},
+		{"https://example.com/galleries/2020.summer.html", "2020.summer"},
+		{"https://example.com/", "example.com"},
+		{"https://example.com", "example.com"},
+		{"https://example.com/index.html", "example.com"},
+		{"", "page-3"},
+		{"/", "page-3"},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.page, func(t *testing.T) {
+			assert.Equal(t, testItem.expected, pageFolderName(mustParseURL(t, testItem.page), 3))
+		})
+	}
+}
+
+func TestAddSitemapPages(t *testing.T) {
+	walker := &sitemapWalker{
+		pattern:   regexp.MustCompile("/galleries/"),
+		pages:     make([]scan.SitemapPage, 0),
+		pageIndex: make(map[string]int),
+	}
+	pictures := func(links ...string) []scan.Record {
+		return scan.NewRecords(links)
+	}
+
+	walker.addPages([]scan.SitemapPage{
+		{URL: "https://example.com/galleries/summer", Pictures: pictures("summer/1.jpg", "summer/2.jpg")},
+		{URL: "https://example.com/about", Pictures: pictures("team.jpg")},
+		{URL: "https://example.com/galleries/empty"},
+		{URL: "https://example.com/galleries/winter", Pictures: pictures("winter/1.jpg")},
+	})
+	// the second sitemap lists a page of the first one again, with a picture already found
+	walker.addPages([]scan.SitemapPage{
+		{URL: "https://example.com/galleries/autumn", Pictures: pictures("autumn/1.jpg")},
+		{URL: "https://example.com/galleries/summer", Pictures: pictures("summer/2.jpg", "summer/3.jpg", "summer/3.jpg")},
+		{URL: "https://example.com/galleries/empty", Pictures: pictures("empty/1.jpg")},
+	})
+
+	expected := []scan.SitemapPage{
+		{URL: "https://example.com/galleries/summer", Pictures: pictures("summer/1.jpg", "summer/2.jpg", "summer/3.jpg")},
+		{URL: "https://example.com/galleries/winter", Pictures: pictures("winter/1.jpg")},
+		{URL: "https://example.com/galleries/autumn", Pictures: pictures("autumn/1.jpg")},
+		{URL: "https://example.com/galleries/empty", Pictures: pictures("empty/1.jpg")},
+	}
+	assert.Equal(t, expected, walker.pages)
+
+	// all the pages without pattern
+	walker = &sitemapWalker{
+		pattern:   regexp.MustCompile(""),
+		pages:     make([]scan.SitemapPage, 0),
+		pageIndex: make(map[string]int),
+	}
+	walker.addPages([]scan.SitemapPage{
+		{URL: "https://example.com/about", Pictures: pictures("team.jpg")},
+		{URL: "https://example.com/galleries/empty"},
+	})
+	assert.Equal(t, []scan.SitemapPage{{URL: "https://example.com/about", Pictures: pictures("team.jpg")}}, walker.pages)
+}
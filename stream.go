@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gallery-downloader/config"
+	"gallery-downloader/download"
+	"gallery-downloader/scan"
+	"io"
+	"log"
+	"net/url"
+)
+
+// streamBuffer is the number of pictures found in advance, while the downloader is busy
+const streamBuffer = 100
+
+// newStreamMatcher returns the matcher used to scan the page while it's downloading,
+// with the profile forced by the -profile flag, or the first one matching the URL of the page.
+// The built-in scanners can also be streamed, but not the profiles following the next pages
+func newStreamMatcher(pageURL *url.URL, flags Flags, cfg *config.Configuration) (*scan.StreamMatcher, config.Profile, error) {
+	if flags.Type != scan.AutoDetect && flags.Type != scan.ConfigProfiles {
+		matcher, err := scan.NewBuiltinStreamMatcher(flags.Type, scan.Config{Name: flags.Type, Extensions: cfg.Extensions})
+		return matcher, config.Profile{Name: flags.Type}, err
+	}
+
+	profile, found := cfg.Profile(flags.Profile)
+	if !found {
+		order := profileOrder(cfg.Profiles, pageURL)
+		if len(order) == 0 || !cfg.Profiles[order[0]].MatchURL(pageURL) {
+			return nil, config.Profile{}, errors.New("no profile matches the URL of the page (use -profile)")
+		}
+		profile = cfg.Profiles[order[0]]
+	}
+	if profile.Pagination.IsSet() {
+		// the next pages are found once the whole page is read, and their pictures go into the same gallery
+		return nil, profile, fmt.Errorf("profile %s follows the next pages of the gallery", profile.Name)
+	}
+	matcher, err := profile.DetectImage.StreamMatcher()
+	if err != nil {
+		return nil, profile, err
+	}
+	matcher.SetExtensions(profile.Extensions)
+	return matcher, profile, nil
+}
+
+// streamGallery downloads the pictures as soon as they're found in the page.
+// It returns the number of pictures found
+func streamGallery(reader io.Reader, matcher *scan.StreamMatcher, profile config.Profile, downloadContext *download.Context) int {
+	log.Printf("Streaming pictures using profile %s", profile.Name)
+	rewriter := newRewriter(profile)
+	pictures := make(chan download.Picture, streamBuffer)
+	var err error
+	go func() {
+		defer close(pictures)
+		seen := make(map[string]bool)
+		err = matcher.Stream(reader, func(link string) {
+			if seen[link] {
+				return
+			}
+			seen[link] = true
+			pictures <- rewriter.picture(scan.Record{URL: link})
+		})
+	}()
+
+	count := downloadContext.Stream(pictures)
+	if err != nil {
+		log.Printf("Error: cannot read the whole page: %v", err)
+	}
+	return count
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"gallery-downloader/config"
+	"gallery-downloader/scan"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStreamMatcher(t *testing.T) {
+	pageURL := mustParseURL(t, "https://photos.example.com/gallery/summer")
+	images := config.Parser{Type: "css", Match: "img", Attribute: config.Attributes{"src"}}
+	cfg := &config.Configuration{Profiles: []config.Profile{
+		{Name: "other", Hosts: []string{"example.org"}, DetectImage: images},
+		{Name: "photos", Hosts: []string{".example.com"}, DetectImage: images},
+		{Name: "pages", DetectImage: images, Pagination: config.Pagination{Pattern: "?page={page}"}},
+		{Name: "regexp", DetectImage: regexpParser(`src="([^"]+)"`)},
+	}}
+
+	testData := []struct {
+		name     string
+		profile  string
+		expected string
+		err      string
+	}{
+		{"matching the url", "", "photos", ""},
+		{"forced", "other", "other", ""},
+		{"pagination", "pages", "pages", "profile pages follows the next pages of the gallery"},
+		{"not streamable", "regexp", "regexp", scan.ErrNotStreamable.Error()},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			flags := Flags{Type: scan.ConfigProfiles, Profile: testItem.profile}
+			matcher, profile, err := newStreamMatcher(pageURL, flags, cfg)
+			assert.Equal(t, testItem.expected, profile.Name)
+			if testItem.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testItem.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, matcher)
+		})
+	}
+
+	_, _, err := newStreamMatcher(mustParseURL(t, "https://example.net/"), Flags{Type: scan.ConfigProfiles}, cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,214 @@
+// Package transcode converts the HTML pages to UTF-8 before scanning them
+package transcode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// UTF8 is the name of the charset of the pages that don't need any conversion
+const UTF8 = "utf-8"
+
+// previewSize is the number of bytes read to detect the charset, like browsers do
+const previewSize = 1024
+
+// byte order marks, in the order they must be checked
+var boms = []struct {
+	bom  []byte
+	name string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, "utf-8"},
+	{[]byte{0xfe, 0xff}, "utf-16be"},
+	{[]byte{0xff, 0xfe}, "utf-16le"},
+}
+
+// Lookup returns the encoding of a charset label like "Shift_JIS", "cp1251" or "latin1", and its canonical name
+func Lookup(label string) (encoding.Encoding, string, error) {
+	enc, name := charset.Lookup(strings.TrimSpace(label))
+	if enc == nil {
+		return nil, "", fmt.Errorf("unknown charset %q", label)
+	}
+	return enc, name, nil
+}
+
+// ToUTF8 converts the page to UTF-8 and returns the name of its original charset.
+// The charset is given by the label when not empty, otherwise it's detected from the byte order mark,
+// the Content-Type header, then the XML declaration of a feed or the <meta> tags of the page.
+// A page without any of these is UTF-8 if it's valid UTF-8, or windows-1252 otherwise
+func ToUTF8(source []byte, contentType, label string) ([]byte, string, error) {
+	source, enc, name, err := detect(source, contentType, label)
+	if err != nil {
+		return nil, "", err
+	}
+	if enc == nil {
+		if utf8.Valid(source) {
+			return source, UTF8, nil
+		}
+		enc, name, _ = Lookup("windows-1252")
+	}
+	if name == UTF8 {
+		return source, name, nil
+	}
+	converted, _, err := transform.Bytes(enc.NewDecoder(), source)
+	if err != nil {
+		return nil, name, fmt.Errorf("cannot convert page from %s: %w", name, err)
+	}
+	return converted, name, nil
+}
+
+// NewReader converts the page to UTF-8 while it's read, and returns the name of its original charset.
+// The charset is detected like ToUTF8, except that only the beginning of the page is checked for valid UTF-8
+func NewReader(reader io.Reader, contentType, label string) (io.Reader, string, error) {
+	preview := make([]byte, previewSize)
+	n, err := io.ReadFull(reader, preview)
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		preview = preview[:n]
+		reader = bytes.NewReader(preview)
+	case err != nil:
+		return nil, "", err
+	default:
+		reader = io.MultiReader(bytes.NewReader(preview), reader)
+	}
+
+	trimmed, enc, name, err := detect(preview, contentType, label)
+	if err != nil {
+		return nil, "", err
+	}
+	// skip the byte order mark
+	if _, err = io.CopyN(io.Discard, reader, int64(len(preview)-len(trimmed))); err != nil {
+		return nil, "", err
+	}
+	if enc == nil {
+		if utf8.Valid(trimPartialRune(trimmed)) {
+			return reader, UTF8, nil
+		}
+		enc, name, _ = Lookup("windows-1252")
+	}
+	if name == UTF8 {
+		return reader, name, nil
+	}
+	return transform.NewReader(reader, enc.NewDecoder()), name, nil
+}
+
+// detect returns the source without its byte order mark, and the encoding of the page.
+// The encoding is nil when there's no hint about the charset
+func detect(source []byte, contentType, label string) ([]byte, encoding.Encoding, string, error) {
+	for _, bom := range boms {
+		if bytes.HasPrefix(source, bom.bom) {
+			source = source[len(bom.bom):]
+			if label == "" {
+				enc, name, err := Lookup(bom.name)
+				return source, enc, name, err
+			}
+			break
+		}
+	}
+	if label != "" {
+		enc, name, err := Lookup(label)
+		return source, enc, name, err
+	}
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		if enc, name, err := Lookup(params["charset"]); err == nil {
+			return source, enc, name, nil
+		}
+	}
+	declared := xmlCharset(source)
+	if declared == "" {
+		declared = metaCharset(source)
+	}
+	if declared != "" {
+		if enc, name, err := Lookup(declared); err == nil {
+			if strings.HasPrefix(name, "utf-16") {
+				// the page was readable as ASCII, so it cannot be UTF-16
+				enc, name, err = Lookup(UTF8)
+			}
+			return source, enc, name, err
+		}
+	}
+	return source, nil, "", nil
+}
+
+// xmlDeclaration finds the encoding of the XML declaration starting a document like a feed
+var xmlDeclaration = regexp.MustCompile(`^\s*<\?xml\s[^>]*?\bencoding\s*=\s*["']([^"']+)["']`)
+
+// xmlCharset returns the encoding declared by <?xml version="1.0" encoding="..."?>
+func xmlCharset(source []byte) string {
+	if len(source) > previewSize {
+		source = source[:previewSize]
+	}
+	if match := xmlDeclaration.FindSubmatch(source); match != nil {
+		return strings.TrimSpace(string(match[1]))
+	}
+	return ""
+}
+
+// metaCharset returns the charset declared by a <meta charset> or <meta http-equiv="Content-Type"> tag
+// at the beginning of the page
+func metaCharset(source []byte) string {
+	if len(source) > previewSize {
+		source = source[:previewSize]
+	}
+	tokenizer := html.NewTokenizer(bytes.NewReader(source))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Body:
+				return ""
+			case atom.Meta:
+				if found := metaTagCharset(token.Attr); found != "" {
+					return found
+				}
+			}
+		}
+	}
+}
+
+func metaTagCharset(attributes []html.Attribute) string {
+	httpEquiv, content := "", ""
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "charset":
+			return strings.TrimSpace(attribute.Val)
+		case "http-equiv":
+			httpEquiv = attribute.Val
+		case "content":
+			content = attribute.Val
+		}
+	}
+	if !strings.EqualFold(strings.TrimSpace(httpEquiv), "content-type") {
+		return ""
+	}
+	if _, params, err := mime.ParseMediaType(content); err == nil {
+		return params["charset"]
+	}
+	return ""
+}
+
+// trimPartialRune removes the incomplete UTF-8 sequence at the end of a preview
+func trimPartialRune(preview []byte) []byte {
+	for i := len(preview) - 1; i >= 0 && i > len(preview)-utf8.UTFMax; i-- {
+		if preview[i] < utf8.RuneSelf {
+			break
+		}
+		if utf8.RuneStart(preview[i]) {
+			return preview[:i]
+		}
+	}
+	return preview
+}
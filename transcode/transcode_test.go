@@ -0,0 +1,94 @@
+package transcode
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+	encoded, err := enc.NewEncoder().Bytes([]byte(text))
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestToUTF8(t *testing.T) {
+	const title = "<title>写真 — Фото — Été</title>"
+	const titleLatin = "<title>Photos de l'été à Orléans</title>"
+	const titleCyrillic = "<title>Фотографии</title>"
+	const titleJapanese = "<title>写真のギャラリー</title>"
+
+	testData := []struct {
+		name        string
+		source      []byte
+		contentType string
+		label       string
+		expected    string
+		charset     string
+	}{
+		{"utf-8 without hint", []byte(title), "", "", title, "utf-8"},
+		{"ascii without hint", []byte("<p>hello</p>"), "", "", "<p>hello</p>", "utf-8"},
+		{"latin1 without hint", encode(t, charmap.Windows1252, titleLatin), "", "", titleLatin, "windows-1252"},
+		{"utf-8 bom", append([]byte{0xef, 0xbb, 0xbf}, title...), "", "", title, "utf-8"},
+		{"utf-16le bom", append([]byte{0xff, 0xfe}, encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), title)...), "text/html; charset=iso-8859-1", "", title, "utf-16le"},
+		{"content type", encode(t, charmap.Windows1251, titleCyrillic), "text/html; charset=windows-1251", "", titleCyrillic, "windows-1251"},
+		{"meta charset", encode(t, japanese.ShiftJIS, `<meta charset="Shift_JIS">`+titleJapanese), "", "", `<meta charset="Shift_JIS">` + titleJapanese, "shift_jis"},
+		{"meta http-equiv", encode(t, charmap.ISO8859_1, `<meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1">`+titleLatin), "text/html", "", `<meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1">` + titleLatin, "windows-1252"},
+		{"meta utf-16", []byte(`<meta charset="utf-16">` + title), "", "", `<meta charset="utf-16">` + title, "utf-8"},
+		{"header before meta", encode(t, charmap.Windows1251, `<meta charset="utf-8">`+titleCyrillic), "text/html; charset=cp1251", "", `<meta charset="utf-8">` + titleCyrillic, "windows-1251"},
+		{"xml declaration", encode(t, japanese.ShiftJIS, `<?xml version="1.0" encoding="Shift_JIS"?><rss><title>写真</title></rss>`), "application/rss+xml", "", `<?xml version="1.0" encoding="Shift_JIS"?><rss><title>写真</title></rss>`, "shift_jis"},
+		{"xml declaration single quotes", encode(t, charmap.Windows1251, "<?xml version='1.0' encoding='windows-1251' standalone='yes'?>"+titleCyrillic), "text/xml", "", "<?xml version='1.0' encoding='windows-1251' standalone='yes'?>" + titleCyrillic, "windows-1251"},
+		{"header before xml declaration", encode(t, charmap.Windows1251, `<?xml version="1.0" encoding="utf-8"?>`+titleCyrillic), "text/xml; charset=windows-1251", "", `<?xml version="1.0" encoding="utf-8"?>` + titleCyrillic, "windows-1251"},
+		{"label before header", encode(t, japanese.ShiftJIS, titleJapanese), "text/html; charset=utf-8", "sjis", titleJapanese, "shift_jis"},
+	}
+
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			converted, name, err := ToUTF8(testItem.source, testItem.contentType, testItem.label)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.expected, string(converted))
+			assert.Equal(t, testItem.charset, name)
+
+			reader, name, err := NewReader(iotest.OneByteReader(bytes.NewReader(testItem.source)), testItem.contentType, testItem.label)
+			require.NoError(t, err)
+			streamed, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.expected, string(streamed))
+			assert.Equal(t, testItem.charset, name)
+		})
+	}
+}
+
+func TestNewReaderLargePage(t *testing.T) {
+	page := encode(t, charmap.Windows1251, `<html><head><meta charset="windows-1251"></head><body>`+string(bytes.Repeat([]byte("<p>Фото</p>"), 1000))+"</body></html>")
+	reader, name, err := NewReader(bytes.NewReader(page), "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "windows-1251", name)
+
+	converted, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, bytes.Count(converted, []byte("<p>Фото</p>")))
+}
+
+func TestUnknownCharset(t *testing.T) {
+	_, _, err := Lookup("klingon")
+	assert.Error(t, err)
+
+	_, _, err = ToUTF8([]byte("<p>hello</p>"), "", "klingon")
+	assert.Error(t, err)
+
+	// an unknown charset in the page is ignored
+	converted, name, err := ToUTF8([]byte(`<meta charset="klingon"><p>hello</p>`), "text/html; charset=klingon", "")
+	require.NoError(t, err)
+	assert.Equal(t, "utf-8", name)
+	assert.Equal(t, `<meta charset="klingon"><p>hello</p>`, string(converted))
+}